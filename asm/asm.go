@@ -0,0 +1,683 @@
+// The two-pass assembler core. Pass one parses every statement,
+// assigns addresses, and collects symbol definitions; pass two encodes
+// instructions and data with all symbols resolved. Errors are
+// collected and reported in bulk so one bad line does not hide the
+// rest.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gmofishsauce/y4/diag"
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/lex"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+const (
+	segCode = iota
+	segData
+)
+
+type opndKind int
+
+const (
+	opReg opndKind = iota
+	opExpr
+	opString
+)
+
+type operand struct {
+	kind opndKind
+	reg  int    // opReg
+	text string // opExpr: symbol or number text; opString: contents
+	neg  bool   // opExpr: preceded by unary minus
+}
+
+type stmt struct {
+	line  int
+	label string
+	op    string // mnemonic or directive; "" for label-only lines
+	opnds []operand
+	seg   int
+	addr  int // word address (code) or byte address (data)
+	frame int // .func/.endfunc: frame size in bytes
+	size  int // .space: reserved byte count, fixed in pass1
+
+	// Comment attachment, populated only under KeepComments: the
+	// full-line comments preceding this statement, and the trailing
+	// comment sharing its line (zero Kind when there is none). The
+	// tokens keep their source positions.
+	comments []lex.Token
+	trailing lex.Token
+}
+
+type Assembler struct {
+	st     *SymbolTable
+	stmts  []stmt
+	eng    *diag.Engine
+	isaRev int
+
+	// KeepComments attaches comments to statements during parsing:
+	// a comment block attaches to the statement that follows it, a
+	// trailing comment to its own statement's line. The assembler
+	// proper never needs them; the listing generator and other
+	// source-round-tripping tools built on this parser do.
+	KeepComments bool
+
+	// .func/.endfunc state, valid during pass1.
+	curFunc     string
+	curFuncLine int
+	curFrame    int
+}
+
+func NewAssembler() *Assembler {
+	return &Assembler{st: NewSymbolTable(), eng: diag.NewEngine(""), isaRev: isa.Version}
+}
+
+// SetIsaRev sets the ISA revision being targeted. Instructions newer
+// than the target are assembly errors, and the revision is stamped
+// into the output header so the emulator can enforce it at load time.
+func (a *Assembler) SetIsaRev(rev int) { a.isaRev = rev }
+
+// Diags exposes the diagnostics engine so the driver can configure
+// it (file name, limits, output format) before and after Assemble.
+func (a *Assembler) Diags() *diag.Engine { return a.eng }
+
+func (a *Assembler) errorf(line int, format string, args ...interface{}) {
+	a.eng.Errorf(line, 0, format, args...)
+}
+
+// errorfAt reports a syntax error with the token's full position.
+func (a *Assembler) errorfAt(t lex.Token, format string, args ...interface{}) {
+	a.eng.Errorf(t.Line, t.Col, format, args...)
+}
+
+// Assemble runs both passes over one source file and returns the
+// binary image; nil if any errors were recorded in the engine.
+func (a *Assembler) Assemble(src []byte) (*obj.Image, *diag.Engine) {
+	lx := lex.NewLexer(src)
+	lx.KeepComments = a.KeepComments
+	a.parse(lx)
+	if !a.eng.Failed() {
+		a.pass1()
+	}
+	if !a.eng.Failed() {
+		for _, sym := range a.st.ResolveAll() {
+			a.eng.Errorf(sym.UseLine, 0, "symbol %q is never defined", sym.Name)
+		}
+	}
+	var img *obj.Image
+	if !a.eng.Failed() {
+		img = a.pass2()
+	}
+	return img, a.eng
+}
+
+// parse consumes the token stream, building one stmt per non-empty
+// source line. Source positions come from the tokens themselves.
+// Under KeepComments, full-line comments accumulate and attach to
+// the next statement; a run left dangling at end of file becomes a
+// statement of its own so round-tripping tools do not lose it.
+func (a *Assembler) parse(lx *lex.Lexer) {
+	var pending []lex.Token
+	for {
+		t := lx.Next()
+		switch t.Kind {
+		case lex.TkEOF:
+			if len(pending) > 0 {
+				a.stmts = append(a.stmts, stmt{line: pending[0].Line, comments: pending})
+			}
+			return
+		case lex.TkNewline:
+			continue
+		case lex.TkError:
+			a.errorfAt(t, "%s", t.Text)
+			a.skipLine(lx)
+			continue
+		case lex.TkComment:
+			pending = append(pending, t)
+		case lex.TkSymbol:
+			a.parseStmt(lx, t, pending)
+			pending = nil
+		default:
+			a.errorfAt(t, "unexpected %s at start of line", t)
+			a.skipLine(lx)
+		}
+	}
+}
+
+func (a *Assembler) parseStmt(lx *lex.Lexer, first lex.Token, comments []lex.Token) {
+	s := stmt{line: first.Line, comments: comments}
+	// One token of lookahead distinguishes "symbol:" from "symbol
+	// operand": the colon follows immediately for a label.
+	if lx.Peek(0).Kind == lex.TkColon {
+		s.label = first.Text
+		lx.Next() // the colon
+		if lx.Peek(0).Kind == lex.TkSymbol {
+			s.op = lx.Next().Text
+		}
+	} else {
+		s.op = first.Text
+	}
+	t := lx.Next()
+
+	// Operands until end of line.
+	for s.op != "" && t.Kind != lex.TkNewline && t.Kind != lex.TkEOF && t.Kind != lex.TkComment {
+		o, ok := a.parseOperand(lx, &t)
+		if !ok {
+			a.skipLine(lx)
+			a.stmts = append(a.stmts, s)
+			return
+		}
+		s.opnds = append(s.opnds, o)
+		if t.Kind == lex.TkComma {
+			t = lx.Next()
+			continue
+		}
+	}
+	if t.Kind == lex.TkComment {
+		s.trailing = t
+		t = lx.Next()
+	}
+	if t.Kind != lex.TkNewline && t.Kind != lex.TkEOF {
+		a.errorfAt(t, "unexpected %s after statement", t)
+		a.skipLine(lx)
+	}
+	a.stmts = append(a.stmts, s)
+}
+
+// parseOperand parses one operand starting at *t and leaves the
+// following token (comma or newline) in *t.
+func (a *Assembler) parseOperand(lx *lex.Lexer, t *lex.Token) (operand, bool) {
+	var o operand
+	switch t.Kind {
+	case lex.TkMinus:
+		o.neg = true
+		*t = lx.Next()
+		if t.Kind != lex.TkNumber && t.Kind != lex.TkSymbol {
+			a.errorfAt(*t, "expected number or symbol after '-'")
+			return o, false
+		}
+		fallthrough
+	case lex.TkNumber, lex.TkSymbol:
+		if r, ok := regNumber(t.Text); ok && !o.neg {
+			o.kind = opReg
+			o.reg = r
+		} else {
+			o.kind = opExpr
+			o.text = t.Text
+		}
+	case lex.TkString:
+		o.kind = opString
+		o.text = t.Text
+	default:
+		a.errorfAt(*t, "unexpected %s in operand", *t)
+		return o, false
+	}
+	*t = lx.Next()
+	return o, true
+}
+
+func (a *Assembler) skipLine(lx *lex.Lexer) {
+	for {
+		t := lx.Next()
+		if t.Kind == lex.TkNewline || t.Kind == lex.TkEOF {
+			return
+		}
+	}
+}
+
+func regNumber(s string) (int, bool) {
+	if len(s) == 2 && s[0] == 'r' && s[1] >= '0' && s[1] <= '7' {
+		return int(s[1] - '0'), true
+	}
+	// Conventional role names from the ABI.
+	switch s {
+	case "lnk":
+		return 1, true
+	case "sp":
+		return 6, true
+	}
+	return 0, false
+}
+
+// pass1 assigns addresses, defines labels and .set symbols, and
+// records symbol uses.
+func (a *Assembler) pass1() {
+	seg := segCode
+	addr := [2]int{0, 0}
+	for i := range a.stmts {
+		s := &a.stmts[i]
+		switch s.op {
+		case ".code":
+			seg = segCode
+		case ".data":
+			seg = segData
+		}
+		if seg == segData && s.op == ".word" && addr[segData]%2 != 0 {
+			addr[segData]++ // align words in the data segment
+		}
+		s.seg = seg
+		s.addr = addr[seg]
+		if s.label != "" {
+			a.defineSym(s.line, s.label, s.addr)
+		}
+		if s.op == ".set" {
+			a.doSet(s)
+			continue
+		}
+		switch s.op {
+		case ".func":
+			a.doFunc(s)
+		case ".endfunc":
+			a.doEndFunc(s)
+		}
+		for _, o := range s.opnds {
+			if o.kind == opExpr && !isNumber(o.text) {
+				a.st.Use(o.text, s.line)
+			}
+		}
+		addr[seg] += a.sizeOf(s)
+	}
+	if a.curFunc != "" {
+		a.errorf(a.curFuncLine, ".func %q not closed by .endfunc", a.curFunc)
+	}
+	if addr[segCode] > 0xFFFF {
+		a.errorf(0, "code segment overflow: %d words", addr[segCode])
+	}
+	if addr[segData] > 0xFFFF {
+		a.errorf(0, "data segment overflow: %d bytes", addr[segData])
+	}
+}
+
+// defineSym applies the symbol definition policy before recording a
+// definition. Names are case-sensitive. A name that is also a
+// register name or alias is an error: operand parsing reads it as
+// the register, so the symbol could never be referenced. A name that
+// shadows a mnemonic, or that differs from an earlier definition
+// only in letter case, is legal but draws a warning.
+func (a *Assembler) defineSym(line int, name string, value int) {
+	if _, ok := regNumber(name); ok {
+		a.errorf(line, "symbol %q shadows a register name", name)
+	} else if isMnemonic(name) {
+		a.eng.Warnf(line, 0, "symbol %q shadows a mnemonic", name)
+	}
+	if prev, ok := a.st.CaseVariant(name); ok {
+		a.eng.Warnf(line, 0, "symbol %q differs from %q only in case", name, prev)
+	}
+	if err := a.st.Define(name, value); err != nil {
+		a.errorf(line, "%v", err)
+	}
+}
+
+// isMnemonic reports whether name is an instruction mnemonic or a
+// pseudo-instruction.
+func isMnemonic(name string) bool {
+	if pseudoOps[name] {
+		return true
+	}
+	for i := range isa.Defs {
+		if isa.Defs[i].Mnemonic == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Assembler) doSet(s *stmt) {
+	if len(s.opnds) != 2 || s.opnds[0].kind != opExpr || s.opnds[1].kind != opExpr {
+		a.errorf(s.line, ".set needs a name and a value")
+		return
+	}
+	v, ok := a.eval(s.opnds[1], s.line)
+	if !ok {
+		return
+	}
+	a.defineSym(s.line, s.opnds[0].text, v)
+}
+
+// doFunc handles .func name[, framesize] in pass1: it defines the
+// function label, records the frame size both for the epilogue and as
+// the debug symbol name.frame, and remembers the open function.
+func (a *Assembler) doFunc(s *stmt) {
+	if s.seg != segCode {
+		a.errorf(s.line, ".func in the data segment")
+		return
+	}
+	if a.curFunc != "" {
+		a.errorf(s.line, ".func %q inside .func %q", opText(s, 0), a.curFunc)
+		return
+	}
+	if len(s.opnds) < 1 || len(s.opnds) > 2 ||
+		s.opnds[0].kind != opExpr || isNumber(s.opnds[0].text) {
+		a.errorf(s.line, ".func needs a name and an optional frame size")
+		return
+	}
+	frame := 0
+	if len(s.opnds) == 2 {
+		// The frame size must be a literal so pass1 can size the
+		// prologue without forward references.
+		o := s.opnds[1]
+		if o.kind != opExpr || !isNumber(o.text) || o.neg {
+			a.errorf(s.line, ".func frame size must be a literal number")
+			return
+		}
+		v, ok := a.eval(o, s.line)
+		if !ok {
+			return
+		}
+		if v < 0 || v > 0x7FFF || v%2 != 0 {
+			a.errorf(s.line, ".func frame size %d must be even and 0..32766", v)
+			return
+		}
+		frame = v
+	}
+	name := s.opnds[0].text
+	a.defineSym(s.line, name, s.addr)
+	// Frame size as debug info: name.frame travels with the symbols.
+	// The synthetic name skips the policy checks, which already ran
+	// on the base name.
+	if err := a.st.Define(name+".frame", frame); err != nil {
+		a.errorf(s.line, "%v", err)
+	}
+	s.frame = frame
+	a.curFunc, a.curFuncLine, a.curFrame = name, s.line, frame
+}
+
+func (a *Assembler) doEndFunc(s *stmt) {
+	if a.curFunc == "" {
+		a.errorf(s.line, ".endfunc without .func")
+		return
+	}
+	if len(s.opnds) != 0 {
+		a.errorf(s.line, ".endfunc takes no operands")
+	}
+	s.frame = a.curFrame
+	a.curFunc = ""
+}
+
+func opText(s *stmt, i int) string {
+	if i < len(s.opnds) {
+		return s.opnds[i].text
+	}
+	return ""
+}
+
+// sizeOf returns the size of a statement in code words or data bytes.
+func (a *Assembler) sizeOf(s *stmt) int {
+	switch s.op {
+	case "", ".code", ".data", ".set":
+		return 0
+	case ".word":
+		if s.seg == segData {
+			return 2 * len(s.opnds)
+		}
+		return len(s.opnds)
+	case ".byte":
+		return len(s.opnds)
+	case ".space":
+		s.size = a.spaceSize(s)
+		return s.size
+	case ".string":
+		n := 0
+		for _, o := range s.opnds {
+			n += len(o.text)
+		}
+		return n
+	case "li", "bne", "push", "pop":
+		return 2
+	case ".func":
+		return 2 + frameAdjustWords(s.frame)
+	case ".endfunc":
+		return 3 + frameAdjustWords(s.frame)
+	default:
+		return 1
+	}
+}
+
+// Stats summarizes one assembly for -stats. Everything is derived
+// from the statement list after the passes, so the encoders carry no
+// counters.
+type Stats struct {
+	Instructions   int // machine instructions emitted, expansions included
+	Pseudos        int // pseudo-op statements expanded
+	CodeWords      int
+	DataBytes      int
+	CodeLo, CodeHi int // address spans, valid when the segment is nonempty
+	DataLo, DataHi int
+	Symbols        int
+	Warnings       int
+}
+
+// pseudoOps is the set of mnemonics encodePseudo expands.
+var pseudoOps = map[string]bool{
+	"nop": true, "mov": true, "rtl": true, "jmp": true,
+	"li": true, "push": true, "pop": true, "bne": true,
+}
+
+func (a *Assembler) Stats() Stats {
+	st := Stats{CodeLo: -1, DataLo: -1}
+	span := func(lo, hi *int, addr, size int) {
+		if size == 0 {
+			return
+		}
+		if *lo < 0 || addr < *lo {
+			*lo = addr
+		}
+		if end := addr + size - 1; end > *hi {
+			*hi = end
+		}
+	}
+	for i := range a.stmts {
+		s := &a.stmts[i]
+		size := a.sizeOf(s)
+		if s.seg == segData {
+			st.DataBytes += size
+			span(&st.DataLo, &st.DataHi, s.addr, size)
+			continue
+		}
+		st.CodeWords += size
+		span(&st.CodeLo, &st.CodeHi, s.addr, size)
+		switch {
+		case s.op == "" || strings.HasPrefix(s.op, "."):
+			// Directives: .word/.space in code are data-in-code, and
+			// .func/.endfunc emit instructions but are not pseudos.
+			if s.op == ".func" || s.op == ".endfunc" {
+				st.Instructions += size
+			}
+		case pseudoOps[s.op]:
+			st.Pseudos++
+			st.Instructions += size
+		default:
+			st.Instructions += size
+		}
+	}
+	st.Symbols = len(a.Symbols())
+	st.Warnings = a.eng.WarnCount()
+	return st
+}
+
+// LineMap returns (word address, source line) pairs for every
+// encoded code word, in address order. Multi-word statements (li,
+// push, .func prologues) map all their words to the statement's
+// line. Coverage tooling uses the map to annotate reports with
+// source positions.
+func (a *Assembler) LineMap() [][2]int {
+	var out [][2]int
+	for i := range a.stmts {
+		s := &a.stmts[i]
+		if s.seg != segCode {
+			continue
+		}
+		for w := 0; w < a.sizeOf(s); w++ {
+			out = append(out, [2]int{s.addr + w, s.line})
+		}
+	}
+	return out
+}
+
+// Symbols returns every defined symbol — labels, .set names, and the
+// synthetic name.frame entries — in definition order. The -syms dump
+// the debugger loads is built from it.
+func (a *Assembler) Symbols() []Symbol {
+	var out []Symbol
+	for _, s := range a.st.entries {
+		if s.Defined {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// spaceSize validates .space in pass1. Like a .func frame size, the
+// count must be a literal number so addresses can be assigned without
+// forward references.
+func (a *Assembler) spaceSize(s *stmt) int {
+	if s.seg != segData {
+		a.errorf(s.line, ".space is only allowed in the data segment")
+		return 0
+	}
+	if len(s.opnds) != 1 || s.opnds[0].kind != opExpr ||
+		!isNumber(s.opnds[0].text) || s.opnds[0].neg {
+		a.errorf(s.line, ".space needs a literal byte count")
+		return 0
+	}
+	v, ok := a.eval(s.opnds[0], s.line)
+	if !ok {
+		return 0
+	}
+	if v <= 0 || v > 0xFFFF {
+		a.errorf(s.line, ".space count %d out of range 1..65535", v)
+		return 0
+	}
+	return v
+}
+
+// pass2 encodes every statement into the output image.
+func (a *Assembler) pass2() *obj.Image {
+	img := &obj.Image{IsaRev: uint16(a.isaRev)}
+	for i := range a.stmts {
+		s := &a.stmts[i]
+		switch s.op {
+		case "", ".code", ".data", ".set":
+		case ".func":
+			a.encodePrologue(img, s)
+		case ".endfunc":
+			a.encodeEpilogue(img, s)
+		case ".word", ".byte", ".string":
+			a.encodeData(img, s)
+		case ".space":
+			// Reserved bytes are zero; just extend the image over them.
+			if s.size > 0 {
+				a.emitData(img, s.addr+s.size-1, 0)
+			}
+		default:
+			a.encodeInstr(img, s)
+		}
+	}
+	if v, ok := a.st.Lookup("_start"); ok {
+		img.Entry = uint16(v)
+	}
+	if a.eng.Failed() {
+		return nil
+	}
+	return img
+}
+
+func (a *Assembler) emitCode(img *obj.Image, addr int, w uint16) {
+	for len(img.Code) <= addr {
+		img.Code = append(img.Code, 0)
+	}
+	img.Code[addr] = w
+}
+
+func (a *Assembler) emitData(img *obj.Image, addr int, b byte) {
+	for len(img.Data) <= addr {
+		img.Data = append(img.Data, 0)
+	}
+	img.Data[addr] = b
+}
+
+func (a *Assembler) encodeData(img *obj.Image, s *stmt) {
+	addr := s.addr
+	for _, o := range s.opnds {
+		switch {
+		case s.op == ".string":
+			if o.kind != opString {
+				a.errorf(s.line, ".string needs string operands")
+				return
+			}
+			if s.seg == segCode {
+				a.errorf(s.line, ".string is only allowed in the data segment")
+				return
+			}
+			for _, c := range []byte(o.text) {
+				a.emitData(img, addr, c)
+				addr++
+			}
+		default:
+			v, ok := a.eval(o, s.line)
+			if !ok {
+				return
+			}
+			if s.op == ".byte" {
+				if s.seg == segCode {
+					a.errorf(s.line, ".byte is only allowed in the data segment")
+					return
+				}
+				if v < -128 || v > 255 {
+					a.errorf(s.line, "byte value %d out of range", v)
+					return
+				}
+				a.emitData(img, addr, byte(v))
+				addr++
+			} else { // .word
+				if v < -32768 || v > 65535 {
+					a.errorf(s.line, "word value %d out of range", v)
+					return
+				}
+				if s.seg == segCode {
+					a.emitCode(img, addr, uint16(v))
+					addr++
+				} else {
+					a.emitData(img, addr, byte(v))
+					a.emitData(img, addr+1, byte(v>>8))
+					addr += 2
+				}
+			}
+		}
+	}
+}
+
+// eval resolves an expression operand to a value. On pass two every
+// symbol must be defined.
+func (a *Assembler) eval(o operand, line int) (int, bool) {
+	var v int
+	if isNumber(o.text) {
+		n, err := strconv.ParseInt(o.text, 0, 32)
+		if err != nil {
+			a.errorf(line, "bad number %q", o.text)
+			return 0, false
+		}
+		v = int(n)
+	} else {
+		var ok bool
+		v, ok = a.st.Lookup(o.text)
+		if !ok {
+			a.errorf(line, "undefined symbol %q", o.text)
+			return 0, false
+		}
+	}
+	// Negation applies after resolution, so -label and negated .set
+	// values work even for forward references; the per-field range
+	// checks in the encoders then see the negated value.
+	if o.neg {
+		v = -v
+	}
+	return v, true
+}
+
+func isNumber(s string) bool { return len(s) > 0 && s[0] >= '0' && s[0] <= '9' }
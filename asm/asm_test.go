@@ -0,0 +1,273 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/lex"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+func assemble(t *testing.T, src string) (*obj.Image, []string) {
+	t.Helper()
+	img, eng := NewAssembler().Assemble([]byte(src))
+	return img, eng.Messages()
+}
+
+func mustAssemble(t *testing.T, src string) *obj.Image {
+	t.Helper()
+	img, errs := assemble(t, src)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	return img
+}
+
+// A negated forward reference must resolve to the twos complement of
+// the label's address.
+func TestNegatedForwardReference(t *testing.T) {
+	img := mustAssemble(t, `
+	li r2, -tail
+	nop
+tail:
+	hlt
+`)
+	// li is lui+adi; tail is at word 3, so -tail is 0xFFFD.
+	want := uint16(0xFFFD)
+	lo := int(want & 0x3F)
+	hi := int(want >> 6)
+	if img.Code[0] != isa.EncodeLui(2, hi) || img.Code[1] != isa.EncodeMem(isa.OpAdi, 2, 2, lo) {
+		t.Errorf("li r2, -tail encoded as 0x%04X 0x%04X", img.Code[0], img.Code[1])
+	}
+}
+
+func TestNegatedSetValue(t *testing.T) {
+	img := mustAssemble(t, `
+.set off, 8
+	adi r1, r0, -off
+	hlt
+`)
+	if img.Code[0] != isa.EncodeMem(isa.OpAdi, 1, 0, -8) {
+		t.Errorf("adi r1, r0, -off encoded as 0x%04X", img.Code[0])
+	}
+}
+
+// .space reserves zero bytes and keeps later data at the right
+// address.
+func TestSpace(t *testing.T) {
+	img := mustAssemble(t, `
+	hlt
+.data
+buf:
+	.space 16
+mark:
+	.byte 0xAA
+`)
+	if len(img.Data) != 17 || img.Data[16] != 0xAA {
+		t.Errorf("data = % X, want 16 zero bytes then 0xAA", img.Data)
+	}
+	for _, bad := range []string{".space 16", ".data\n.space label", ".data\n.space 0"} {
+		if _, errs := assemble(t, bad); len(errs) == 0 {
+			t.Errorf("%q assembled without error", bad)
+		}
+	}
+}
+
+// The line map covers every code word, with multi-word statements
+// mapping all their words to one source line.
+func TestLineMap(t *testing.T) {
+	a := NewAssembler()
+	if _, eng := a.Assemble([]byte("\tnop\n\tli r2, 0x1234\n\thlt\n")); eng.Failed() {
+		t.Fatalf("unexpected errors: %v", eng.Messages())
+	}
+	got := a.LineMap()
+	want := [][2]int{{0, 1}, {1, 2}, {2, 2}, {3, 3}}
+	if len(got) != len(want) {
+		t.Fatalf("line map %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("line map %v, want %v", got, want)
+		}
+	}
+}
+
+// Negated values are still subject to the field range checks.
+func TestNegatedValueRangeCheck(t *testing.T) {
+	_, errs := assemble(t, `
+.set big, 100
+	adi r1, r0, -big
+`)
+	if len(errs) != 1 || !strings.Contains(errs[0], "out of range") {
+		t.Errorf("expected one range error, got %v", errs)
+	}
+}
+
+// Symbols must report labels, .set names, and the synthetic
+// name.frame entries in definition order.
+func TestSymbols(t *testing.T) {
+	a := NewAssembler()
+	src := "\t.set limit, 60\n\t.func main, 4\n\thlt\n\t.endfunc\nbuf:\n"
+	if _, eng := a.Assemble([]byte(src)); eng.Failed() {
+		t.Fatalf("unexpected errors: %v", eng.Messages())
+	}
+	got := make(map[string]int)
+	for _, s := range a.Symbols() {
+		got[s.Name] = s.Value
+	}
+	want := map[string]int{"limit": 60, "main": 0, "main.frame": 4}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("symbol %s = %d, want %d", name, got[name], v)
+		}
+	}
+}
+
+// lui normally takes the ten-bit field value; a 64-aligned constant
+// above the field is the special case encoded as the field that loads
+// it, and an unaligned one is an explicit error.
+func TestLuiConstantSpecialCase(t *testing.T) {
+	img := mustAssemble(t, "\tlui r2, 0x1000\n\thlt\n")
+	if img.Code[0] != isa.EncodeLui(2, 0x1000>>6) {
+		t.Errorf("lui r2, 0x1000 encoded as 0x%04X", img.Code[0])
+	}
+	_, errs := assemble(t, "\tlui r2, 0x1001\n")
+	if len(errs) != 1 || !strings.Contains(errs[0], "multiple of 64") {
+		t.Errorf("expected the alignment error, got %v", errs)
+	}
+}
+
+// Negative immediates in unsigned fields get the explicit rule, not
+// the bare range message.
+func TestUnsignedFieldNegative(t *testing.T) {
+	_, errs := assemble(t, "\tjlr r1, r2, -1\n")
+	if len(errs) != 1 || !strings.Contains(errs[0], "unsigned") {
+		t.Errorf("expected the unsigned-field error, got %v", errs)
+	}
+}
+
+// An instruction newer than the -isa target is an assembly error, so
+// a build for revision 1 hardware cannot silently emit revision 2
+// encodings; the target revision is stamped into the image header.
+func TestIsaRevisionGate(t *testing.T) {
+	a := NewAssembler()
+	a.SetIsaRev(1)
+	_, eng := a.Assemble([]byte("\ttas r2, r3\n"))
+	errs := eng.Messages()
+	if len(errs) != 1 || !strings.Contains(errs[0], "revision 2") {
+		t.Errorf("expected the revision error, got %v", errs)
+	}
+	img := mustAssemble(t, "\ttas r2, r3\n\thlt\n")
+	if img.IsaRev != isa.Version {
+		t.Errorf("image ISA revision = %d, want %d", img.IsaRev, isa.Version)
+	}
+}
+
+// The statistics distinguish real instructions from pseudo
+// expansions and data-in-code, and span only what was emitted.
+func TestStats(t *testing.T) {
+	a := NewAssembler()
+	a.Assemble([]byte(`
+	li r2, 0x1234
+	adi r3, r0, 1
+	hlt
+	.word 0xFFFF
+	.data
+buf:	.byte 1, 2, 3
+`))
+	st := a.Stats()
+	if st.Instructions != 4 || st.Pseudos != 1 {
+		t.Errorf("instructions=%d pseudos=%d, want 4 and 1", st.Instructions, st.Pseudos)
+	}
+	if st.CodeWords != 5 || st.CodeLo != 0 || st.CodeHi != 4 {
+		t.Errorf("code-words=%d span=%d-%d, want 5 over 0-4", st.CodeWords, st.CodeLo, st.CodeHi)
+	}
+	if st.DataBytes != 3 || st.DataLo != 0 || st.DataHi != 2 {
+		t.Errorf("data-bytes=%d span=%d-%d, want 3 over 0-2", st.DataBytes, st.DataLo, st.DataHi)
+	}
+	if st.Symbols != 1 {
+		t.Errorf("symbols=%d, want 1", st.Symbols)
+	}
+}
+
+// Under KeepComments the parser attaches a comment block to the
+// following statement and a trailing comment to its own line, with
+// source positions intact; a block dangling at end of file becomes
+// a statement of its own. The binary is unaffected.
+func TestCommentAttachment(t *testing.T) {
+	a := NewAssembler()
+	a.KeepComments = true
+	img, eng := a.Assemble([]byte(`; one
+; two
+loop:	adi r2, r0, 1	; bump
+	hlt
+; dangling
+`))
+	if eng.Failed() {
+		t.Fatalf("unexpected errors: %v", eng.Messages())
+	}
+	if len(a.stmts) != 3 {
+		t.Fatalf("got %d stmts, want 3", len(a.stmts))
+	}
+	s := a.stmts[0]
+	if len(s.comments) != 2 || s.comments[0].Text != " one" || s.comments[1].Line != 2 {
+		t.Errorf("leading comments = %+v", s.comments)
+	}
+	if s.trailing.Kind != lex.TkComment || s.trailing.Text != " bump" || s.trailing.Line != 3 {
+		t.Errorf("trailing = %+v", s.trailing)
+	}
+	if a.stmts[1].trailing.Kind != 0 || len(a.stmts[1].comments) != 0 {
+		t.Errorf("hlt stmt has comments: %+v", a.stmts[1])
+	}
+	d := a.stmts[2]
+	if d.op != "" || len(d.comments) != 1 || d.comments[0].Text != " dangling" {
+		t.Errorf("dangling comment stmt = %+v", d)
+	}
+	if len(img.Code) != 2 {
+		t.Errorf("code length %d, want 2", len(img.Code))
+	}
+
+	// With the option off nothing is attached and nothing changes.
+	a = NewAssembler()
+	if img, _ := a.Assemble([]byte("; x\n\thlt ; y\n")); len(img.Code) != 1 {
+		t.Errorf("comments leaked into the image")
+	}
+	if len(a.stmts) != 1 || len(a.stmts[0].comments) != 0 || a.stmts[0].trailing.Kind != 0 {
+		t.Errorf("comments attached without KeepComments: %+v", a.stmts)
+	}
+}
+
+// The symbol policy: names are case-sensitive; register names are
+// unusable as symbols and are errors; mnemonic shadowing and
+// case-only near-duplicates are warnings, not errors.
+func TestSymbolPolicy(t *testing.T) {
+	// Case sensitivity is pinned: LOOP does not resolve loop.
+	_, errs := assemble(t, "loop:\thlt\n\tjmp LOOP\n")
+	if len(errs) != 1 || !strings.Contains(errs[0], "never defined") {
+		t.Errorf("case-variant use: errors = %v", errs)
+	}
+
+	// A register name as a label is an error.
+	_, errs = assemble(t, "sp:\thlt\n")
+	if len(errs) != 1 || !strings.Contains(errs[0], "shadows a register name") {
+		t.Errorf("register label: errors = %v", errs)
+	}
+
+	// Shadowing a mnemonic warns but assembles.
+	a := NewAssembler()
+	img, eng := a.Assemble([]byte("add:\thlt\n"))
+	if img == nil || eng.Failed() || eng.WarnCount() != 1 {
+		t.Errorf("mnemonic label: failed=%v warns=%d", eng.Failed(), eng.WarnCount())
+	}
+
+	// So does a pair of definitions differing only in case.
+	a = NewAssembler()
+	img, eng = a.Assemble([]byte("Loop:\thlt\nloop:\thlt\n\tjmp Loop\n\tjmp loop\n"))
+	if img == nil || eng.Failed() || eng.WarnCount() != 1 {
+		t.Errorf("case pair: failed=%v warns=%d msgs=%v", eng.Failed(), eng.WarnCount(), eng.Messages())
+	}
+	if eng.WarnCount() == 1 && !strings.Contains(eng.Messages()[0], "only in case") {
+		t.Errorf("case pair warning = %v", eng.Messages())
+	}
+}
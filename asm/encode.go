@@ -0,0 +1,325 @@
+// Instruction encoding for pass two: operand checking, immediate
+// range checking, and pseudo-instruction expansion.
+
+package main
+
+import (
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+func (a *Assembler) encodeInstr(img *obj.Image, s *stmt) {
+	if s.seg != segCode {
+		a.errorf(s.line, "instruction %q in the data segment", s.op)
+		return
+	}
+	if a.encodePseudo(img, s) {
+		return
+	}
+	d := isa.ByMnemonic(s.op)
+	if d == nil {
+		a.errorf(s.line, "unknown instruction or directive %q", s.op)
+		return
+	}
+	if rev := isa.MinRev(d); rev > a.isaRev {
+		a.errorf(s.line, "%s requires ISA revision %d but the target is revision %d",
+			s.op, rev, a.isaRev)
+		return
+	}
+
+	switch d.Fmt {
+	case isa.FmtMem, isa.FmtAdi:
+		ra, rb, ok := a.twoRegs(s, 3)
+		if !ok {
+			return
+		}
+		imm, ok := a.immOperand(s, 2, -64, 63)
+		if !ok {
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeMem(d.Op, ra, rb, imm))
+	case isa.FmtBranch:
+		ra, rb, ok := a.twoRegs(s, 3)
+		if !ok {
+			return
+		}
+		disp, ok := a.branchDisp(s, 2, s.addr+1)
+		if !ok {
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeMem(d.Op, ra, rb, disp))
+	case isa.FmtLui:
+		ra, ok := a.regOperand(s, 0, 2)
+		if !ok {
+			return
+		}
+		imm, ok := a.immOperand(s, 1, 0, 0xFFFF)
+		if !ok {
+			return
+		}
+		// Special case: lui's operand is normally the ten-bit field
+		// value, but a constant above the field (an address from the
+		// linker, say) is accepted when its low six bits are zero and
+		// encoded as the field that loads it.
+		if imm > 0x3FF {
+			if imm&0x3F != 0 {
+				a.errorf(s.line,
+					"lui: %d is neither a field value 0..1023 nor a multiple of 64", imm)
+				return
+			}
+			imm >>= 6
+		}
+		a.emitCode(img, s.addr, isa.EncodeLui(ra, imm))
+	case isa.FmtXop:
+		ra, rb, ok := a.twoRegs(s, 2)
+		if !ok {
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeXop(d.Xop, ra, rb))
+	case isa.FmtJlr:
+		ra, rb, ok := a.twoRegs(s, 3)
+		if !ok {
+			return
+		}
+		imm, ok := a.immOperand(s, 2, 0, 15)
+		if !ok {
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeJlr(ra, rb, imm))
+	case isa.FmtSpr:
+		ra, ok := a.regOperand(s, 0, 2)
+		if !ok {
+			return
+		}
+		spr, ok := a.immOperand(s, 1, 0, isa.NumSpr-1)
+		if !ok {
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeSpr(d.Yop, ra, spr))
+	case isa.FmtIo:
+		ra, rb, ok := a.twoRegs(s, 2)
+		if !ok {
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeIo(d.Yop, ra, rb))
+	case isa.FmtSys:
+		if len(s.opnds) != 1 {
+			a.errorf(s.line, "sys needs one immediate operand")
+			return
+		}
+		imm, ok := a.immOperand(s, 0, 0, 63)
+		if !ok {
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeSys(imm))
+	case isa.FmtShi:
+		ra, ok := a.regOperand(s, 0, 2)
+		if !ok {
+			return
+		}
+		n, ok := a.immOperand(s, 1, 0, 7)
+		if !ok {
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeShi(d.Yop, ra, n))
+	case isa.FmtYop:
+		ra, ok := a.regOperand(s, 0, 1)
+		if !ok {
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeYop(d.Yop, ra))
+	case isa.FmtVop:
+		if len(s.opnds) != 0 {
+			a.errorf(s.line, "%s takes no operands", s.op)
+			return
+		}
+		a.emitCode(img, s.addr, isa.EncodeVop(d.Vop))
+	}
+}
+
+// encodePseudo expands the assembler's pseudo-instructions. It
+// returns false if s.op is not a pseudo.
+func (a *Assembler) encodePseudo(img *obj.Image, s *stmt) bool {
+	switch s.op {
+	case "nop":
+		a.emitCode(img, s.addr, isa.EncodeMem(isa.OpAdi, 0, 0, 0))
+	case "mov":
+		ra, rb, ok := a.twoRegs(s, 2)
+		if !ok {
+			return true
+		}
+		a.emitCode(img, s.addr, isa.EncodeMem(isa.OpAdi, ra, rb, 0))
+	case "rtl":
+		// Return through the conventional link register r1.
+		a.emitCode(img, s.addr, isa.EncodeJlr(0, 1, 0))
+	case "jmp":
+		disp, ok := a.branchDisp(s, 0, s.addr+1)
+		if !ok {
+			return true
+		}
+		a.emitCode(img, s.addr, isa.EncodeMem(isa.OpBeq, 0, 0, disp))
+	case "li":
+		// Load a full 16-bit immediate: lui for the top ten bits,
+		// adi for the bottom six. Always two words so pass one can
+		// size it without evaluating the operand.
+		ra, ok := a.regOperand(s, 0, 2)
+		if !ok {
+			return true
+		}
+		v, ok := a.immOperand(s, 1, -32768, 65535)
+		if !ok {
+			return true
+		}
+		u := uint16(v)
+		a.emitCode(img, s.addr, isa.EncodeLui(ra, int(u>>6)))
+		a.emitCode(img, s.addr+1, isa.EncodeMem(isa.OpAdi, ra, ra, int(u&0x3F)))
+	case "push":
+		// Stacks grow downward through the designated stack
+		// register r6 (alias sp).
+		ra, ok := a.regOperand(s, 0, 1)
+		if !ok {
+			return true
+		}
+		a.emitCode(img, s.addr, isa.EncodeMem(isa.OpAdi, 6, 6, -2))
+		a.emitCode(img, s.addr+1, isa.EncodeMem(isa.OpStw, ra, 6, 0))
+	case "pop":
+		ra, ok := a.regOperand(s, 0, 1)
+		if !ok {
+			return true
+		}
+		a.emitCode(img, s.addr, isa.EncodeMem(isa.OpLdw, ra, 6, 0))
+		a.emitCode(img, s.addr+1, isa.EncodeMem(isa.OpAdi, 6, 6, 2))
+	case "bne":
+		// Branch if not equal: skip over an unconditional branch.
+		ra, rb, ok := a.twoRegs(s, 3)
+		if !ok {
+			return true
+		}
+		disp, ok := a.branchDisp(s, 2, s.addr+2)
+		if !ok {
+			return true
+		}
+		a.emitCode(img, s.addr, isa.EncodeMem(isa.OpBeq, ra, rb, 1))
+		a.emitCode(img, s.addr+1, isa.EncodeMem(isa.OpBeq, 0, 0, disp))
+	default:
+		return false
+	}
+	return true
+}
+
+// The WUT-4 ABI, generated by .func/.endfunc: r1 (lnk) holds the
+// return address, r6 (sp) the full descending stack pointer, and r7
+// is the assembler temporary, clobbered by prologues with frames
+// larger than 63 bytes. The prologue pushes lnk and allocates the
+// frame; locals live at sp+0..frame-1 and the saved link at sp+frame.
+
+// frameAdjustWords is the code size of one sp adjustment by frame
+// bytes: nothing for an empty frame, one adi when the size fits its
+// immediate, else a li/sub (or li/add) triple through r7.
+func frameAdjustWords(frame int) int {
+	switch {
+	case frame == 0:
+		return 0
+	case frame <= 63:
+		return 1
+	default:
+		return 3
+	}
+}
+
+func (a *Assembler) encodePrologue(img *obj.Image, s *stmt) {
+	addr := s.addr
+	a.emitCode(img, addr, isa.EncodeMem(isa.OpAdi, 6, 6, -2)) // push lnk
+	a.emitCode(img, addr+1, isa.EncodeMem(isa.OpStw, 1, 6, 0))
+	addr += 2
+	switch {
+	case s.frame == 0:
+	case s.frame <= 63:
+		a.emitCode(img, addr, isa.EncodeMem(isa.OpAdi, 6, 6, -s.frame))
+	default:
+		u := uint16(s.frame)
+		a.emitCode(img, addr, isa.EncodeLui(7, int(u>>6)))
+		a.emitCode(img, addr+1, isa.EncodeMem(isa.OpAdi, 7, 7, int(u&0x3F)))
+		a.emitCode(img, addr+2, isa.EncodeXop(isa.XopSub, 6, 7))
+	}
+}
+
+func (a *Assembler) encodeEpilogue(img *obj.Image, s *stmt) {
+	addr := s.addr
+	switch {
+	case s.frame == 0:
+	case s.frame <= 63:
+		a.emitCode(img, addr, isa.EncodeMem(isa.OpAdi, 6, 6, s.frame))
+		addr++
+	default:
+		u := uint16(s.frame)
+		a.emitCode(img, addr, isa.EncodeLui(7, int(u>>6)))
+		a.emitCode(img, addr+1, isa.EncodeMem(isa.OpAdi, 7, 7, int(u&0x3F)))
+		a.emitCode(img, addr+2, isa.EncodeXop(isa.XopAdd, 6, 7))
+		addr += 3
+	}
+	a.emitCode(img, addr, isa.EncodeMem(isa.OpLdw, 1, 6, 0)) // pop lnk
+	a.emitCode(img, addr+1, isa.EncodeMem(isa.OpAdi, 6, 6, 2))
+	a.emitCode(img, addr+2, isa.EncodeJlr(0, 1, 0)) // rtl
+}
+
+// Operand helpers. Each reports errors itself and returns ok=false.
+
+func (a *Assembler) regOperand(s *stmt, i, want int) (int, bool) {
+	if len(s.opnds) != want || s.opnds[i].kind != opReg {
+		a.errorf(s.line, "%s: expected register operand %d", s.op, i+1)
+		return 0, false
+	}
+	return s.opnds[i].reg, true
+}
+
+func (a *Assembler) twoRegs(s *stmt, want int) (int, int, bool) {
+	if len(s.opnds) != want || s.opnds[0].kind != opReg || s.opnds[1].kind != opReg {
+		a.errorf(s.line, "%s: expected %d operands starting with two registers", s.op, want)
+		return 0, 0, false
+	}
+	return s.opnds[0].reg, s.opnds[1].reg, true
+}
+
+func (a *Assembler) immOperand(s *stmt, i, lo, hi int) (int, bool) {
+	if i >= len(s.opnds) || s.opnds[i].kind != opExpr {
+		a.errorf(s.line, "%s: expected immediate operand %d", s.op, i+1)
+		return 0, false
+	}
+	v, ok := a.eval(s.opnds[i], s.line)
+	if !ok {
+		return 0, false
+	}
+	if v < lo || v > hi {
+		// Unsigned fields (jlr, lui, sys, spr, shift counts) reject
+		// negatives explicitly: the hardware field has no sign bit,
+		// and silently taking the low bits would jump or trap wild.
+		if lo == 0 && v < 0 {
+			a.errorf(s.line, "%s: immediate %d is negative but the field is unsigned 0..%d",
+				s.op, v, hi)
+			return 0, false
+		}
+		a.errorf(s.line, "%s: immediate %d out of range %d..%d", s.op, v, lo, hi)
+		return 0, false
+	}
+	return v, true
+}
+
+// branchDisp evaluates operand i as a code address and returns its
+// displacement from 'from', checking the imm7 range.
+func (a *Assembler) branchDisp(s *stmt, i, from int) (int, bool) {
+	if i >= len(s.opnds) || s.opnds[i].kind != opExpr {
+		a.errorf(s.line, "%s: expected branch target", s.op)
+		return 0, false
+	}
+	v, ok := a.eval(s.opnds[i], s.line)
+	if !ok {
+		return 0, false
+	}
+	disp := v - from
+	if disp < -64 || disp > 63 {
+		a.errorf(s.line, "%s: branch target out of range (displacement %d)", s.op, disp)
+		return 0, false
+	}
+	return disp, true
+}
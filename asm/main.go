@@ -0,0 +1,163 @@
+// asm is the WUT-4 assembler. It translates one assembly source file
+// into a WUT4 format binary.
+//
+// Output is reproducible: assembling the same source always produces
+// a byte-identical binary. Symbols live in insertion-ordered tables
+// (never bare maps), padding bytes are always zero, and nothing
+// timestamped enters the output. itf checks this on every test case.
+//
+// Usage: asm [-o out.bin] source.s
+//
+// With -tokens the assembler stops after lexing and emits the token
+// stream as JSON on stdout, one object per token with its kind name,
+// text, and position. This doubles as a lexer debugging aid and as a
+// backend for simple editor highlighters.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/lex"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+var outFile = flag.String("o", "", "output file (default source with .bin suffix)")
+var maxErrs = flag.Int("maxerrs", 20, "maximum errors to report; 0 for no limit")
+var colorize = flag.Bool("color", false, "colorize diagnostics")
+var jsonDiags = flag.Bool("json", false, "emit diagnostics as JSON")
+var tokens = flag.Bool("tokens", false, "dump the token stream as JSON and exit")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+var lineFile = flag.String("lines", "", "write an address-to-source-line map to this file")
+var elfOut = flag.Bool("elf", false, "write an ELF container instead of WUT4 format")
+var symsFile = flag.String("syms", "", "write a symbol table dump to this file")
+var isaRev = flag.Int("isa", isa.Version, "ISA revision to target")
+var stats = flag.Bool("stats", false, "print an assembly summary on stderr")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("asm isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: asm [-o out.bin] source.s")
+		os.Exit(2)
+	}
+	src := flag.Arg(0)
+	b, err := os.ReadFile(src)
+	if err != nil {
+		fatal("%v", err)
+	}
+
+	if *tokens {
+		if err := dumpTokens(os.Stdout, b); err != nil {
+			fatal("%v", err)
+		}
+		return
+	}
+
+	a := NewAssembler()
+	if *isaRev < 1 || *isaRev > isa.Version {
+		fatal("-isa %d: revisions are 1..%d", *isaRev, isa.Version)
+	}
+	a.SetIsaRev(*isaRev)
+	eng := a.Diags()
+	eng.File = src
+	eng.MaxErrs = *maxErrs
+	eng.Color = *colorize
+	img, _ := a.Assemble(b)
+	if eng.Failed() || eng.WarnCount() > 0 {
+		if *jsonDiags {
+			eng.EmitJSON(os.Stderr)
+		} else {
+			eng.Emit(os.Stderr)
+		}
+		if eng.Failed() {
+			os.Exit(1)
+		}
+	}
+
+	out := *outFile
+	if out == "" {
+		out = strings.TrimSuffix(src, ".s") + ".bin"
+	}
+	if *elfOut {
+		var syms []obj.Sym
+		for _, s := range a.Symbols() {
+			syms = append(syms, obj.Sym{Name: s.Name, Value: uint16(s.Value)})
+		}
+		if err := obj.WriteELF(out, img, syms); err != nil {
+			fatal("%v", err)
+		}
+	} else if err := img.Write(out); err != nil {
+		fatal("%v", err)
+	}
+	if *lineFile != "" {
+		var b strings.Builder
+		for _, e := range a.LineMap() {
+			fmt.Fprintf(&b, "%d %d\n", e[0], e[1])
+		}
+		if err := os.WriteFile(*lineFile, []byte(b.String()), 0644); err != nil {
+			fatal("%v", err)
+		}
+	}
+	if *symsFile != "" {
+		var b strings.Builder
+		for _, s := range a.Symbols() {
+			fmt.Fprintf(&b, "%s %d\n", s.Name, s.Value)
+		}
+		if err := os.WriteFile(*symsFile, []byte(b.String()), 0644); err != nil {
+			fatal("%v", err)
+		}
+	}
+	if *stats {
+		// One fixed-format line on stderr, matching func-stats, so
+		// scripts scrape it the same way. Spans are omitted for an
+		// empty segment rather than printed as a fiction.
+		st := a.Stats()
+		fmt.Fprintf(os.Stderr, "asm-stats: instructions=%d pseudos=%d code-words=%d data-bytes=%d symbols=%d warnings=%d",
+			st.Instructions, st.Pseudos, st.CodeWords, st.DataBytes, st.Symbols, st.Warnings)
+		if st.CodeWords > 0 {
+			fmt.Fprintf(os.Stderr, " code-span=0x%04X-0x%04X", st.CodeLo, st.CodeHi)
+		}
+		if st.DataBytes > 0 {
+			fmt.Fprintf(os.Stderr, " data-span=0x%04X-0x%04X", st.DataLo, st.DataHi)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// jsonToken is the wire form of one token in -tokens output. Comments
+// are kept so highlighters see the whole source.
+type jsonToken struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+}
+
+func dumpTokens(w *os.File, src []byte) error {
+	lx := lex.NewLexer(src)
+	lx.KeepComments = true
+	var toks []jsonToken
+	for {
+		t := lx.Next()
+		if t.Kind == lex.TkEOF {
+			break
+		}
+		toks = append(toks, jsonToken{Kind: t.Kind.Name(), Text: t.Text, Line: t.Line, Col: t.Col})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(toks)
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "asm: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,92 @@
+// The assembler's symbol table. Symbols are created either by labels
+// and .set directives (defined) or by uses that precede definition
+// (forward references, resolved on the second pass).
+//
+// Names are case-sensitive: Loop and loop are distinct symbols. The
+// table additionally tracks definitions case-folded so the assembler
+// can warn about pairs that differ only in case, which are legal
+// under the policy but almost always a typo.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Symbol struct {
+	Name    string
+	Value   int
+	Defined bool
+	UseLine int // source line of the first use, for diagnostics
+}
+
+// SymbolTable stores symbols in definition order; the index map gives
+// O(1) lookup by name.
+type SymbolTable struct {
+	entries []Symbol
+	index   map[string]int
+	fold    map[string]string // lowercased name -> first defined spelling
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{index: make(map[string]int), fold: make(map[string]string)}
+}
+
+// Define records a definition for name. Defining a name twice is an
+// error.
+func (st *SymbolTable) Define(name string, value int) error {
+	if _, ok := st.fold[strings.ToLower(name)]; !ok {
+		st.fold[strings.ToLower(name)] = name
+	}
+	if i, ok := st.index[name]; ok {
+		if st.entries[i].Defined {
+			return fmt.Errorf("symbol %q redefined", name)
+		}
+		st.entries[i].Value = value
+		st.entries[i].Defined = true
+		return nil
+	}
+	st.index[name] = len(st.entries)
+	st.entries = append(st.entries, Symbol{Name: name, Value: value, Defined: true})
+	return nil
+}
+
+// CaseVariant returns a previously defined symbol whose name differs
+// from name only in letter case, if there is one.
+func (st *SymbolTable) CaseVariant(name string) (string, bool) {
+	prev, ok := st.fold[strings.ToLower(name)]
+	return prev, ok && prev != name
+}
+
+// Use records a reference to name at the given source line, creating
+// an undefined entry if the name has not been seen yet. The entry in
+// the table is updated in place when the definition arrives later.
+func (st *SymbolTable) Use(name string, line int) {
+	if _, ok := st.index[name]; !ok {
+		st.index[name] = len(st.entries)
+		st.entries = append(st.entries, Symbol{Name: name, UseLine: line})
+	}
+}
+
+// ResolveAll verifies that every symbol ever used has received a
+// definition, returning the symbols still missing one, in first-use
+// order. It runs between the passes so each missing name is reported
+// once rather than at every use.
+func (st *SymbolTable) ResolveAll() []*Symbol {
+	var missing []*Symbol
+	for i := range st.entries {
+		if !st.entries[i].Defined {
+			missing = append(missing, &st.entries[i])
+		}
+	}
+	return missing
+}
+
+// Lookup returns the value of name if it is defined.
+func (st *SymbolTable) Lookup(name string) (int, bool) {
+	if i, ok := st.index[name]; ok && st.entries[i].Defined {
+		return st.entries[i].Value, true
+	}
+	return 0, false
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// A definition arriving after uses must update the stored entry, not
+// a copy of it.
+func TestDefineAfterUse(t *testing.T) {
+	st := NewSymbolTable()
+	st.Use("late", 3)
+	if _, ok := st.Lookup("late"); ok {
+		t.Fatal("undefined symbol reported as defined")
+	}
+	if err := st.Define("late", 42); err != nil {
+		t.Fatal(err)
+	}
+	v, ok := st.Lookup("late")
+	if !ok || v != 42 {
+		t.Errorf("Lookup(late) = %d, %v; want 42, true", v, ok)
+	}
+	if errs := st.ResolveAll(); len(errs) != 0 {
+		t.Errorf("ResolveAll after definition: %v", errs)
+	}
+}
+
+func TestResolveAllReportsUndefined(t *testing.T) {
+	st := NewSymbolTable()
+	st.Use("ghost", 7)
+	st.Use("ghost", 9) // only the first use is reported
+	if err := st.Define("real", 1); err != nil {
+		t.Fatal(err)
+	}
+	missing := st.ResolveAll()
+	if len(missing) != 1 {
+		t.Fatalf("ResolveAll: %v, want one symbol", missing)
+	}
+	if missing[0].Name != "ghost" || missing[0].UseLine != 7 {
+		t.Errorf("ResolveAll reported %q at line %d, want ghost at line 7",
+			missing[0].Name, missing[0].UseLine)
+	}
+}
+
+func TestRedefinitionRejected(t *testing.T) {
+	st := NewSymbolTable()
+	if err := st.Define("x", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Define("x", 2); err == nil {
+		t.Error("redefinition of a defined symbol was accepted")
+	}
+}
+
+// End to end: an undefined symbol in a program is reported exactly
+// once, against its first use.
+func TestAssembleUndefinedSymbol(t *testing.T) {
+	_, errs := assemble(t, `
+	jmp nowhere
+	jmp nowhere
+	hlt
+`)
+	if len(errs) != 1 || !strings.Contains(errs[0], "nowhere") {
+		t.Errorf("expected a single undefined-symbol error, got %v", errs)
+	}
+}
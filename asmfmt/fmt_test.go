@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Exact column positions are implementation detail; these tests pin
+// the structural properties (label placement, comment preservation,
+// operand spacing, string re-escaping) and idempotence.
+func TestCanonicalForm(t *testing.T) {
+	in := "start:adi r1,r0 , 5;count\n;banner\n\n  hlt\nmsg:.string \"a\\nb\"\n"
+	got, err := Format([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(got, []byte("\n"))
+	if !bytes.HasPrefix(lines[0], []byte("start:")) || !bytes.Contains(lines[0], []byte("adi")) ||
+		!bytes.Contains(lines[0], []byte("r1, r0, 5")) || !bytes.Contains(lines[0], []byte(";count")) {
+		t.Errorf("line 0 = %q", lines[0])
+	}
+	if string(lines[1]) != ";banner" || string(lines[2]) != "" {
+		t.Errorf("comment/blank lines = %q %q", lines[1], lines[2])
+	}
+	if !bytes.Contains(lines[4], []byte(`.string "a\nb"`)) {
+		t.Errorf("string line = %q", lines[4])
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	in := "loop: ldw r2 ,r3,  -4 ; spin\n    bne r2,r0,loop\n.data\nx: .word 1, 2 ,3\n"
+	once, err := Format([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	twice, err := Format(once)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(once, twice) {
+		t.Errorf("not idempotent:\nonce:  %q\ntwice: %q", once, twice)
+	}
+}
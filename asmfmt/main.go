@@ -0,0 +1,187 @@
+// asmfmt is the canonical formatter for WUT-4 assembly, in the
+// spirit of gofmt: labels in column one, mnemonics indented and
+// padded to a fixed operand column, trailing comments aligned, and
+// comments preserved exactly. Formatting is idempotent.
+//
+// Usage: asmfmt [-w] file.s ...
+//
+// Without -w the formatted source is written to stdout.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gmofishsauce/y4/lex"
+)
+
+var write = flag.Bool("w", false, "write result back to the source file")
+
+const (
+	opCol      = 4  // column where mnemonics start (0-based)
+	operandCol = 12 // column where operands start
+	commentCol = 32 // column where trailing comments start
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: asmfmt [-w] file.s ...")
+		os.Exit(2)
+	}
+	status := 0
+	for _, path := range flag.Args() {
+		if err := formatFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "asmfmt: %v\n", err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func formatFile(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := Format(src)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if *write {
+		return os.WriteFile(path, out, 0644)
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// line is one source line regrouped from the token stream.
+type line struct {
+	label   string
+	toks    []lex.Token // mnemonic and operand tokens, in order
+	comment string
+	hasCmt  bool
+}
+
+// Format returns the canonical form of one assembly source file.
+func Format(src []byte) ([]byte, error) {
+	lx := lex.NewLexer(src)
+	lx.KeepComments = true
+
+	var out strings.Builder
+	cur := line{}
+	flush := func() {
+		out.WriteString(cur.render())
+		cur = line{}
+	}
+	for {
+		t := lx.Next()
+		switch t.Kind {
+		case lex.TkEOF:
+			if !cur.empty() {
+				flush()
+			}
+			return []byte(out.String()), nil
+		case lex.TkError:
+			return nil, fmt.Errorf("line %d:%d: %s", t.Line, t.Col, t.Text)
+		case lex.TkNewline:
+			flush()
+		case lex.TkComment:
+			cur.comment = t.Text
+			cur.hasCmt = true
+		case lex.TkSymbol:
+			if len(cur.toks) == 0 && cur.label == "" && lx.Peek(0).Kind == lex.TkColon {
+				cur.label = t.Text
+				lx.Next() // the colon
+				continue
+			}
+			cur.toks = append(cur.toks, t)
+		default:
+			cur.toks = append(cur.toks, t)
+		}
+	}
+}
+
+func (l *line) empty() bool {
+	return l.label == "" && len(l.toks) == 0 && !l.hasCmt
+}
+
+func (l *line) render() string {
+	var b strings.Builder
+	if l.label != "" {
+		b.WriteString(l.label)
+		b.WriteString(":")
+	}
+	if len(l.toks) > 0 {
+		pad(&b, opCol)
+		b.WriteString(l.toks[0].Text)
+		if len(l.toks) > 1 {
+			pad(&b, operandCol)
+			b.WriteString(renderOperands(l.toks[1:]))
+		}
+	}
+	if l.hasCmt {
+		if b.Len() > 0 {
+			pad(&b, commentCol)
+		}
+		b.WriteString(";")
+		b.WriteString(strings.TrimRight(l.comment, " \t"))
+	}
+	return strings.TrimRight(b.String(), " ") + "\n"
+}
+
+// renderOperands joins operand tokens with canonical spacing: commas
+// followed by one space, unary minus attached to its operand.
+func renderOperands(toks []lex.Token) string {
+	var b strings.Builder
+	for _, t := range toks {
+		switch t.Kind {
+		case lex.TkComma:
+			b.WriteString(", ")
+		case lex.TkMinus:
+			b.WriteString("-")
+		case lex.TkString:
+			b.WriteString(quote(t.Text))
+		default:
+			b.WriteString(t.Text)
+		}
+	}
+	return b.String()
+}
+
+// quote re-escapes string contents for re-emission.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case 0:
+			b.WriteString(`\0`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// pad advances b to the given column with spaces, or emits a single
+// space if already past it.
+func pad(b *strings.Builder, col int) {
+	cur := b.Len() - strings.LastIndexByte(b.String(), '\n') - 1
+	if cur >= col {
+		b.WriteByte(' ')
+		return
+	}
+	b.WriteString(strings.Repeat(" ", col-cur))
+}
@@ -0,0 +1,163 @@
+// Package diag is the diagnostics engine shared by the WUT-4 tools.
+// Tools accumulate positioned errors and warnings in an Engine and
+// emit them in one place, so asm, dis and the future yapl all produce
+// the same message format, the same sorted ordering, and the same
+// machine-readable output when scripts ask for it.
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+type Level int
+
+const (
+	Warning Level = iota
+	Error
+)
+
+func (l Level) String() string {
+	if l == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// A Diagnostic is one positioned message. Line and Col are 1-based;
+// zero means "not applicable" (for example, a file-level error).
+type Diagnostic struct {
+	Level Level  `json:"level"`
+	File  string `json:"file,omitempty"`
+	Line  int    `json:"line,omitempty"`
+	Col   int    `json:"col,omitempty"`
+	Msg   string `json:"msg"`
+}
+
+func (d Diagnostic) String() string {
+	s := ""
+	if d.File != "" {
+		s = d.File + ": "
+	}
+	switch {
+	case d.Line > 0 && d.Col > 0:
+		s += fmt.Sprintf("line %d:%d: ", d.Line, d.Col)
+	case d.Line > 0:
+		s += fmt.Sprintf("line %d: ", d.Line)
+	}
+	if d.Level == Warning {
+		s += "warning: "
+	}
+	return s + d.Msg
+}
+
+// Engine collects diagnostics for one input file.
+type Engine struct {
+	File    string
+	MaxErrs int  // emit at most this many errors; 0 means no limit
+	Color   bool // ANSI color in Emit
+	diags   []Diagnostic
+	nErrors int
+	nWarns  int
+}
+
+func NewEngine(file string) *Engine {
+	return &Engine{File: file}
+}
+
+func (e *Engine) add(level Level, line, col int, format string, args ...interface{}) {
+	e.diags = append(e.diags, Diagnostic{
+		Level: level, File: e.File, Line: line, Col: col,
+		Msg: fmt.Sprintf(format, args...),
+	})
+	if level == Error {
+		e.nErrors++
+	} else {
+		e.nWarns++
+	}
+}
+
+func (e *Engine) Errorf(line, col int, format string, args ...interface{}) {
+	e.add(Error, line, col, format, args...)
+}
+
+func (e *Engine) Warnf(line, col int, format string, args ...interface{}) {
+	e.add(Warning, line, col, format, args...)
+}
+
+// ErrorCount reports the number of errors (warnings excluded).
+func (e *Engine) ErrorCount() int { return e.nErrors }
+
+// WarnCount reports the number of warnings.
+func (e *Engine) WarnCount() int { return e.nWarns }
+
+// Failed reports whether any error was recorded.
+func (e *Engine) Failed() bool { return e.nErrors > 0 }
+
+// sorted returns the diagnostics in source order: by line, then
+// column, preserving insertion order for ties (file-level messages
+// first).
+func (e *Engine) sorted() []Diagnostic {
+	out := make([]Diagnostic, len(e.diags))
+	copy(out, e.diags)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Line != out[j].Line {
+			return out[i].Line < out[j].Line
+		}
+		return out[i].Col < out[j].Col
+	})
+	return out
+}
+
+// Messages returns the sorted diagnostics rendered as plain strings.
+func (e *Engine) Messages() []string {
+	var out []string
+	for _, d := range e.sorted() {
+		out = append(out, d.String())
+	}
+	return out
+}
+
+// Emit writes the sorted diagnostics to w, honoring MaxErrs and
+// Color. It returns the number of diagnostics written.
+func (e *Engine) Emit(w io.Writer) int {
+	n, errs := 0, 0
+	for _, d := range e.sorted() {
+		if d.Level == Error {
+			if errs++; e.MaxErrs > 0 && errs > e.MaxErrs {
+				fmt.Fprintf(w, "too many errors (%d not shown)\n", e.nErrors-e.MaxErrs)
+				return n
+			}
+		}
+		if e.Color {
+			fmt.Fprintf(w, "%s%s%s\n", colorFor(d.Level), d, colorReset)
+		} else {
+			fmt.Fprintf(w, "%s\n", d)
+		}
+		n++
+	}
+	return n
+}
+
+// EmitJSON writes the sorted diagnostics as a JSON array, one object
+// per diagnostic, for editor and CI consumption.
+func (e *Engine) EmitJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	diags := e.sorted()
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	return enc.Encode(diags)
+}
+
+const colorReset = "\x1b[0m"
+
+func colorFor(l Level) string {
+	if l == Warning {
+		return "\x1b[33m" // yellow
+	}
+	return "\x1b[31m" // red
+}
@@ -0,0 +1,63 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSortedEmission(t *testing.T) {
+	e := NewEngine("f.s")
+	e.Errorf(9, 1, "third")
+	e.Warnf(2, 7, "second")
+	e.Errorf(2, 3, "first")
+	got := e.Messages()
+	want := []string{
+		"f.s: line 2:3: first",
+		"f.s: line 2:7: warning: second",
+		"f.s: line 9:1: third",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if e.ErrorCount() != 2 || !e.Failed() {
+		t.Errorf("ErrorCount = %d, Failed = %v", e.ErrorCount(), e.Failed())
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	e := NewEngine("")
+	e.MaxErrs = 2
+	for i := 1; i <= 5; i++ {
+		e.Errorf(i, 0, "e%d", i)
+	}
+	var b bytes.Buffer
+	if n := e.Emit(&b); n != 2 {
+		t.Errorf("Emit wrote %d diagnostics, want 2", n)
+	}
+	if !strings.Contains(b.String(), "too many errors (3 not shown)") {
+		t.Errorf("missing truncation notice in %q", b.String())
+	}
+}
+
+func TestEmitJSON(t *testing.T) {
+	e := NewEngine("f.s")
+	e.Errorf(3, 4, "boom")
+	var b bytes.Buffer
+	if err := e.EmitJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	var out []Diagnostic
+	if err := json.Unmarshal(b.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Line != 3 || out[0].Col != 4 || out[0].Msg != "boom" {
+		t.Errorf("round trip: %+v", out)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// The li expansion condenses to one line by default and is annotated
+// but not rewritten under -literal; a lui followed by an unrelated
+// adi is left alone either way.
+func TestRenderIdioms(t *testing.T) {
+	code := []uint16{
+		isa.EncodeLui(2, int(0x1234>>6)),
+		isa.EncodeMem(isa.OpAdi, 2, 2, 0x1234&0x3F),
+		isa.EncodeLui(3, 1),
+		isa.EncodeMem(isa.OpAdi, 4, 0, 5), // different registers: no idiom
+		isa.EncodeVop(isa.VopHlt),
+	}
+	got := render(code, false, isa.Version)
+	if len(got) != 4 {
+		t.Fatalf("condensed render gave %d lines:\n%s", len(got), strings.Join(got, "\n"))
+	}
+	if !strings.Contains(got[0], "li r2, 0x1234") {
+		t.Errorf("pair line = %q", got[0])
+	}
+	if !strings.HasPrefix(got[1], "0x0002") || !strings.Contains(got[1], "lui r3") {
+		t.Errorf("line after pair = %q", got[1])
+	}
+
+	got = render(code, true, isa.Version)
+	if len(got) != len(code) {
+		t.Fatalf("literal render gave %d lines for %d words", len(got), len(code))
+	}
+	if !strings.Contains(got[0], "lui r2") || !strings.Contains(got[0], "li r2, 0x1234 idiom") {
+		t.Errorf("literal pair line = %q", got[0])
+	}
+	if strings.Contains(got[2], "idiom") {
+		t.Errorf("non-idiom annotated: %q", got[2])
+	}
+}
+
+// Instructions newer than the assumed revision render as .word in
+// both modes.
+func TestRenderIsaRev(t *testing.T) {
+	code := []uint16{isa.EncodeIo(isa.YopTas, 2, 3)}
+	got := render(code, false, 1)
+	if len(got) != 1 || !strings.Contains(got[0], ".word") || !strings.Contains(got[0], "tas") {
+		t.Errorf("rev-1 render = %q", got)
+	}
+}
+
+// Reserved encodings come out as reassemblable .word lines, not a
+// placeholder.
+func TestRenderUndecodable(t *testing.T) {
+	code := []uint16{isa.EncodeVop(63), isa.EncodeVop(isa.VopHlt)}
+	got := render(code, false, isa.Version)
+	if !strings.Contains(got[0], ".word 0xFBFF ; undecodable") {
+		t.Errorf("undecodable line = %q", got[0])
+	}
+	if strings.Contains(got[0], "?") || !strings.Contains(got[1], "hlt") {
+		t.Errorf("render = %q", got)
+	}
+}
+
+func TestTrimZero(t *testing.T) {
+	code := []uint16{1, 2, 0, 3}
+	if got := trimZero(code); len(got) != 2 {
+		t.Errorf("trimZero = %v", got)
+	}
+	if got := trimZero([]uint16{1, 2}); len(got) != 2 {
+		t.Errorf("no zero: trimZero = %v", got)
+	}
+}
@@ -0,0 +1,191 @@
+// dis is the WUT-4 disassembler. It prints the code segment of a
+// WUT4 format binary (or a raw word image) one instruction per line.
+//
+// Usage: dis [-literal] binary.bin
+//
+// By default assembler idioms are condensed: a lui+adi pair that
+// builds a full 16-bit constant — the li pseudo's expansion — prints
+// as one li line covering both words. That assumption misleads on
+// hand-built or fuzzer-generated binaries, where the pair may be two
+// deliberate instructions; -literal emits exactly one mnemonic per
+// word and instead annotates where an idiom was detected but left
+// unrewritten.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gmofishsauce/y4/diag"
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+	"github.com/gmofishsauce/y4/wire"
+)
+
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+var verify = flag.Bool("verify", false, "check the section checksums and exit")
+var isaRev = flag.Int("isa", isa.Version, "ISA revision to assume; newer instructions print as .word")
+var literal = flag.Bool("literal", false, "one mnemonic per word; annotate idioms instead of condensing")
+var all = flag.Bool("all", false, "disassemble a raw image to its end instead of stopping at a zero word")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("dis isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: dis binary.bin")
+		os.Exit(2)
+	}
+	if *verify {
+		os.Exit(doVerify(flag.Arg(0)))
+	}
+	code, raw, err := loadCode(flag.Arg(0))
+	if err != nil {
+		eng := diag.NewEngine("") // load errors already name the file
+		eng.Errorf(0, 0, "%v", err)
+		eng.Emit(os.Stderr)
+		os.Exit(1)
+	}
+	if raw && !*all {
+		// A WUT4 or ELF image is disassembled to exactly its
+		// header's code length, zero words and all. Only a raw image
+		// needs a heuristic end: dumps are zero-padded to their full
+		// extent, so stop at the first zero word (-all overrides).
+		if n := len(code); n != len(trimZero(code)) {
+			code = trimZero(code)
+			fmt.Fprintf(os.Stderr, "dis: raw image trimmed at first zero word 0x%04X (-all to keep %d more)\n",
+				len(code), n-len(code))
+		}
+	}
+	for _, line := range render(code, *literal, *isaRev) {
+		fmt.Println(line)
+	}
+}
+
+// render disassembles the code segment into output lines, applying
+// or annotating the li idiom per the -literal flag.
+func render(code []uint16, literal bool, isaRev int) []string {
+	var out []string
+	for addr := 0; addr < len(code); addr++ {
+		w := code[addr]
+		text := isa.Disassemble(w, addr)
+		if d := isa.Decode(w); d == nil {
+			// Reserved encoding space. Emit a reassemblable .word
+			// line (.word is legal in the code segment) rather than
+			// a placeholder, so round-tripping fuzzer output or data
+			// mixed into code needs no special casing.
+			text = fmt.Sprintf(".word 0x%04X ; undecodable", w)
+		} else if isa.MinRev(d) > isaRev {
+			// On the assumed revision this word is not an instruction.
+			text = fmt.Sprintf(".word 0x%04X ; %s is ISA revision %d", w, d.Mnemonic, isa.MinRev(d))
+		} else if d.Mnemonic == "sys" {
+			// The operand is relative to the trap base; the reader
+			// wants the cause the kernel will see.
+			text += fmt.Sprintf(" ; cause %d", isa.ExSysBase+isa.GetSys(w))
+		} else if ra, v, ok := liIdiom(code, addr); ok {
+			if literal {
+				text += fmt.Sprintf(" ; li r%d, 0x%04X idiom with next word", ra, v)
+			} else {
+				out = append(out, fmt.Sprintf("0x%04X  0x%04X  li r%d, 0x%04X", addr, w, ra, v))
+				addr++ // the adi word is covered by the li line
+				continue
+			}
+		}
+		out = append(out, fmt.Sprintf("0x%04X  0x%04X  %s", addr, w, text))
+	}
+	return out
+}
+
+// liIdiom reports whether the word at addr starts the assembler's li
+// expansion: lui ra, top ten bits, then adi ra, ra, bottom six.
+func liIdiom(code []uint16, addr int) (ra int, v uint16, ok bool) {
+	if addr+1 >= len(code) {
+		return 0, 0, false
+	}
+	w, next := code[addr], code[addr+1]
+	if isa.GetOp(w) != isa.OpLui || isa.GetOp(next) != isa.OpAdi {
+		return 0, 0, false
+	}
+	ra = isa.GetRa(w)
+	lo := isa.GetImm7(next)
+	if isa.GetRa(next) != ra || isa.GetRb(next) != ra || lo < 0 || lo > 63 {
+		return 0, 0, false
+	}
+	return ra, uint16(isa.GetImm10(w))<<6 | uint16(lo), true
+}
+
+// doVerify checks the stored section checksums against the payload
+// and reports both sections even when the first is bad, unlike a
+// load, which stops at the first mismatch. Only the native WUT4
+// format carries checksums.
+func doVerify(path string) int {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dis: %v\n", err)
+		return 1
+	}
+	if !obj.IsObject(b) {
+		fmt.Fprintf(os.Stderr, "dis: %s: not a WUT4 binary, no checksums to verify\n", path)
+		return 2
+	}
+	nCode := int(wire.Word(b[6:]))
+	nData := int(wire.Word(b[8:]))
+	if len(b) < obj.HeaderBytes+2*nCode+nData {
+		fmt.Fprintf(os.Stderr, "dis: %s: truncated (header claims %d code words, %d data bytes)\n",
+			path, nCode, nData)
+		return 1
+	}
+	stored, computed := obj.SectionCRCs(b)
+	status := 0
+	for i, name := range []string{"code", "data"} {
+		if stored[i] == computed[i] {
+			fmt.Printf("%s: %s crc 0x%08X ok\n", path, name, stored[i])
+		} else {
+			fmt.Printf("%s: %s crc BAD (header 0x%08X, computed 0x%08X)\n",
+				path, name, stored[i], computed[i])
+			status = 1
+		}
+	}
+	return status
+}
+
+func loadCode(path string) (code []uint16, raw bool, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if obj.IsObject(b) || obj.IsELF(b) {
+		var im *obj.Image
+		var err error
+		if obj.IsELF(b) {
+			im, err = obj.ReadELF(path)
+		} else {
+			im, err = obj.Read(path)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return im.Code, false, nil
+	}
+	if len(b)%2 != 0 {
+		return nil, false, fmt.Errorf("%s: odd length, not a word image", path)
+	}
+	code = make([]uint16, len(b)/2)
+	for i := range code {
+		code[i] = wire.Word(b[2*i:])
+	}
+	return code, true, nil
+}
+
+// trimZero returns the prefix before the first zero word.
+func trimZero(code []uint16) []uint16 {
+	for i, w := range code {
+		if w == 0 {
+			return code[:i]
+		}
+	}
+	return code
+}
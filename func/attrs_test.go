@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// encodeFor builds one executable word for a definition, with ra=2,
+// rb=3, and small immediates, so r3 can hold an address or port and
+// r2 a recognizable value.
+func encodeFor(d *isa.Def) uint16 {
+	switch d.Fmt {
+	case isa.FmtMem, isa.FmtBranch, isa.FmtAdi:
+		return isa.EncodeMem(d.Op, 2, 3, 0)
+	case isa.FmtLui:
+		return isa.EncodeLui(2, 5)
+	case isa.FmtXop:
+		return isa.EncodeXop(d.Xop, 2, 3)
+	case isa.FmtJlr:
+		return isa.EncodeJlr(2, 3, 0)
+	case isa.FmtYop:
+		return isa.EncodeYop(d.Yop, 2)
+	case isa.FmtSpr:
+		return isa.EncodeSpr(d.Yop, 2, isa.SprEpc)
+	case isa.FmtIo:
+		return isa.EncodeIo(d.Yop, 2, 3)
+	case isa.FmtShi:
+		return isa.EncodeShi(d.Yop, 2, 1)
+	case isa.FmtSys:
+		return isa.EncodeSys(1)
+	case isa.FmtVop:
+		return isa.EncodeVop(d.Vop)
+	}
+	return 0
+}
+
+// Every instruction's observed state changes must fall within its
+// declared isa.Attrs set, and the register and memory writers must
+// observably write with these operands. This is what keeps the
+// attribute table honest when an instruction is added.
+func TestAttrsMatchObservedEffects(t *testing.T) {
+	for i := range isa.Defs {
+		d := &isa.Defs[i]
+		attrs := isa.Attrs(d)
+		m := testMachine(encodeFor(d))
+		m.reg[2] = 0x1234
+		m.reg[3] = 0x0040
+		before := *m // copies reg, spr, pc; slices stay shared
+		dmem := bytes.Clone(m.dmem)
+		m.Step()
+
+		for r := 1; r < 8; r++ {
+			if r != 2 && m.reg[r] != before.reg[r] {
+				t.Errorf("%s: wrote r%d, which no instruction may", d.Mnemonic, r)
+			}
+		}
+		if got := m.reg[2] != before.reg[2]; got != (attrs&isa.AttrWritesRa != 0) {
+			t.Errorf("%s: ra changed=%v, AttrWritesRa=%v", d.Mnemonic, got, !got)
+		}
+		if got := !bytes.Equal(m.dmem, dmem); got != (attrs&isa.AttrWritesMem != 0) {
+			t.Errorf("%s: dmem changed=%v, AttrWritesMem=%v", d.Mnemonic, got, !got)
+		}
+		if m.pc != before.pc+1 && attrs&isa.AttrBranches == 0 {
+			t.Errorf("%s: pc went 0x%04X -> 0x%04X without AttrBranches",
+				d.Mnemonic, before.pc, m.pc)
+		}
+		carry := (m.spr[isa.SprPsw] ^ before.spr[isa.SprPsw]) & isa.PswC
+		if carry != 0 && attrs&isa.AttrSetsC == 0 {
+			t.Errorf("%s: carry changed without AttrSetsC", d.Mnemonic)
+		}
+		for n := 0; n < isa.NumSpr; n++ {
+			v, was := m.spr[n], before.spr[n]
+			if n == isa.SprPsw {
+				v, was = v&^isa.PswC, was&^isa.PswC
+			}
+			if v != was && attrs&isa.AttrWritesSpr == 0 {
+				t.Errorf("%s: wrote spr %d without AttrWritesSpr", d.Mnemonic, n)
+			}
+		}
+	}
+}
+
+// AttrPriv must agree with the emulator: every flagged instruction
+// is ExIllegal from user mode.
+func TestAttrPrivMatchesUserMode(t *testing.T) {
+	for i := range isa.Defs {
+		d := &isa.Defs[i]
+		if isa.Attrs(d)&isa.AttrPriv == 0 {
+			continue
+		}
+		m := testMachine(
+			encodeFor(d),
+			isa.EncodeVop(isa.VopHlt), // doubles as the ExIllegal vector
+		)
+		m.spr[isa.SprPsw] &^= isa.PswK
+		stepN(m, 10)
+		if got := m.spr[isa.SprCause]; got != isa.ExIllegal {
+			t.Errorf("%s: user-mode cause = %d, want ExIllegal", d.Mnemonic, got)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// The headless benchmark suite: func bench runs a few bundled WUT-4
+// workloads for a fixed cycle budget each and reports how fast the
+// host interprets them. The workloads are built directly from the
+// isa encoders so the emulator binary is self-contained; they loop
+// forever and the budget, not a halt, ends each run.
+//
+// The WUT-4 retires one instruction per cycle, so simulated MHz and
+// host MIPS are the same number today; both columns stay in the
+// table so the report format survives future timing modeling.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+type benchmark struct {
+	name string
+	prog []uint16
+}
+
+// benchmarks returns the bundled workloads: a Dhrystone-flavored
+// arithmetic loop, a byte memcpy loop, and a sieve of Eratosthenes
+// over a dmem byte array.
+func benchmarks() []benchmark {
+	adi := func(ra, rb, imm int) uint16 { return isa.EncodeMem(isa.OpAdi, ra, rb, imm) }
+	beq := func(ra, rb, imm int) uint16 { return isa.EncodeMem(isa.OpBeq, ra, rb, imm) }
+	return []benchmark{
+		{"loop", []uint16{
+			adi(1, 1, 1),                     // 0: r1++
+			isa.EncodeXop(isa.XopAdd, 2, 1),  // 1: r2 += r1
+			isa.EncodeXop(isa.XopXor, 3, 2),  // 2: r3 ^= r2
+			isa.EncodeShi(isa.YopLsri, 3, 1), // 3: r3 >>= 1
+			isa.EncodeXop(isa.XopSub, 2, 3),  // 4: r2 -= r3
+			beq(0, 0, -6),                    // 5: loop
+		}},
+		{"memcpy", []uint16{
+			adi(1, 0, 0),                       // 0: r1 = index
+			isa.EncodeMem(isa.OpLdb, 2, 1, 0),  // 1: r2 = src[r1]
+			isa.EncodeMem(isa.OpStb, 2, 1, 32), // 2: dst[r1] = r2 (dst = src+32)
+			adi(1, 1, 1),                       // 3: r1++
+			adi(3, 0, 32),                      // 4: r3 = 32
+			beq(1, 3, 1),                       // 5: done after 32 bytes
+			beq(0, 0, -6),                      // 6: next byte
+			beq(0, 0, -8),                      // 7: restart
+		}},
+		{"sieve", []uint16{
+			adi(1, 0, 2),                      // 0: r1 = candidate
+			adi(2, 1, 0),                      // 1: r2 = multiple (adds r1 below)
+			isa.EncodeXop(isa.XopAdd, 2, 1),   // 2: r2 += r1
+			adi(3, 0, 60),                     // 3: r3 = limit
+			isa.EncodeXop(isa.XopSlt, 3, 2),   // 4: r3 = limit < r2
+			adi(4, 0, 1),                      // 5: r4 = 1
+			beq(3, 4, 2),                      // 6: past limit: next candidate
+			isa.EncodeMem(isa.OpStb, 4, 2, 0), // 7: mark composite
+			beq(0, 0, -7),                     // 8: next multiple
+			adi(1, 1, 1),                      // 9: r1++
+			adi(3, 0, 8),                      // 10: r3 = 8
+			beq(1, 3, 1),                      // 11: swept to 8: restart
+			beq(0, 0, -12),                    // 12: next candidate
+			beq(0, 0, -14),                    // 13: restart
+		}},
+	}
+}
+
+// runBench executes every benchmark for the given cycle budget and
+// writes the timing table.
+func runBench(w io.Writer, cycles int) {
+	fmt.Fprintf(w, "%-8s %10s %8s %8s %8s\n", "name", "cycles", "seconds", "sim-MHz", "MIPS")
+	for _, b := range benchmarks() {
+		io := NewIoSystem()
+		io.AddDevice(&MathUnit{}, PortMathPresent, PortMathOpA, PortMathOpB,
+			PortMathProdLo, PortMathProdHi, PortMathQuot, PortMathRem)
+		m := NewMachine(io)
+		copy(m.imem[isa.VectorBase:], b.prog)
+		start := time.Now()
+		for i := 0; i < cycles && m.Step(); i++ {
+		}
+		secs := time.Since(start).Seconds()
+		rate := 0.0
+		if secs > 0 {
+			rate = float64(m.cycles) / secs / 1e6
+		}
+		fmt.Fprintf(w, "%-8s %10d %8.3f %8.1f %8.1f\n", b.name, m.cycles, secs, rate, rate)
+	}
+}
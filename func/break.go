@@ -0,0 +1,301 @@
+// Interactive break support. One SIGINT (Ctrl-C) suspends the run
+// loop between instructions into a minimal prompt showing the current
+// pc disassembled; a second SIGINT within two seconds gives up on the
+// prompt and exits with a final state dump, which covers the case
+// where the first one appeared to do nothing (stdin redirected, a
+// blocked device). The prompt is deliberately tiny — continue,
+// registers, quit — and is where a real debugger will hang off later.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// doubleTap is the window within which a second SIGINT means "stop
+// asking and exit".
+const doubleTap = 2 * time.Second
+
+type breaker struct {
+	m    *Machine
+	syms map[string]uint16 // from -syms, may be nil
+	req  atomic.Bool
+	last atomic.Int64 // unix nanos of the previous SIGINT
+}
+
+// newBreaker installs the SIGINT handler and returns the breaker the
+// run loop polls.
+func newBreaker(m *Machine, syms map[string]uint16) *breaker {
+	b := &breaker{m: m, syms: syms}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		for range ch {
+			now := time.Now().UnixNano()
+			if prev := b.last.Swap(now); now-prev < int64(doubleTap) {
+				fmt.Fprintln(os.Stderr, "\nfunc: second interrupt, exiting")
+				b.dump(os.Stderr)
+				os.Exit(1)
+			}
+			b.req.Store(true)
+		}
+	}()
+	return b
+}
+
+// pending reports and consumes a suspension request. The fast path is
+// one atomic load per instruction.
+func (b *breaker) pending() bool {
+	if !b.req.Load() {
+		return false
+	}
+	return b.req.Swap(false)
+}
+
+// dump prints the state a reader needs to know where the machine was:
+// the current instruction disassembled, the cycle count, and the
+// general registers.
+func (b *breaker) dump(w io.Writer) {
+	m := b.m
+	fmt.Fprintf(w, "pc 0x%04X: %s (cycle %d)\n",
+		m.pc, isa.Disassemble(m.imem[m.pc], int(m.pc)), m.cycles)
+	for i, v := range m.reg {
+		fmt.Fprintf(w, "r%d=0x%04X ", i, v)
+	}
+	fmt.Fprintln(w)
+	if c := m.spr[isa.SprCause]; c != 0 {
+		fmt.Fprintf(w, "last exception: %s, epc 0x%04X\n",
+			isa.CauseName(c), m.spr[isa.SprEpc])
+	}
+}
+
+// prompt runs the suspended-state command loop. It returns false when
+// the user asked to quit. End of file on stdin continues the run, so
+// a SIGINT against a program with stdin redirected does not wedge.
+func (b *breaker) prompt() bool {
+	fmt.Fprintln(os.Stderr, "\nfunc: interrupted")
+	b.dump(os.Stderr)
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "func> ")
+		if !in.Scan() {
+			fmt.Fprintln(os.Stderr)
+			return true
+		}
+		cmd := strings.TrimSpace(in.Text())
+		switch {
+		case cmd == "" || cmd == "c":
+			return true
+		case cmd == "r":
+			b.dump(os.Stderr)
+		case cmd == "q":
+			return false
+		case cmd == "x" || strings.HasPrefix(cmd, "x/") || strings.HasPrefix(cmd, "x "):
+			b.examine(os.Stderr, cmd)
+		case cmd == "m" || strings.HasPrefix(cmd, "m "):
+			b.mmuCmd(os.Stderr, cmd)
+		case strings.HasPrefix(cmd, "rc ") || strings.HasPrefix(cmd, "rp "):
+			b.runTo(os.Stderr, cmd)
+		default:
+			fmt.Fprintln(os.Stderr, "c continue, r registers, x/COUNTMODE addr|symbol examine, m [addr] mmu, rc cycle | rp addr|symbol run to, q quit")
+		}
+	}
+}
+
+// examine implements the x command: x/8w buffer, x/16b 0x2000,
+// x/5i main. Modes w (words), b (bytes), and a (ASCII) read dmem;
+// i (disassembly) and W (raw words) read imem — the bank follows the
+// mode, since the two address spaces are disjoint. The default is
+// /8w, and the address may be a -syms symbol, hex, or decimal.
+func (b *breaker) examine(w io.Writer, cmd string) {
+	count, mode := 8, byte('w')
+	fields := strings.Fields(cmd)
+	if len(fields) != 2 {
+		fmt.Fprintln(w, "usage: x/COUNTMODE addr|symbol (modes: w b a i W)")
+		return
+	}
+	if spec, ok := strings.CutPrefix(fields[0], "x/"); ok {
+		if len(spec) == 0 || !strings.ContainsRune("wbaiW", rune(spec[len(spec)-1])) {
+			fmt.Fprintf(w, "bad examine spec %q (modes: w b a i W)\n", fields[0])
+			return
+		}
+		mode = spec[len(spec)-1]
+		if digits := spec[:len(spec)-1]; digits != "" {
+			n, err := strconv.Atoi(digits)
+			if err != nil || n < 1 || n > 1024 {
+				fmt.Fprintf(w, "bad examine count %q\n", digits)
+				return
+			}
+			count = n
+		}
+	}
+	addr, ok := b.resolve(fields[1])
+	if !ok {
+		fmt.Fprintf(w, "bad address %q (not a symbol or number)\n", fields[1])
+		return
+	}
+	m := b.m
+	switch mode {
+	case 'b':
+		for i := 0; i < count; i++ {
+			if i%16 == 0 {
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintf(w, "0x%04X:", addr+uint16(i))
+			}
+			fmt.Fprintf(w, " %02X", m.dmem[addr+uint16(i)])
+		}
+		fmt.Fprintln(w)
+	case 'a':
+		for i := 0; i < count; i++ {
+			if i%64 == 0 {
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintf(w, "0x%04X: ", addr+uint16(i))
+			}
+			c := m.dmem[addr+uint16(i)]
+			if c < 0x20 || c >= 0x7F {
+				c = '.'
+			}
+			fmt.Fprintf(w, "%c", c)
+		}
+		fmt.Fprintln(w)
+	case 'w', 'W':
+		// dmem is byte-addressed, imem word-addressed; the row labels
+		// follow the bank's own addressing.
+		stride := uint16(2)
+		if mode == 'W' {
+			stride = 1
+		}
+		for i := 0; i < count; i++ {
+			a := addr + uint16(i)*stride
+			if i%8 == 0 {
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintf(w, "0x%04X:", a)
+			}
+			if mode == 'W' {
+				fmt.Fprintf(w, " 0x%04X", m.imem[a])
+			} else {
+				fmt.Fprintf(w, " 0x%04X", uint16(m.dmem[a])|uint16(m.dmem[a+1])<<8)
+			}
+		}
+		fmt.Fprintln(w)
+	case 'i':
+		for i := 0; i < count; i++ {
+			a := addr + uint16(i)
+			fmt.Fprintf(w, "0x%04X  0x%04X  %s\n", a, m.imem[a], isa.Disassemble(m.imem[a], int(a)))
+		}
+	}
+}
+
+// mmuCmd implements the m command: bare m prints the mapping table
+// and translation counters; m addr translates one address the way a
+// read at the current mode would, without counting it as an access.
+func (b *breaker) mmuCmd(w io.Writer, cmd string) {
+	u := b.m.mmu
+	if u == nil {
+		fmt.Fprintln(w, "no mmu attached")
+		return
+	}
+	fields := strings.Fields(cmd)
+	if len(fields) == 1 {
+		u.dump(w)
+		return
+	}
+	addr, ok := b.resolve(fields[1])
+	if !ok {
+		fmt.Fprintf(w, "bad address %q (not a symbol or number)\n", fields[1])
+		return
+	}
+	e := u.entries[addr>>mmuPageShift]
+	if e&MmuValid == 0 {
+		fmt.Fprintf(w, "0x%04X: no valid mapping (page %d)\n", addr, addr>>mmuPageShift)
+		return
+	}
+	phys := e&MmuPage<<mmuPageShift | addr&(1<<mmuPageShift-1)
+	flags := []byte("--")
+	if e&MmuRead != 0 {
+		flags[0] = 'r'
+	}
+	if e&MmuWrite != 0 {
+		flags[1] = 'w'
+	}
+	fmt.Fprintf(w, "0x%04X -> 0x%04X  %s\n", addr, phys, flags)
+}
+
+// runTo implements rc (run to a cycle count) and rp (run to a pc):
+// full-speed execution with one condition check per instruction, so
+// getting to the interesting part of a long run does not mean
+// single-stepping there. Another SIGINT aborts back to the prompt,
+// and a halt or an idle machine with no input pending ends early.
+func (b *breaker) runTo(w io.Writer, cmd string) {
+	fields := strings.Fields(cmd)
+	var stop func(*Machine) bool
+	switch {
+	case len(fields) != 2:
+		fmt.Fprintln(w, "usage: rc cycle | rp addr|symbol")
+		return
+	case fields[0] == "rc":
+		n, err := strconv.ParseUint(fields[1], 0, 64)
+		if err != nil {
+			fmt.Fprintf(w, "bad cycle count %q\n", fields[1])
+			return
+		}
+		if n <= b.m.cycles {
+			fmt.Fprintf(w, "cycle %d has already passed (now at %d)\n", n, b.m.cycles)
+			return
+		}
+		stop = func(m *Machine) bool { return m.cycles >= n }
+	default: // rp
+		addr, ok := b.resolve(fields[1])
+		if !ok {
+			fmt.Fprintf(w, "bad address %q (not a symbol or number)\n", fields[1])
+			return
+		}
+		stop = func(m *Machine) bool { return m.pc == addr }
+	}
+	m := b.m
+	for !stop(m) {
+		if !m.Step() {
+			break
+		}
+		if b.pending() {
+			fmt.Fprintln(w, "interrupted")
+			break
+		}
+		if m.idle && !m.io.Pending() {
+			fmt.Fprintln(w, "machine idle (wfi) with nothing pending")
+			break
+		}
+	}
+	if m.halted {
+		fmt.Fprintln(w, "machine halted")
+	}
+	b.dump(w)
+}
+
+// resolve turns a -syms symbol or a numeric literal into an address.
+func (b *breaker) resolve(s string) (uint16, bool) {
+	if v, ok := b.syms[s]; ok {
+		return v, true
+	}
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(v), true
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// The examine command must resolve symbols, pick the bank that fits
+// the mode, and format each mode as documented.
+func TestExamine(t *testing.T) {
+	m := NewMachine(NewIoSystem())
+	copy(m.dmem[0x20:], []byte{0x34, 0x12, 'h', 'i', '!', 0x01})
+	m.imem[isa.VectorBase] = isa.EncodeVop(isa.VopHlt)
+	b := &breaker{m: m, syms: map[string]uint16{"buf": 0x20, "main": uint16(isa.VectorBase)}}
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"x/1w buf", "0x0020: 0x1234"},
+		{"x/6b 0x20", "0x0020: 34 12 68 69 21 01"},
+		{"x/4a 34", "0x0022: hi!."},
+		{"x/1i main", "hlt"},
+		{"x/2b nosuch", `bad address "nosuch"`},
+		{"x/3z buf", "bad examine spec"},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		b.examine(&out, c.cmd)
+		if !strings.Contains(out.String(), c.want) {
+			t.Errorf("%s: output %q does not contain %q", c.cmd, out.String(), c.want)
+		}
+	}
+}
+
+// rc and rp run at full speed to the requested cycle or pc and land
+// exactly there; a halt on the way ends the run early.
+func TestRunTo(t *testing.T) {
+	prog := []uint16{
+		isa.EncodeMem(isa.OpAdi, 2, 0, 1),
+		isa.EncodeMem(isa.OpAdi, 2, 2, 1),
+		isa.EncodeMem(isa.OpAdi, 2, 2, 1),
+		isa.EncodeVop(isa.VopHlt),
+	}
+	m := testMachine(prog...)
+	b := &breaker{m: m, syms: map[string]uint16{"spot": 2}}
+
+	var out bytes.Buffer
+	b.runTo(&out, "rp spot")
+	if m.pc != 2 || m.cycles != 2 || m.halted {
+		t.Errorf("rp spot: pc=0x%04X cycles=%d halted=%v", m.pc, m.cycles, m.halted)
+	}
+	b.runTo(&out, "rc 3")
+	if m.cycles != 3 || m.halted {
+		t.Errorf("rc 3: cycles=%d halted=%v", m.cycles, m.halted)
+	}
+	b.runTo(&out, "rc 100")
+	if !m.halted || !strings.Contains(out.String(), "machine halted") {
+		t.Errorf("rc past the end: halted=%v output %q", m.halted, out.String())
+	}
+
+	out.Reset()
+	b.runTo(&out, "rc 1")
+	if !strings.Contains(out.String(), "already passed") {
+		t.Errorf("stale rc target: %q", out.String())
+	}
+}
@@ -0,0 +1,40 @@
+// The simulated clock. The machine advances one clock per
+// instruction, and anything that needs simulated time — a periodic
+// timer, a UART pacing itself to a baud rate — subscribes with a
+// divisor and receives Tick callbacks, instead of counting cycles in
+// its own Read/Write paths. Ticks keep arriving while the CPU idles
+// in wfi, because idle cycles are still cycles; a timed device wakes
+// the machine by raising its interrupt line from Tick.
+
+package main
+
+// A Ticker receives simulated-time callbacks; see AddTicker.
+type Ticker interface {
+	Tick(cycles uint64)
+}
+
+type tickEntry struct {
+	t     Ticker
+	every uint64
+}
+
+// AddTicker subscribes t to the simulated clock: Tick runs once
+// every `every` cycles, at cycle counts the divisor divides evenly,
+// so two devices on the same divisor always tick together. A divisor
+// below one means every cycle.
+func (io *IoSystem) AddTicker(every uint64, t Ticker) {
+	if every < 1 {
+		every = 1
+	}
+	io.ticks = append(io.ticks, tickEntry{t, every})
+}
+
+// tick dispatches one clock advance. Step calls it once per cycle
+// when any subscriber exists.
+func (io *IoSystem) tick(cycles uint64) {
+	for _, e := range io.ticks {
+		if cycles%e.every == 0 {
+			e.t.Tick(cycles)
+		}
+	}
+}
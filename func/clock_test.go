@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+type countTicker struct {
+	ticks []uint64
+}
+
+func (c *countTicker) Tick(cycles uint64) { c.ticks = append(c.ticks, cycles) }
+
+// A subscriber at divisor 4 ticks at cycles 4 and 8 of an 8-cycle
+// run, and nowhere else.
+func TestClockDivisor(t *testing.T) {
+	io := NewIoSystem()
+	m := NewMachine(io)
+	for i := 0; i < 7; i++ {
+		m.imem[i] = isa.EncodeMem(isa.OpAdi, 2, 2, 1)
+	}
+	m.imem[7] = isa.EncodeVop(isa.VopHlt)
+	var c countTicker
+	io.AddTicker(4, &c)
+	stepN(m, 8)
+	if len(c.ticks) != 2 || c.ticks[0] != 4 || c.ticks[1] != 8 {
+		t.Errorf("ticks = %v, want [4 8]", c.ticks)
+	}
+}
+
+// tickIrq raises its interrupt line from a Tick callback, the shape
+// of a periodic timer device.
+type tickIrq struct {
+	at      uint64
+	pending bool
+}
+
+func (d *tickIrq) Read(uint16) uint16   { return 0 }
+func (d *tickIrq) Write(uint16, uint16) {}
+func (d *tickIrq) Pending() bool        { return d.pending }
+func (d *tickIrq) Tick(cycles uint64) {
+	if cycles >= d.at {
+		d.pending = true
+	}
+}
+
+// The clock keeps running while the CPU idles in wfi, so a timed
+// device's interrupt ends the idle and execution resumes.
+func TestClockWakesWfi(t *testing.T) {
+	io := NewIoSystem()
+	d := &tickIrq{at: 5}
+	io.AddDevice(d)
+	io.AddTicker(5, d)
+	m := NewMachine(io)
+	m.imem[0] = isa.EncodeVop(isa.VopWfi)
+	m.imem[1] = isa.EncodeVop(isa.VopHlt)
+	stepN(m, 20)
+	if !m.halted {
+		t.Fatalf("still idle after 20 cycles: pc=0x%04X idle=%v", m.pc, m.idle)
+	}
+	if m.cycles < 5 {
+		t.Errorf("halted at cycle %d, before the tick at 5", m.cycles)
+	}
+}
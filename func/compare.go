@@ -0,0 +1,19 @@
+// The emulator's half of the dual-simulator compare mode: a hook
+// that feeds every data memory access into the shared mem trace, in
+// program order. sim's RAM component records into the same trace
+// type from its side, so the two sequences are directly comparable
+// with mem.FirstDiff.
+
+package main
+
+import "github.com/gmofishsauce/y4/mem"
+
+// TraceHooks records the memory-access sequence of a run.
+type TraceHooks struct {
+	NopHooks
+	T *mem.Trace
+}
+
+func (h *TraceHooks) OnMemAccess(m *Machine, addr uint16, size int, write bool, value uint16) {
+	h.T.Record(mem.Access{Addr: addr, Value: value, Size: size, Write: write})
+}
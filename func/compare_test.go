@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/mem"
+)
+
+// The hook must record loads and stores in program order with the
+// values the memory moved, matching what sim's RAM records for the
+// same sequence.
+func TestTraceHooksSequence(t *testing.T) {
+	m := testMachine(
+		isa.EncodeMem(isa.OpAdi, 2, 0, 5),
+		isa.EncodeMem(isa.OpStw, 2, 0, 32),
+		isa.EncodeMem(isa.OpLdw, 3, 0, 32),
+		isa.EncodeVop(isa.VopHlt),
+	)
+	tr := &mem.Trace{}
+	m.AddHook(&TraceHooks{T: tr})
+	stepN(m, 10)
+
+	want := &mem.Trace{}
+	want.Record(mem.Access{Addr: 32, Value: 5, Size: 2, Write: true})
+	want.Record(mem.Access{Addr: 32, Value: 5, Size: 2})
+	if d := mem.FirstDiff(tr, want); d != -1 {
+		t.Errorf("trace differs from expected at access %d", d)
+	}
+}
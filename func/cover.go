@@ -0,0 +1,129 @@
+// Coverage measurement for WUT-4 programs, built on the hook
+// interface. The collector counts executions per instruction address
+// and, for beq, how often each branch went each way. The report maps
+// addresses back to assembly source lines when the assembler's
+// -lines map is supplied.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// Coverage is a Hooks implementation that records executed addresses
+// and branch directions.
+type Coverage struct {
+	NopHooks
+	codeWords int // extent of the loaded code segment
+	exec      map[uint16]uint64
+	taken     map[uint16]uint64
+	notTaken  map[uint16]uint64
+}
+
+func NewCoverage(codeWords int) *Coverage {
+	return &Coverage{
+		codeWords: codeWords,
+		exec:      make(map[uint16]uint64),
+		taken:     make(map[uint16]uint64),
+		notTaken:  make(map[uint16]uint64),
+	}
+}
+
+func (c *Coverage) OnExecute(m *Machine, pc, w uint16) {
+	c.exec[pc]++
+	if isa.GetOp(w) == isa.OpBeq {
+		// The registers are unchanged by beq, so the direction can be
+		// recomputed here instead of widening the hook interface.
+		if m.get(isa.GetRa(w)) == m.get(isa.GetRb(w)) {
+			c.taken[pc]++
+		} else {
+			c.notTaken[pc]++
+		}
+	}
+}
+
+// Report writes a human-readable coverage summary. lines maps word
+// addresses to source lines and may be nil.
+func (c *Coverage) Report(w io.Writer, lines map[int]int) {
+	executed := len(c.exec)
+	fmt.Fprintf(w, "coverage: %d/%d code words executed (%.1f%%)\n",
+		executed, c.codeWords, pct(executed, c.codeWords))
+
+	both := 0
+	branches := sortedKeys(c.taken, c.notTaken)
+	for _, pc := range branches {
+		if c.taken[pc] > 0 && c.notTaken[pc] > 0 {
+			both++
+		}
+	}
+	fmt.Fprintf(w, "branches: %d/%d taken both ways\n", both, len(branches))
+
+	for pc := 0; pc < c.codeWords; pc++ {
+		if _, ok := c.exec[uint16(pc)]; !ok {
+			fmt.Fprintf(w, "never executed: %s\n", c.where(pc, lines))
+		}
+	}
+	for _, pc := range branches {
+		switch {
+		case c.notTaken[pc] == 0:
+			fmt.Fprintf(w, "branch always taken: %s\n", c.where(int(pc), lines))
+		case c.taken[pc] == 0:
+			fmt.Fprintf(w, "branch never taken: %s\n", c.where(int(pc), lines))
+		}
+	}
+}
+
+func (c *Coverage) where(pc int, lines map[int]int) string {
+	if line, ok := lines[pc]; ok {
+		return fmt.Sprintf("0x%04X (line %d)", pc, line)
+	}
+	return fmt.Sprintf("0x%04X", pc)
+}
+
+func pct(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(of)
+}
+
+func sortedKeys(ms ...map[uint16]uint64) []uint16 {
+	seen := make(map[uint16]bool)
+	var keys []uint16
+	for _, m := range ms {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// loadLineMap reads the assembler's -lines output: one "address line"
+// pair per line, both decimal.
+func loadLineMap(path string) (map[int]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines := make(map[int]int)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var addr, line int
+		if _, err := fmt.Sscanf(sc.Text(), "%d %d", &addr, &line); err != nil {
+			return nil, fmt.Errorf("%s: bad line map entry %q", path, sc.Text())
+		}
+		lines[addr] = line
+	}
+	return lines, sc.Err()
+}
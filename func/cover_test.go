@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+func TestCoverage(t *testing.T) {
+	// Two iterations: the branch at word 2 goes both ways (r1 is 1
+	// then 2 against r2=2), the loop branch at word 3 is always
+	// taken, and word 5 is dead code.
+	prog := []uint16{
+		isa.EncodeMem(isa.OpAdi, 1, 1, 1),  // 0: r1++
+		isa.EncodeMem(isa.OpAdi, 2, 0, 2),  // 1: r2 = 2
+		isa.EncodeMem(isa.OpBeq, 1, 2, 1),  // 2: beq r1, r2, done
+		isa.EncodeMem(isa.OpBeq, 0, 0, -4), // 3: loop to 0
+		isa.EncodeVop(isa.VopHlt),          // 4: done
+		0,                                  // 5: never executed
+	}
+	m := testMachine(prog...)
+	c := NewCoverage(len(prog))
+	m.AddHook(c)
+	stepN(m, 100)
+
+	var b strings.Builder
+	c.Report(&b, map[int]int{5: 42})
+	got := b.String()
+	for _, want := range []string{
+		"coverage: 5/6 code words executed (83.3%)",
+		"branches: 1/2 taken both ways",
+		"never executed: 0x0005 (line 42)",
+		"branch always taken: 0x0003",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+}
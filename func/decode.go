@@ -0,0 +1,198 @@
+// Predecoding for the interpreter core. The nested opcode/xop/yop/vop
+// field extraction used to run on every cycle; hot loops repeated it
+// millions of times for the same handful of words. Instead, the whole
+// of imem is decoded once into a flat table of (kind, ra, rb, imm)
+// records the first time Step runs, and the interpreter dispatches on
+// the flattened kind alone. The machine cannot write imem, so the
+// table never goes stale on its own; InvalidateImem covers external
+// pokes (a debugger, a loader patch).
+
+package main
+
+import "github.com/gmofishsauce/y4/isa"
+
+// dkind is a flattened operation code: one value per distinct
+// operation across the base, XOP, YOP, and VOP spaces.
+type dkind uint8
+
+const (
+	dIllegal dkind = iota
+	dLdw
+	dLdb
+	dStw
+	dStb
+	dBeq
+	dAdi
+	dLui
+	dAdd
+	dSub
+	dAnd
+	dOr
+	dXor
+	dJlr
+	dSlt
+	dLsr
+	dLsl
+	dAsr
+	dNot
+	dNeg
+	dLsp
+	dSsp
+	dLio
+	dSio
+	dLsri
+	dLsli
+	dAsri
+	dTas
+	dSys
+	dHlt
+	dRti
+	dDi
+	dEi
+	dBrk
+	dWfi
+)
+
+// decoded is one predecoded instruction. imm holds whichever
+// immediate the format carries: the sign-extended imm7, the
+// already-shifted lui value, the jlr imm4, or the sys trap number.
+// For lsp/ssp and the immediate shifts, rb holds the spr number or
+// shift count (they occupy the same field).
+type decoded struct {
+	kind   dkind
+	ra, rb uint8
+	imm    int16
+}
+
+func decodeWord(w uint16) decoded {
+	ra, rb := uint8(isa.GetRa(w)), uint8(isa.GetRb(w))
+	imm7 := int16(isa.GetImm7(w))
+	switch isa.GetOp(w) {
+	case isa.OpLdw:
+		return decoded{dLdw, ra, rb, imm7}
+	case isa.OpLdb:
+		return decoded{dLdb, ra, rb, imm7}
+	case isa.OpStw:
+		return decoded{dStw, ra, rb, imm7}
+	case isa.OpStb:
+		return decoded{dStb, ra, rb, imm7}
+	case isa.OpBeq:
+		return decoded{dBeq, ra, rb, imm7}
+	case isa.OpAdi:
+		return decoded{dAdi, ra, rb, imm7}
+	case isa.OpLui:
+		return decoded{dLui, ra, 0, int16(uint16(isa.GetImm10(w)) << 6)}
+	}
+
+	// OpExt: the XOP space. The two-register XOPs reserve the imm4
+	// field (bits 9..6, zero in every legal encoding); only jlr uses
+	// it. Rejecting nonzero reserved bits here keeps the patterns
+	// free for future operands instead of silently aliasing.
+	switch isa.GetXop(w) {
+	case isa.XopJlr:
+		return decoded{dJlr, ra, rb, int16(isa.GetImm4(w))}
+	}
+	if isa.GetXop(w) != isa.XopYop && isa.GetImm4(w) != 0 {
+		return decoded{dIllegal, 0, 0, 0}
+	}
+	switch isa.GetXop(w) {
+	case isa.XopAdd:
+		return decoded{dAdd, ra, rb, 0}
+	case isa.XopSub:
+		return decoded{dSub, ra, rb, 0}
+	case isa.XopAnd:
+		return decoded{dAnd, ra, rb, 0}
+	case isa.XopOr:
+		return decoded{dOr, ra, rb, 0}
+	case isa.XopXor:
+		return decoded{dXor, ra, rb, 0}
+	case isa.XopSlt:
+		return decoded{dSlt, ra, rb, 0}
+	}
+
+	// XopYop: the YOP space. The one-register YOPs reserve bits 5..3
+	// (the rb/spr/count position), zero in every legal encoding.
+	switch isa.GetYop(w) {
+	case isa.YopLsr, isa.YopLsl, isa.YopAsr, isa.YopNot, isa.YopNeg:
+		if rb != 0 {
+			return decoded{dIllegal, 0, 0, 0}
+		}
+	}
+	switch isa.GetYop(w) {
+	case isa.YopLsr:
+		return decoded{dLsr, ra, 0, 0}
+	case isa.YopLsl:
+		return decoded{dLsl, ra, 0, 0}
+	case isa.YopAsr:
+		return decoded{dAsr, ra, 0, 0}
+	case isa.YopNot:
+		return decoded{dNot, ra, 0, 0}
+	case isa.YopNeg:
+		return decoded{dNeg, ra, 0, 0}
+	case isa.YopLsp:
+		return decoded{dLsp, ra, uint8(isa.GetSpr(w)), 0}
+	case isa.YopSsp:
+		return decoded{dSsp, ra, uint8(isa.GetSpr(w)), 0}
+	case isa.YopLio:
+		return decoded{dLio, ra, rb, 0}
+	case isa.YopSio:
+		return decoded{dSio, ra, rb, 0}
+	case isa.YopLsri:
+		return decoded{dLsri, ra, uint8(isa.GetShn(w)), 0}
+	case isa.YopLsli:
+		return decoded{dLsli, ra, uint8(isa.GetShn(w)), 0}
+	case isa.YopAsri:
+		return decoded{dAsri, ra, uint8(isa.GetShn(w)), 0}
+	case isa.YopTas:
+		return decoded{dTas, ra, rb, 0}
+	case isa.YopSys:
+		return decoded{dSys, 0, 0, int16(isa.GetSys(w))}
+	case isa.YopVop:
+		// fall through to the VOP space below
+	default:
+		return decoded{dIllegal, 0, 0, 0}
+	}
+
+	switch isa.GetVop(w) {
+	case isa.VopHlt:
+		return decoded{dHlt, 0, 0, 0}
+	case isa.VopRti:
+		return decoded{dRti, 0, 0, 0}
+	case isa.VopDi:
+		return decoded{dDi, 0, 0, 0}
+	case isa.VopEi:
+		return decoded{dEi, 0, 0, 0}
+	case isa.VopBrk:
+		return decoded{dBrk, 0, 0, 0}
+	case isa.VopWfi:
+		return decoded{dWfi, 0, 0, 0}
+	}
+	return decoded{dIllegal, 0, 0, 0}
+}
+
+// predecode fills the dispatch table from imem.
+func (m *Machine) predecode() {
+	m.dec = make([]decoded, ImemWords)
+	for i, w := range m.imem {
+		m.dec[i] = m.decodeRev(w)
+	}
+}
+
+// decodeRev decodes one word under the machine's ISA revision: words
+// that encode instructions newer than the revision are illegal, just
+// as they would be on the older hardware.
+func (m *Machine) decodeRev(w uint16) decoded {
+	if d := isa.Decode(w); d != nil && isa.MinRev(d) > m.isaRev {
+		return decoded{dIllegal, 0, 0, 0}
+	}
+	return decodeWord(w)
+}
+
+// InvalidateImem re-decodes one word after an external imem write
+// (a debugger poke or loader patch). Nothing in Step writes imem, so
+// the interpreter itself never needs this.
+func (m *Machine) InvalidateImem(addr uint16) {
+	if m.dec != nil {
+		m.dec[addr] = m.decodeRev(m.imem[addr])
+	}
+}
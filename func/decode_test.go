@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// Every reserved encoding must decode to dIllegal: the free YOP and
+// VOP slots, nonzero imm4 bits under the two-register XOPs, and
+// nonzero bits 5..3 under the one-register YOPs. Raising ExIllegal at
+// decode keeps those patterns free for future instructions.
+func TestReservedEncodingsIllegal(t *testing.T) {
+	illegal := func(w uint16, what string) {
+		t.Helper()
+		if d := decodeWord(w); d.kind != dIllegal {
+			t.Errorf("%s: 0x%04X decoded to kind %d, want dIllegal", what, w, d.kind)
+		}
+	}
+
+	// Free VOP slots: everything past the assigned six.
+	for v := isa.VopWfi + 1; v < 64; v++ {
+		illegal(isa.EncodeVop(v), "free vop")
+	}
+
+	// The free YOP slot, with every low-bit pattern.
+	for low := 0; low < 64; low++ {
+		w := uint16(isa.OpExt<<13 | isa.XopYop<<10 | 14<<6 | low)
+		illegal(w, "free yop")
+	}
+
+	// Two-register XOPs reserve imm4; jlr is the one that uses it.
+	for _, xop := range []int{isa.XopAdd, isa.XopSub, isa.XopAnd,
+		isa.XopOr, isa.XopXor, isa.XopSlt} {
+		if d := decodeWord(isa.EncodeXop(xop, 2, 3)); d.kind == dIllegal {
+			t.Errorf("xop %d with zero imm4 decoded illegal", xop)
+		}
+		for imm := 1; imm < 16; imm++ {
+			w := uint16(isa.OpExt<<13 | xop<<10 | imm<<6 | 3<<3 | 2)
+			illegal(w, "xop reserved imm4")
+		}
+	}
+	for imm := 0; imm < 16; imm++ {
+		if d := decodeWord(isa.EncodeJlr(1, 2, imm)); d.kind != dJlr {
+			t.Errorf("jlr imm4=%d decoded to kind %d, want dJlr", imm, d.kind)
+		}
+	}
+
+	// One-register YOPs reserve bits 5..3.
+	for _, yop := range []int{isa.YopLsr, isa.YopLsl, isa.YopAsr,
+		isa.YopNot, isa.YopNeg} {
+		if d := decodeWord(isa.EncodeYop(yop, 4)); d.kind == dIllegal {
+			t.Errorf("yop %d with zero bits 5..3 decoded illegal", yop)
+		}
+		for rb := 1; rb < 8; rb++ {
+			w := uint16(isa.OpExt<<13 | isa.XopYop<<10 | yop<<6 | rb<<3 | 4)
+			illegal(w, "yop reserved bits 5..3")
+		}
+	}
+}
+
+// Under an older ISA revision the newer encodings are illegal, the
+// same as on the hardware they postdate; under the current revision
+// the same program runs.
+func TestIsaRevisionDecode(t *testing.T) {
+	// The hlt doubles as the ExIllegal vector slot.
+	prog := []uint16{
+		isa.EncodeIo(isa.YopTas, 2, 3),
+		isa.EncodeVop(isa.VopHlt),
+	}
+	m := testMachine(prog...)
+	stepN(m, 10)
+	if got := m.spr[isa.SprCause]; got != 0 {
+		t.Errorf("tas at revision %d faulted with cause %d", isa.Version, got)
+	}
+
+	m = testMachine(prog...)
+	m.SetIsaRev(1)
+	stepN(m, 10)
+	if got := m.spr[isa.SprCause]; got != isa.ExIllegal {
+		t.Errorf("tas at revision 1: cause = %d, want ExIllegal", got)
+	}
+}
@@ -0,0 +1,63 @@
+// Trap-and-emulate support for ISA experiments. An Emulator is a Go
+// callback that gets first claim on every word that decodes as
+// illegal: it can implement a proposed instruction in a reserved
+// encoding and measure real programs with it before anything is
+// committed to the isa tables or the hardware. Words the emulator
+// declines fall through to the usual ExIllegal exception — which is
+// also the road for a guest-side experiment: a kernel handler can
+// read imem[epc], emulate, and rti, with no emulator attached.
+//
+// Experiments live in this file's registry and are selected with
+// -emu name, so trying one needs no main.go surgery. They are
+// deliberately not reachable by default: an unassigned encoding must
+// stay illegal in every configuration a test suite sees, or programs
+// start depending on it.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// An Emulator sees the pc and word of an illegal instruction and
+// returns the next pc and true if it emulated it, or false to let
+// the illegal-instruction exception proceed. It may use the
+// machine's get/put and memories like any exec function.
+type Emulator func(m *Machine, pc, w uint16) (uint16, bool)
+
+// SetEmulator attaches fn; nil detaches.
+func (m *Machine) SetEmulator(fn Emulator) { m.emu = fn }
+
+// emulators is the -emu registry. Keep entries documented: each is a
+// proposal under evaluation, not part of the architecture.
+var emulators = map[string]Emulator{
+	// mul: ra = low word of ra*rb, in the free YOP slot 14 with the
+	// multiplicand in the rb/spr field. Evaluates whether a hardware
+	// multiply would pay for itself against the math unit's I/O
+	// ports.
+	"mul": func(m *Machine, pc, w uint16) (uint16, bool) {
+		if isa.GetOp(w) != isa.OpExt || isa.GetXop(w) != isa.XopYop || isa.GetYop(w) != 14 {
+			return 0, false
+		}
+		ra, rb := isa.GetRa(w), isa.GetRb(w)
+		m.put(ra, m.get(ra)*m.get(rb))
+		return pc + 1, true
+	},
+}
+
+// lookupEmulator resolves -emu, listing the registry in the error so
+// a typo shows what is available.
+func lookupEmulator(name string) (Emulator, error) {
+	if fn, ok := emulators[name]; ok {
+		return fn, nil
+	}
+	var names []string
+	for n := range emulators {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("-emu %q: unknown experiment (have %v)", name, names)
+}
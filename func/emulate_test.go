@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// With the mul experiment attached, the free YOP 14 encoding computes
+// a product and execution continues; without it, the same program
+// takes ExIllegal. Declined words still fault.
+func TestTrapAndEmulate(t *testing.T) {
+	prog := []uint16{
+		isa.EncodeMem(isa.OpAdi, 2, 0, 6),
+		isa.EncodeMem(isa.OpAdi, 3, 0, 7),
+		isa.EncodeIo(14, 2, 3), // proposed mul r2, r3
+		isa.EncodeVop(isa.VopHlt),
+	}
+	m := testMachine(prog...)
+	fn, err := lookupEmulator("mul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetEmulator(fn)
+	stepN(m, 10)
+	if !m.halted || m.reg[2] != 42 || m.spr[isa.SprCause] != 0 {
+		t.Errorf("emulated: halted=%v r2=%d cause=%d", m.halted, m.reg[2], m.spr[isa.SprCause])
+	}
+
+	m = testMachine(prog...)
+	m.imem[64+isa.ExIllegal] = isa.EncodeVop(isa.VopHlt)
+	m.spr[isa.SprVbase] = 64
+	stepN(m, 10)
+	if got := m.spr[isa.SprCause]; got != isa.ExIllegal {
+		t.Errorf("unattached: cause = %d, want ExIllegal", got)
+	}
+
+	m = testMachine(isa.EncodeVop(63)) // reserved vop, not mul's encoding
+	m.imem[64+isa.ExIllegal] = isa.EncodeVop(isa.VopHlt)
+	m.spr[isa.SprVbase] = 64
+	m.SetEmulator(fn)
+	stepN(m, 10)
+	if got := m.spr[isa.SprCause]; got != isa.ExIllegal {
+		t.Errorf("declined word: cause = %d, want ExIllegal", got)
+	}
+
+	if _, err := lookupEmulator("nosuch"); err == nil {
+		t.Error("unknown experiment accepted")
+	}
+}
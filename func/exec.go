@@ -0,0 +1,424 @@
+// The execute stage, one function per flattened operation. Step looks
+// the function up in execTab by the predecoded kind, so the whole
+// execute path is a single indexed call with no secondary decoding.
+// Each function gets the machine explicitly, which keeps the table a
+// plain package-level constant and lets a test drive any single
+// operation against a scratch machine without assembling a program.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// An execFn executes one decoded instruction. next is the fall-through
+// pc; the function returns the actual next pc, and false if it took an
+// exception (in which case the machine state is already diverted and
+// the caller must not advance pc or run post-execute hooks).
+type execFn func(m *Machine, d *decoded, next uint16) (uint16, bool)
+
+// execTab is indexed by dkind. Every kind has an entry; unused encodings
+// decode to dIllegal, so no slot is nil.
+var execTab = [...]execFn{
+	dIllegal: execIllegal,
+	dLdw:     execLdw,
+	dLdb:     execLdb,
+	dStw:     execStw,
+	dStb:     execStb,
+	dBeq:     execBeq,
+	dAdi:     execAdi,
+	dLui:     execLui,
+	dAdd:     execAdd,
+	dSub:     execSub,
+	dAnd:     execAnd,
+	dOr:      execOr,
+	dXor:     execXor,
+	dJlr:     execJlr,
+	dSlt:     execSlt,
+	dLsr:     execLsr,
+	dLsl:     execLsl,
+	dAsr:     execAsr,
+	dNot:     execNot,
+	dNeg:     execNeg,
+	dLsp:     execLsp,
+	dSsp:     execSsp,
+	dLio:     execLio,
+	dSio:     execSio,
+	dLsri:    execLsri,
+	dLsli:    execLsli,
+	dAsri:    execAsri,
+	dTas:     execTas,
+	dSys:     execSys,
+	dHlt:     execHlt,
+	dRti:     execRti,
+	dDi:      execDi,
+	dEi:      execEi,
+	dBrk:     execBrk,
+	dWfi:     execWfi,
+}
+
+func execIllegal(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if m.emu != nil {
+		if pc, ok := m.emu(m, next-1, m.imem[next-1]); ok {
+			return pc, true
+		}
+	}
+	m.exception(isa.ExIllegal)
+	return 0, false
+}
+
+func execLdw(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	addr := m.get(int(d.rb)) + uint16(d.imm)
+	if addr&1 != 0 || int(addr)+1 >= DmemBytes {
+		m.memFault(addr, isa.FtRead)
+		return 0, false
+	}
+	if m.mmu != nil {
+		var ok bool
+		if addr, ok = m.xlate(addr, isa.FtRead); !ok {
+			return 0, false
+		}
+	}
+	if len(m.guards) != 0 && m.guarded(addr, 2) {
+		m.memFault(addr, isa.FtRead)
+		return 0, false
+	}
+	v := uint16(m.dmem[addr]) | uint16(m.dmem[addr+1])<<8
+	if len(m.hooks) != 0 {
+		m.memHook(addr, 2, false, v)
+	}
+	m.put(int(d.ra), v)
+	return next, true
+}
+
+func execLdb(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	addr := m.get(int(d.rb)) + uint16(d.imm)
+	if m.mmu != nil {
+		var ok bool
+		if addr, ok = m.xlate(addr, isa.FtRead); !ok {
+			return 0, false
+		}
+	}
+	if len(m.guards) != 0 && m.guarded(addr, 1) {
+		m.memFault(addr, isa.FtRead)
+		return 0, false
+	}
+	v := uint16(m.dmem[addr])
+	if len(m.hooks) != 0 {
+		m.memHook(addr, 1, false, v)
+	}
+	m.put(int(d.ra), v)
+	return next, true
+}
+
+func execStw(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	addr := m.get(int(d.rb)) + uint16(d.imm)
+	if addr&1 != 0 || int(addr)+1 >= DmemBytes {
+		m.memFault(addr, isa.FtWrite)
+		return 0, false
+	}
+	if m.mmu != nil {
+		var ok bool
+		if addr, ok = m.xlate(addr, isa.FtWrite); !ok {
+			return 0, false
+		}
+	}
+	if len(m.guards) != 0 && m.guarded(addr, 2) {
+		m.memFault(addr, isa.FtWrite)
+		return 0, false
+	}
+	v := m.get(int(d.ra))
+	if len(m.hooks) != 0 {
+		m.memHook(addr, 2, true, v)
+	}
+	m.dmem[addr] = byte(v)
+	m.dmem[addr+1] = byte(v >> 8)
+	return next, true
+}
+
+func execStb(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	addr := m.get(int(d.rb)) + uint16(d.imm)
+	if m.mmu != nil {
+		var ok bool
+		if addr, ok = m.xlate(addr, isa.FtWrite); !ok {
+			return 0, false
+		}
+	}
+	if len(m.guards) != 0 && m.guarded(addr, 1) {
+		m.memFault(addr, isa.FtWrite)
+		return 0, false
+	}
+	v := m.get(int(d.ra))
+	if len(m.hooks) != 0 {
+		m.memHook(addr, 1, true, v)
+	}
+	m.dmem[addr] = byte(v)
+	return next, true
+}
+
+func execBeq(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if m.get(int(d.ra)) == m.get(int(d.rb)) {
+		next += uint16(d.imm)
+	}
+	return next, true
+}
+
+func execAdi(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	m.put(int(d.ra), m.get(int(d.rb))+uint16(d.imm))
+	return next, true
+}
+
+func execLui(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	m.put(int(d.ra), uint16(d.imm))
+	return next, true
+}
+
+func execAdd(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	a, b := m.get(int(d.ra)), m.get(int(d.rb))
+	m.put(int(d.ra), a+b)
+	m.setCarry(uint32(a)+uint32(b) > 0xFFFF)
+	return next, true
+}
+
+func execSub(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	a, b := m.get(int(d.ra)), m.get(int(d.rb))
+	m.put(int(d.ra), a-b)
+	m.setCarry(a < b)
+	return next, true
+}
+
+func execAnd(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	m.put(int(d.ra), m.get(int(d.ra))&m.get(int(d.rb)))
+	return next, true
+}
+
+func execOr(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	m.put(int(d.ra), m.get(int(d.ra))|m.get(int(d.rb)))
+	return next, true
+}
+
+func execXor(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	m.put(int(d.ra), m.get(int(d.ra))^m.get(int(d.rb)))
+	return next, true
+}
+
+func execJlr(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	t := m.get(int(d.rb)) + uint16(d.imm)
+	m.put(int(d.ra), next)
+	return t, true
+}
+
+func execSlt(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if int16(m.get(int(d.ra))) < int16(m.get(int(d.rb))) {
+		m.put(int(d.ra), 1)
+	} else {
+		m.put(int(d.ra), 0)
+	}
+	return next, true
+}
+
+func execLsr(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	v := m.get(int(d.ra))
+	m.put(int(d.ra), v>>1)
+	m.setCarry(v&1 != 0)
+	return next, true
+}
+
+func execLsl(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	v := m.get(int(d.ra))
+	m.put(int(d.ra), v<<1)
+	m.setCarry(v&0x8000 != 0)
+	return next, true
+}
+
+func execAsr(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	v := m.get(int(d.ra))
+	m.put(int(d.ra), uint16(int16(v)>>1))
+	m.setCarry(v&1 != 0)
+	return next, true
+}
+
+func execNot(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	m.put(int(d.ra), ^m.get(int(d.ra)))
+	return next, true
+}
+
+func execNeg(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	m.put(int(d.ra), -m.get(int(d.ra)))
+	return next, true
+}
+
+func execLsp(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if !m.kernel() {
+		m.exception(isa.ExIllegal)
+		return 0, false
+	}
+	v, ok := m.loadSpr(int(d.rb))
+	if !ok {
+		return 0, false
+	}
+	m.put(int(d.ra), v)
+	return next, true
+}
+
+func execSsp(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if !m.kernel() {
+		m.exception(isa.ExIllegal)
+		return 0, false
+	}
+	if !m.storeSpr(int(d.rb), m.get(int(d.ra))) {
+		return 0, false
+	}
+	return next, true
+}
+
+func execLio(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	port := m.get(int(d.rb))
+	if !m.ioPermitted(port, isa.FtRead) {
+		return 0, false
+	}
+	if !m.io.Mapped(port) &&
+		m.violate(isa.ExMemory, fmt.Sprintf("lio%d", port),
+			"read of unmapped i/o port %d at pc 0x%04X", port, m.pc) {
+		// The port number stands in for the fault address.
+		m.spr[isa.SprFar] = port
+		m.spr[isa.SprFtype] = isa.FtRead
+		return 0, false
+	}
+	m.put(int(d.ra), m.io.Read(port))
+	return next, true
+}
+
+func execSio(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	port := m.get(int(d.rb))
+	if !m.ioPermitted(port, isa.FtWrite) {
+		return 0, false
+	}
+	if !m.io.Mapped(port) &&
+		m.violate(isa.ExMemory, fmt.Sprintf("sio%d", port),
+			"write to unmapped i/o port %d at pc 0x%04X", port, m.pc) {
+		m.spr[isa.SprFar] = port
+		m.spr[isa.SprFtype] = isa.FtWrite
+		return 0, false
+	}
+	m.io.Write(port, m.get(int(d.ra)))
+	return next, true
+}
+
+func execLsri(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if n := int(d.rb); n != 0 {
+		v := m.get(int(d.ra))
+		m.put(int(d.ra), v>>n)
+		m.setCarry(v>>(n-1)&1 != 0)
+	}
+	return next, true
+}
+
+func execLsli(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if n := int(d.rb); n != 0 {
+		v := m.get(int(d.ra))
+		m.put(int(d.ra), v<<n)
+		m.setCarry(v<<(n-1)&0x8000 != 0)
+	}
+	return next, true
+}
+
+func execAsri(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if n := int(d.rb); n != 0 {
+		v := m.get(int(d.ra))
+		m.put(int(d.ra), uint16(int16(v)>>n))
+		m.setCarry(uint16(int16(v)>>(n-1))&1 != 0)
+	}
+	return next, true
+}
+
+func execTas(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	// Atomic with respect to interrupts and, when multiple
+	// processors arrive, to other CPUs: the load of the old
+	// value and the store of 0xFFFF are one indivisible step.
+	addr := m.get(int(d.rb))
+	if addr&1 != 0 || int(addr)+1 >= DmemBytes {
+		m.memFault(addr, isa.FtRead|isa.FtWrite)
+		return 0, false
+	}
+	if m.mmu != nil {
+		var ok bool
+		if addr, ok = m.xlate(addr, isa.FtRead|isa.FtWrite); !ok {
+			return 0, false
+		}
+	}
+	if len(m.guards) != 0 && m.guarded(addr, 2) {
+		m.memFault(addr, isa.FtRead|isa.FtWrite)
+		return 0, false
+	}
+	v := uint16(m.dmem[addr]) | uint16(m.dmem[addr+1])<<8
+	if len(m.hooks) != 0 {
+		m.memHook(addr, 2, false, v)
+		m.memHook(addr, 2, true, 0xFFFF)
+	}
+	m.put(int(d.ra), v)
+	m.dmem[addr] = 0xFF
+	m.dmem[addr+1] = 0xFF
+	return next, true
+}
+
+func execSys(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	m.exception(uint16(isa.ExSysBase) + uint16(d.imm))
+	return 0, false
+}
+
+func execHlt(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if !m.kernel() {
+		m.exception(isa.ExIllegal)
+		return 0, false
+	}
+	m.halted = true
+	return next, true
+}
+
+func execRti(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if !m.kernel() {
+		m.exception(isa.ExIllegal)
+		return 0, false
+	}
+	m.inExc = false
+	m.spr[isa.SprPsw] |= isa.PswIe
+	return m.spr[isa.SprEpc], true
+}
+
+func execDi(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if !m.kernel() {
+		m.exception(isa.ExIllegal)
+		return 0, false
+	}
+	m.spr[isa.SprPsw] &^= isa.PswIe
+	return next, true
+}
+
+func execEi(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	if !m.kernel() {
+		m.exception(isa.ExIllegal)
+		return 0, false
+	}
+	m.spr[isa.SprPsw] |= isa.PswIe
+	return next, true
+}
+
+func execBrk(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	// No debugger attached yet: treat as halt so runaway
+	// programs containing brk do not spin.
+	m.halted = true
+	return next, true
+}
+
+func execWfi(m *Machine, d *decoded, next uint16) (uint16, bool) {
+	// Issue stops here until an interrupt is pending. The run
+	// loop sleeps the host thread while idle is set instead of
+	// spinning on Step.
+	if !m.io.Pending() {
+		m.idle = true
+	}
+	return next, true
+}
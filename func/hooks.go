@@ -0,0 +1,67 @@
+// Instrumentation hooks for the emulator. External code (coverage
+// collectors, tracers, custom monitors) observes the interpreter by
+// registering a Hooks implementation instead of patching the core
+// loop. Every hook site is guarded by a single length check, so a
+// machine with no hooks registered pays one predictable branch per
+// site and nothing else.
+
+package main
+
+// Hooks is the observer interface for one instrumented Machine.
+// Implementations that only care about some events embed NopHooks
+// and override the rest.
+type Hooks interface {
+	// OnFetch runs after instruction fetch, before execution.
+	OnFetch(m *Machine, pc, w uint16)
+
+	// OnExecute runs after an instruction completes without taking
+	// an exception; pc and w are the instruction that ran, not the
+	// successor.
+	OnExecute(m *Machine, pc, w uint16)
+
+	// OnMemAccess runs on every data memory access: loads, stores,
+	// and both halves of tas. size is 1 or 2 bytes; value is the
+	// value read or written.
+	OnMemAccess(m *Machine, addr uint16, size int, write bool, value uint16)
+
+	// OnException runs when an exception or interrupt is taken,
+	// before the machine state changes; pc is the faulting pc.
+	OnException(m *Machine, cause, pc uint16)
+}
+
+// NopHooks implements Hooks with empty methods, for embedding.
+type NopHooks struct{}
+
+func (NopHooks) OnFetch(*Machine, uint16, uint16)                {}
+func (NopHooks) OnExecute(*Machine, uint16, uint16)              {}
+func (NopHooks) OnMemAccess(*Machine, uint16, int, bool, uint16) {}
+func (NopHooks) OnException(*Machine, uint16, uint16)            {}
+
+// AddHook registers an observer. Hooks run in registration order.
+func (m *Machine) AddHook(h Hooks) {
+	m.hooks = append(m.hooks, h)
+}
+
+func (m *Machine) fetchHook(pc, w uint16) {
+	for _, h := range m.hooks {
+		h.OnFetch(m, pc, w)
+	}
+}
+
+func (m *Machine) execHook(pc, w uint16) {
+	for _, h := range m.hooks {
+		h.OnExecute(m, pc, w)
+	}
+}
+
+func (m *Machine) memHook(addr uint16, size int, write bool, value uint16) {
+	for _, h := range m.hooks {
+		h.OnMemAccess(m, addr, size, write, value)
+	}
+}
+
+func (m *Machine) excHook(cause, pc uint16) {
+	for _, h := range m.hooks {
+		h.OnException(m, cause, pc)
+	}
+}
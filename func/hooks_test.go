@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// recorder collects every hook event for inspection.
+type recorder struct {
+	NopHooks
+	fetches    int
+	executes   int
+	mems       []string
+	exceptions []uint16
+}
+
+func (r *recorder) OnFetch(m *Machine, pc, w uint16)   { r.fetches++ }
+func (r *recorder) OnExecute(m *Machine, pc, w uint16) { r.executes++ }
+
+func (r *recorder) OnMemAccess(m *Machine, addr uint16, size int, write bool, value uint16) {
+	kind := "r"
+	if write {
+		kind = "w"
+	}
+	r.mems = append(r.mems, kind)
+	_ = addr
+	_ = size
+	_ = value
+}
+
+func (r *recorder) OnException(m *Machine, cause, pc uint16) {
+	r.exceptions = append(r.exceptions, cause)
+}
+
+func testMachine(prog ...uint16) *Machine {
+	m := NewMachine(NewIoSystem())
+	copy(m.imem[isa.VectorBase:], prog)
+	return m
+}
+
+func stepN(m *Machine, max int) {
+	for i := 0; i < max && m.Step(); i++ {
+	}
+}
+
+func TestHookEvents(t *testing.T) {
+	m := testMachine(
+		isa.EncodeMem(isa.OpAdi, 1, 0, 5),  // adi r1, r0, 5
+		isa.EncodeMem(isa.OpStw, 1, 0, 10), // stw r1, r0, 10
+		isa.EncodeMem(isa.OpLdw, 2, 0, 10), // ldw r2, r0, 10
+		isa.EncodeVop(isa.VopHlt),
+	)
+	r := &recorder{}
+	m.AddHook(r)
+	stepN(m, 10)
+	if r.fetches != 4 || r.executes != 4 {
+		t.Errorf("fetches=%d executes=%d, want 4 and 4", r.fetches, r.executes)
+	}
+	if len(r.mems) != 2 || r.mems[0] != "w" || r.mems[1] != "r" {
+		t.Errorf("memory accesses = %v, want [w r]", r.mems)
+	}
+	if len(r.exceptions) != 0 {
+		t.Errorf("unexpected exceptions %v", r.exceptions)
+	}
+}
+
+func TestHookException(t *testing.T) {
+	// An odd word address faults; the hook sees the cause and the
+	// faulting pc before the state changes.
+	m := testMachine(isa.EncodeMem(isa.OpLdw, 1, 0, 3))
+	r := &recorder{}
+	m.AddHook(r)
+	m.Step()
+	if len(r.exceptions) != 1 || r.exceptions[0] != isa.ExMemory {
+		t.Errorf("exceptions = %v, want [ExMemory]", r.exceptions)
+	}
+	// The faulting instruction must not report completion.
+	if r.executes != 0 {
+		t.Errorf("executes = %d, want 0", r.executes)
+	}
+}
+
+func TestNoHooksStillRuns(t *testing.T) {
+	m := testMachine(
+		isa.EncodeMem(isa.OpAdi, 1, 0, 7),
+		isa.EncodeVop(isa.VopHlt),
+	)
+	stepN(m, 10)
+	if !m.halted || m.reg[1] != 7 {
+		t.Errorf("halted=%v r1=%d, want true and 7", m.halted, m.reg[1])
+	}
+}
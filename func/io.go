@@ -0,0 +1,302 @@
+// The emulated I/O system: a 16-bit port space accessed by lio/sio,
+// with devices that can raise interrupts. Device activity is pushed
+// through an event channel so the run loop can sleep, rather than
+// spin, while the CPU is idle in wfi.
+
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// Console device ports.
+const (
+	PortConOut    = 0 // write: transmit low byte to stdout
+	PortConIn     = 1 // read: next received byte, 0 if none
+	PortConStatus = 2 // read: bit 0 set when input is available
+)
+
+// Math unit ports. Reading a result port computes from the current
+// operands; the operands are not consumed.
+const (
+	PortMathPresent = 8  // read: MathPresent when the unit is attached
+	PortMathOpA     = 9  // write: operand A
+	PortMathOpB     = 10 // write: operand B
+	PortMathProdLo  = 11 // read: low word of A*B
+	PortMathProdHi  = 12 // read: high word of A*B
+	PortMathQuot    = 13 // read: A/B unsigned, 0xFFFF when B is 0
+	PortMathRem     = 14 // read: A%B unsigned, A when B is 0
+)
+
+// MathPresent is the value PortMathPresent reads when the math unit
+// is attached; software probes it before using the unit.
+const MathPresent = 0x4D44 // "MD"
+
+// Semihosting file-input ports (-in). Unlike the console, the file
+// device can signal end of input, which the self-hosting path needs:
+// a native tool reading its source through this device must know
+// when the source ends.
+const (
+	PortFileData   = 16 // read: next byte, 0xFFFF past end
+	PortFileStatus = 17 // read: FileHasData or FileAtEof
+)
+
+// PortFileStatus bits.
+const (
+	FileHasData = 1 << 0
+	FileAtEof   = 1 << 1
+)
+
+// A Device backs one or more I/O ports.
+type Device interface {
+	Read(port uint16) uint16
+	Write(port uint16, v uint16)
+	// Pending reports whether the device has an interrupt to deliver.
+	Pending() bool
+}
+
+// IoSystem routes lio/sio to devices and aggregates their interrupt
+// lines. Each device has an interrupt priority, fixed at attach time
+// as real controllers fix it by wiring; a source is delivered only
+// when its priority exceeds the ipl SPR, which is how a handler
+// re-enables interrupts for more urgent sources while it runs.
+type IoSystem struct {
+	ports map[uint16]Device
+	devs  []devEntry
+	// event is signalled (non-blockingly) whenever a device changes
+	// state asynchronously; the run loop selects on it while idle.
+	event chan struct{}
+	// ticks are the simulated-clock subscribers; see clock.go.
+	ticks []tickEntry
+}
+
+type devEntry struct {
+	dev Device
+	pri int
+}
+
+func NewIoSystem() *IoSystem {
+	return &IoSystem{
+		ports: make(map[uint16]Device),
+		event: make(chan struct{}, 1),
+	}
+}
+
+// AddDevice attaches a device at the given ports with priority 1,
+// one above the reset ipl, so its interrupts are delivered unless
+// the kernel raises the level.
+func (io *IoSystem) AddDevice(d Device, ports ...uint16) {
+	io.AddDeviceAt(1, d, ports...)
+}
+
+// AddDeviceAt attaches a device at the given ports and interrupt
+// priority. Higher numbers are more urgent.
+func (io *IoSystem) AddDeviceAt(pri int, d Device, ports ...uint16) {
+	io.devs = append(io.devs, devEntry{d, pri})
+	for _, p := range ports {
+		io.ports[p] = d
+	}
+}
+
+func (io *IoSystem) Read(port uint16) uint16 {
+	if d, ok := io.ports[port]; ok {
+		return d.Read(port)
+	}
+	return 0
+}
+
+func (io *IoSystem) Write(port uint16, v uint16) {
+	if d, ok := io.ports[port]; ok {
+		d.Write(port, v)
+	}
+}
+
+// Mapped reports whether a device backs the given port.
+func (io *IoSystem) Mapped(port uint16) bool {
+	_, ok := io.ports[port]
+	return ok
+}
+
+// Pending reports whether any device has an interrupt to deliver,
+// regardless of priority; it is what ends a wfi idle.
+func (io *IoSystem) Pending() bool {
+	for _, e := range io.devs {
+		if e.dev.Pending() {
+			return true
+		}
+	}
+	return false
+}
+
+// PendingAbove reports whether a device with priority greater than
+// level has an interrupt to deliver.
+func (io *IoSystem) PendingAbove(level int) bool {
+	for _, e := range io.devs {
+		if e.pri > level && e.dev.Pending() {
+			return true
+		}
+	}
+	return false
+}
+
+// Event returns the channel signalled on asynchronous device activity.
+func (io *IoSystem) Event() <-chan struct{} {
+	return io.event
+}
+
+// Kick signals the event channel without blocking. Devices call it
+// from their background goroutines.
+func (io *IoSystem) Kick() {
+	select {
+	case io.event <- struct{}{}:
+	default:
+	}
+}
+
+// MathUnit is the memory-mapped multiply/divide peripheral. It is
+// purely combinational from the emulator's point of view: results are
+// computed on demand from the operand registers and it never
+// interrupts.
+type MathUnit struct {
+	a, b uint16
+}
+
+func (u *MathUnit) Read(port uint16) uint16 {
+	switch port {
+	case PortMathPresent:
+		return MathPresent
+	case PortMathProdLo:
+		return uint16(uint32(u.a) * uint32(u.b))
+	case PortMathProdHi:
+		return uint16(uint32(u.a) * uint32(u.b) >> 16)
+	case PortMathQuot:
+		if u.b == 0 {
+			return 0xFFFF
+		}
+		return u.a / u.b
+	case PortMathRem:
+		if u.b == 0 {
+			return u.a
+		}
+		return u.a % u.b
+	}
+	return 0
+}
+
+func (u *MathUnit) Write(port uint16, v uint16) {
+	switch port {
+	case PortMathOpA:
+		u.a = v
+	case PortMathOpB:
+		u.b = v
+	}
+}
+
+func (u *MathUnit) Pending() bool { return false }
+
+// FileInput is the semihosting input device: a host file exposed as
+// a byte stream with an explicit end-of-input indication. It is
+// entirely synchronous and never interrupts.
+type FileInput struct {
+	data []byte
+	pos  int
+}
+
+func NewFileInput(data []byte) *FileInput {
+	return &FileInput{data: data}
+}
+
+func (f *FileInput) Read(port uint16) uint16 {
+	switch port {
+	case PortFileData:
+		if f.pos >= len(f.data) {
+			return 0xFFFF
+		}
+		v := uint16(f.data[f.pos])
+		f.pos++
+		return v
+	case PortFileStatus:
+		if f.pos < len(f.data) {
+			return FileHasData
+		}
+		return FileAtEof
+	}
+	return 0
+}
+
+func (f *FileInput) Write(port uint16, v uint16) {}
+func (f *FileInput) Pending() bool               { return false }
+
+// Console is the standard character device: output bytes go to the
+// writer the console was built with (stdout unless -conout redirects
+// it), input bytes come from stdin via a background reader, and
+// available input raises an interrupt.
+type Console struct {
+	io  *IoSystem
+	out io.Writer
+	mu  sync.Mutex
+	buf []byte
+}
+
+func NewConsole(io *IoSystem, out io.Writer) *Console {
+	c := &Console{io: io, out: out}
+	go c.reader()
+	return c
+}
+
+// NewBufferedConsole returns a console whose entire input is known up
+// front: no background reader, so input availability (and therefore
+// interrupt timing) does not depend on host scheduling. This is the
+// console -deterministic uses.
+func NewBufferedConsole(io *IoSystem, input []byte, out io.Writer) *Console {
+	return &Console{io: io, out: out, buf: input}
+}
+
+func (c *Console) reader() {
+	b := make([]byte, 256)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n > 0 {
+			c.mu.Lock()
+			c.buf = append(c.buf, b[:n]...)
+			c.mu.Unlock()
+			c.io.Kick()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *Console) Read(port uint16) uint16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch port {
+	case PortConIn:
+		if len(c.buf) == 0 {
+			return 0
+		}
+		v := uint16(c.buf[0])
+		c.buf = c.buf[1:]
+		return v
+	case PortConStatus:
+		if len(c.buf) != 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (c *Console) Write(port uint16, v uint16) {
+	if port == PortConOut {
+		c.out.Write([]byte{byte(v)})
+	}
+}
+
+func (c *Console) Pending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.buf) != 0
+}
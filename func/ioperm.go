@@ -0,0 +1,70 @@
+// User-mode I/O permission. I/O is kernel business, but a kernel may
+// want to hand one process direct access to one device — a frame
+// buffer, say — without taking a trap per transfer. The permission
+// map holds one bit per port; user-mode lio and sio consult it and
+// raise ExIoPerm, with the port in far, when the bit is clear. The
+// map resets to all clear, so user-mode I/O is denied until a kernel
+// grants it. Kernel-mode I/O is never checked.
+//
+// Like the MMU, the map is programmed through I/O ports: write a
+// group number (port/16) to PortIopIndex, then read or write that
+// group's sixteen permission bits through PortIopBits. The map ports
+// themselves start denied like every other port, so a user process
+// cannot grant itself access.
+
+package main
+
+import "github.com/gmofishsauce/y4/isa"
+
+// Permission map ports.
+const (
+	PortIopIndex = 28 // port group number 0..4095 selected for PortIopBits
+	PortIopBits  = 29 // the selected group's bits; bit n covers port group*16+n
+)
+
+// IoPerm is both the bitmap user-mode lio/sio consult and the device
+// a kernel programs it through.
+type IoPerm struct {
+	index uint16
+	bits  [1 << 12]uint16
+}
+
+func NewIoPerm() *IoPerm { return &IoPerm{} }
+
+func (p *IoPerm) Read(port uint16) uint16 {
+	switch port {
+	case PortIopIndex:
+		return p.index
+	case PortIopBits:
+		return p.bits[p.index]
+	}
+	return 0
+}
+
+func (p *IoPerm) Write(port uint16, v uint16) {
+	switch port {
+	case PortIopIndex:
+		p.index = v & 0xFFF
+	case PortIopBits:
+		p.bits[p.index] = v
+	}
+}
+
+func (p *IoPerm) Pending() bool { return false }
+
+// UserOk reports whether user mode may access the given port.
+func (p *IoPerm) UserOk(port uint16) bool {
+	return p.bits[port>>4]>>(port&0xF)&1 != 0
+}
+
+// ioPermitted checks one user-mode I/O access against the map,
+// raising ExIoPerm itself on denial. Kernel accesses pass untouched.
+func (m *Machine) ioPermitted(port, ftype uint16) bool {
+	if m.kernel() || (m.iop != nil && m.iop.UserOk(port)) {
+		return true
+	}
+	m.spr[isa.SprFar] = port
+	m.spr[isa.SprFtype] = ftype
+	m.exception(isa.ExIoPerm)
+	return false
+}
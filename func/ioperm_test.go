@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// User-mode I/O is denied by default and raises ExIoPerm with the
+// port in far; kernel-mode I/O is never checked.
+func TestIoPermDenied(t *testing.T) {
+	io := NewIoSystem()
+	iop := NewIoPerm()
+	io.AddDevice(iop, PortIopIndex, PortIopBits)
+	m := NewMachine(io)
+	m.AttachIoPerm(iop)
+	m.imem[0] = isa.EncodeMem(isa.OpAdi, 2, 0, int(PortConStatus))
+	m.imem[1] = isa.EncodeIo(isa.YopLio, 3, 2)
+	m.imem[2] = isa.EncodeVop(isa.VopHlt)
+	m.imem[isa.VectorBase+isa.ExIoPerm] = isa.EncodeVop(isa.VopHlt)
+	m.spr[isa.SprPsw] &^= isa.PswK
+	stepN(m, 10)
+	if m.spr[isa.SprCause] != isa.ExIoPerm {
+		t.Fatalf("cause = %d, want ExIoPerm", m.spr[isa.SprCause])
+	}
+	if m.spr[isa.SprFar] != PortConStatus || m.spr[isa.SprFtype] != isa.FtRead {
+		t.Errorf("far=0x%04X ftype=%d", m.spr[isa.SprFar], m.spr[isa.SprFtype])
+	}
+}
+
+// A granted bit lets the same user-mode access through, and the
+// kernel programs the grant over the map's own ports.
+func TestIoPermGranted(t *testing.T) {
+	io := NewIoSystem()
+	io.AddDevice(NewBufferedConsole(io, []byte("x"), os.Stdout),
+		PortConOut, PortConIn, PortConStatus)
+	iop := NewIoPerm()
+	io.AddDevice(iop, PortIopIndex, PortIopBits)
+	m := NewMachine(io)
+	m.AttachIoPerm(iop)
+	// Program the map the way a kernel would: select the group, then
+	// set the bit for PortConStatus.
+	io.Write(PortIopIndex, PortConStatus>>4)
+	io.Write(PortIopBits, 1<<(PortConStatus&0xF))
+	if !iop.UserOk(PortConStatus) || iop.UserOk(PortConIn) {
+		t.Fatalf("grant bits wrong: %04X", iop.bits[0])
+	}
+	m.imem[0] = isa.EncodeMem(isa.OpAdi, 2, 0, int(PortConStatus))
+	m.imem[1] = isa.EncodeIo(isa.YopLio, 3, 2)
+	m.imem[2] = isa.EncodeMem(isa.OpBeq, 0, 0, -1) // user mode cannot hlt
+	m.spr[isa.SprPsw] &^= isa.PswK
+	stepN(m, 10)
+	if m.spr[isa.SprCause] != 0 {
+		t.Fatalf("cause = %d, want no exception", m.spr[isa.SprCause])
+	}
+	if m.reg[3] != 1 {
+		t.Errorf("r3 = %d, want console status 1", m.reg[3])
+	}
+}
@@ -0,0 +1,374 @@
+// Machine state and the instruction interpreter for the WUT-4
+// functional emulator. The emulator is behavioral only: one call to
+// Step is one architectural instruction, with no modeling of the
+// pipeline or memory timing.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+const (
+	ImemWords = 64 * 1024
+	DmemBytes = 64 * 1024
+)
+
+// PollInterval is how many cycles run between scans of the device
+// interrupt lines. The scan rides the simulated clock (see clock.go)
+// rather than a private countdown, so it is batched: an interrupt may
+// be taken up to PollInterval-1 instructions after the device raises
+// it, which the architecture does not forbid (interrupt latency was
+// never specified as one instruction).
+const PollInterval = 64
+
+// Machine is the full architectural state of one WUT-4 CPU plus the
+// attached I/O system.
+type Machine struct {
+	reg  [8]uint16
+	spr  [isa.NumSpr]uint16
+	pc   uint16
+	imem []uint16
+	dmem []byte
+
+	halted bool
+	idle   bool // set by wfi, cleared when an interrupt is pending
+	inExc  bool // an exception handler is active (for double faults)
+	cycles uint64
+
+	io    *IoSystem
+	hooks []Hooks
+
+	// dec is the predecoded image of imem, built lazily on the first
+	// Step so callers can fill imem however they like after NewMachine.
+	dec []decoded
+
+	// hist is a ring of the last excHistSize exceptions, dumped when a
+	// double fault proves unrecoverable; histN counts all exceptions.
+	hist  [excHistSize]excRecord
+	histN uint64
+
+	// strict makes conformance violations fault instead of warn; see
+	// SetStrict. warned dedups the permissive-mode warnings.
+	strict bool
+	warned map[string]bool
+
+	// isaRev is the ISA revision being emulated; instructions newer
+	// than it decode as illegal. See SetIsaRev.
+	isaRev int
+
+	// guards are dmem regions that fault on any access; see AddGuard.
+	guards []guardRegion
+
+	// mmu, when attached and enabled, translates data addresses; see
+	// mmu.go. nil means physical addressing, the common case.
+	mmu *Mmu
+
+	// iop, when attached, is the user-mode I/O permission map; see
+	// ioperm.go. nil denies all user-mode I/O.
+	iop *IoPerm
+
+	// emu, when attached, gets first claim on illegal words; see
+	// emulate.go.
+	emu Emulator
+}
+
+// guardRegion is one inclusive dmem address range under -guard.
+type guardRegion struct {
+	lo, hi uint16
+}
+
+// excRecord is one entry in the exception history ring, captured
+// before the exception changes any state.
+type excRecord struct {
+	cycle uint64
+	pc    uint16
+	cause uint16
+	user  bool
+}
+
+const excHistSize = 8
+
+func NewMachine(io *IoSystem) *Machine {
+	m := &Machine{
+		imem:   make([]uint16, ImemWords),
+		dmem:   make([]byte, DmemBytes),
+		io:     io,
+		warned: make(map[string]bool),
+		isaRev: isa.Version,
+	}
+	m.Reset()
+	return m
+}
+
+// SetStrict selects conformance handling for behavior real hardware
+// rejects: accesses to reserved SPRs, writes to the read-only cause
+// register, I/O on unmapped ports. Strict mode faults; the permissive
+// default warns once per distinct condition and carries on, so
+// software can be hardened toward the real machine incrementally.
+func (m *Machine) SetStrict(v bool) { m.strict = v }
+
+// SetIsaRev selects the ISA revision to emulate, 1..isa.Version.
+// Instructions introduced after rev decode as illegal, so software
+// targeting an old machine can be tested for accidental use of newer
+// features. Any predecoded image is discarded.
+func (m *Machine) SetIsaRev(rev int) {
+	m.isaRev = rev
+	m.dec = nil
+}
+
+// AttachMmu wires a translation unit into the memory stage. The
+// caller also attaches it to the I/O system so software can program
+// the map.
+func (m *Machine) AttachMmu(u *Mmu) { m.mmu = u }
+
+// AttachIoPerm wires the user-mode I/O permission map into lio/sio.
+// The caller also attaches it to the I/O system so software can
+// program the bits.
+func (m *Machine) AttachIoPerm(p *IoPerm) { m.iop = p }
+
+// AddGuard makes any load or store touching lo..hi (inclusive) take
+// a memory exception. Guard regions catch overflows across placement
+// boundaries — a kernel heap growing into the region reserved for a
+// user image, say — the way unmapped pages would on a machine with
+// an MMU.
+func (m *Machine) AddGuard(lo, hi uint16) {
+	m.guards = append(m.guards, guardRegion{lo, hi})
+}
+
+// guarded reports whether an access of size bytes at addr touches a
+// guard region. Callers check len(m.guards) first to keep the common
+// case off the memory path.
+func (m *Machine) guarded(addr uint16, size int) bool {
+	end := int(addr) + size - 1
+	for _, g := range m.guards {
+		if int(addr) <= int(g.hi) && end >= int(g.lo) {
+			return true
+		}
+	}
+	return false
+}
+
+// violate handles one conformance violation: in strict mode it takes
+// an exception with the given cause and reports true, otherwise it
+// warns once per key and reports false so execution continues.
+func (m *Machine) violate(cause uint16, key, format string, args ...interface{}) bool {
+	if m.strict {
+		m.exception(cause)
+		return true
+	}
+	if !m.warned[key] {
+		m.warned[key] = true
+		fmt.Fprintf(os.Stderr, "func: warning: "+format+"\n", args...)
+	}
+	return false
+}
+
+// Reset puts the machine in the architected power-on state: kernel
+// mode, interrupts disabled, pc at the reset vector.
+func (m *Machine) Reset() {
+	for i := range m.reg {
+		m.reg[i] = 0
+	}
+	for i := range m.spr {
+		m.spr[i] = 0
+	}
+	m.spr[isa.SprPsw] = isa.PswK
+	m.pc = isa.VectorBase
+	m.halted = false
+	m.idle = false
+	m.inExc = false
+	m.histN = 0
+}
+
+func (m *Machine) kernel() bool { return m.spr[isa.SprPsw]&isa.PswK != 0 }
+func (m *Machine) intEnabled() bool {
+	return m.spr[isa.SprPsw]&isa.PswIe != 0
+}
+
+func (m *Machine) setCarry(c bool) {
+	if c {
+		m.spr[isa.SprPsw] |= isa.PswC
+	} else {
+		m.spr[isa.SprPsw] &^= isa.PswC
+	}
+}
+
+// get reads a general register. Register 0 always reads as zero.
+func (m *Machine) get(r int) uint16 {
+	if r == 0 {
+		return 0
+	}
+	return m.reg[r]
+}
+
+// put writes a general register. Writes to register 0 are discarded.
+func (m *Machine) put(r int, v uint16) {
+	if r != 0 {
+		m.reg[r] = v
+	}
+}
+
+// loadSpr and storeSpr are the one statement of the SPR access rules,
+// shared by every path that touches an SPR on behalf of the program.
+// Reserved numbers (NumAssignedSpr..NumSpr-1) read as zero and ignore
+// writes, with the usual -strict escalation to ExIllegal; the cause
+// register is read-only the same way. The reserved slots deliberately
+// have no backing store in permissive mode, so software cannot grow a
+// dependence on a scratch register real hardware will not have.
+// Privilege is the caller's problem: lsp/ssp fault in user mode
+// before these rules apply.
+
+// loadSpr reads an SPR. It reports false when a violation faulted.
+func (m *Machine) loadSpr(n int) (uint16, bool) {
+	if n >= isa.NumAssignedSpr {
+		if m.violate(isa.ExIllegal, fmt.Sprintf("lsp%d", n),
+			"read of reserved spr %d at pc 0x%04X", n, m.pc) {
+			return 0, false
+		}
+		return 0, true
+	}
+	return m.spr[n], true
+}
+
+// storeSpr writes an SPR. It reports false when a violation faulted.
+func (m *Machine) storeSpr(n int, v uint16) bool {
+	if n >= isa.NumAssignedSpr {
+		return !m.violate(isa.ExIllegal, fmt.Sprintf("ssp%d", n),
+			"write to reserved spr %d at pc 0x%04X", n, m.pc)
+	}
+	if n == isa.SprCause {
+		return !m.violate(isa.ExIllegal, "sspcause",
+			"write to read-only spr %d (cause) at pc 0x%04X", n, m.pc)
+	}
+	m.spr[n] = v
+	return true
+}
+
+// memFault records the faulting address and access type in the far
+// and ftype SPRs and raises ExMemory, so a kernel can print a useful
+// message or page the address in.
+func (m *Machine) memFault(addr, ftype uint16) {
+	m.spr[isa.SprFar] = addr
+	m.spr[isa.SprFtype] = ftype
+	m.exception(isa.ExMemory)
+}
+
+// exception diverts control to the vector for the given cause. Taking
+// an exception while one is already active is a double fault; a fault
+// inside the double fault handler itself (the cause register still
+// holds ExDouble) is unrecoverable and stops the machine with the
+// exception history, which is usually enough to see the chain that
+// led here.
+func (m *Machine) exception(cause uint16) {
+	if m.inExc {
+		if m.spr[isa.SprCause] == isa.ExDouble {
+			fmt.Fprintln(os.Stderr, "func: unrecoverable double fault, machine halted")
+			m.dumpExcHistory(os.Stderr)
+			m.halted = true
+			return
+		}
+		m.exceptionRaw(isa.ExDouble)
+		return
+	}
+	m.exceptionRaw(cause)
+}
+
+func (m *Machine) exceptionRaw(cause uint16) {
+	if len(m.hooks) != 0 {
+		m.excHook(cause, m.pc)
+	}
+	m.hist[m.histN%excHistSize] = excRecord{
+		cycle: m.cycles,
+		pc:    m.pc,
+		cause: cause,
+		user:  !m.kernel(),
+	}
+	m.histN++
+	m.inExc = true
+	m.spr[isa.SprCause] = cause
+	m.spr[isa.SprEpc] = m.pc
+	m.spr[isa.SprPsw] |= isa.PswK
+	m.spr[isa.SprPsw] &^= isa.PswIe
+	m.pc = m.spr[isa.SprVbase] + cause
+	m.idle = false
+}
+
+// dumpExcHistory prints the exception ring, oldest first, with each
+// faulting instruction disassembled.
+func (m *Machine) dumpExcHistory(w io.Writer) {
+	start := uint64(0)
+	if m.histN > excHistSize {
+		start = m.histN - excHistSize
+	}
+	for i := start; i < m.histN; i++ {
+		r := &m.hist[i%excHistSize]
+		mode := "kernel"
+		if r.user {
+			mode = "user"
+		}
+		fmt.Fprintf(w, "  cycle %d: cause=%d (%s) pc=0x%04X (%s) %s\n",
+			r.cycle, r.cause, isa.CauseName(r.cause), r.pc, mode,
+			isa.Disassemble(m.imem[r.pc], int(r.pc)))
+	}
+}
+
+// Step executes one instruction. It returns false when the machine
+// has halted. The execute stage is a single indexed call through
+// execTab on the predecoded kind; see decode.go and exec.go.
+func (m *Machine) Step() bool {
+	if m.halted {
+		return false
+	}
+	if m.dec == nil {
+		m.predecode()
+	}
+	m.cycles++
+	if len(m.io.ticks) != 0 {
+		m.io.tick(m.cycles)
+	}
+
+	// An enabled, pending interrupt is taken between instructions,
+	// and always ends an idle (wfi) state. The device scan runs only
+	// at clock batch boundaries — every PollInterval cycles — except
+	// while idle, when waking is the only work left and the scan runs
+	// every cycle.
+	if m.cycles%PollInterval == 0 || m.idle {
+		if m.io.Pending() {
+			m.idle = false
+			// Delivery is gated twice: the psw ie bit, and the ipl
+			// SPR, which masks sources at or below its level. A
+			// handler raises ipl to its source's priority and runs
+			// ei to nest only more urgent interrupts.
+			if m.intEnabled() && m.io.PendingAbove(int(m.spr[isa.SprIpl])) {
+				m.exception(isa.ExIrq)
+				return true
+			}
+		}
+	}
+	if m.idle {
+		return true
+	}
+
+	pc := m.pc
+	d := &m.dec[pc]
+	hooked := len(m.hooks) != 0
+	if hooked {
+		m.fetchHook(pc, m.imem[pc])
+	}
+
+	next, ok := execTab[d.kind](m, d, pc+1)
+	if !ok {
+		return true // exception already taken
+	}
+
+	if hooked {
+		m.execHook(pc, m.imem[pc])
+	}
+	m.pc = next
+	return !m.halted
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// A fault inside the double-fault handler must halt the machine, and
+// the exception history must show the chain that led there.
+func TestUnrecoverableDoubleFault(t *testing.T) {
+	bad := isa.EncodeYop(14, 0) // reserved encoding: illegal
+	m := testMachine(bad)
+	m.imem[isa.VectorBase+isa.ExIllegal] = bad // faulting handler
+	m.imem[isa.VectorBase+isa.ExDouble] = bad  // faulting double-fault handler
+	stepN(m, 10)
+	if !m.halted {
+		t.Fatalf("machine did not halt; pc=0x%04X", m.pc)
+	}
+	var out bytes.Buffer
+	m.dumpExcHistory(&out)
+	for _, want := range []string{
+		"cause=1 (illegal) pc=0x0000",
+		"cause=3 (double) pc=0x0001",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("history missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// Strict mode faults on conformance violations; the permissive
+// default warns and carries on.
+func TestStrictMode(t *testing.T) {
+	prog := []uint16{
+		isa.EncodeSpr(isa.YopSsp, 1, 7), // reserved spr
+		isa.EncodeVop(isa.VopHlt),
+	}
+	m := testMachine(prog...)
+	m.imem[isa.VectorBase+isa.ExIllegal] = isa.EncodeVop(isa.VopHlt)
+	m.SetStrict(true)
+	stepN(m, 10)
+	if got := m.spr[isa.SprCause]; got != isa.ExIllegal {
+		t.Errorf("strict: cause = %d, want ExIllegal", got)
+	}
+
+	m = testMachine(prog...)
+	stepN(m, 10)
+	// pc advances past the hlt at word 1 before the machine stops.
+	if m.spr[isa.SprCause] != 0 || m.pc != isa.VectorBase+2 {
+		t.Errorf("permissive: cause=%d pc=0x%04X, want no fault and halt past 0x%04X",
+			m.spr[isa.SprCause], m.pc, isa.VectorBase+1)
+	}
+}
+
+// Unmapped I/O ports are a memory fault under -strict.
+func TestStrictUnmappedPort(t *testing.T) {
+	m := testMachine(
+		isa.EncodeMem(isa.OpAdi, 2, 0, 63), // port with no device
+		isa.EncodeIo(isa.YopLio, 1, 2),
+		isa.EncodeVop(isa.VopHlt),
+	)
+	m.imem[isa.VectorBase+isa.ExMemory] = isa.EncodeVop(isa.VopHlt)
+	m.SetStrict(true)
+	stepN(m, 10)
+	if got := m.spr[isa.SprCause]; got != isa.ExMemory {
+		t.Errorf("cause = %d, want ExMemory", got)
+	}
+}
+
+// Accesses touching a guard region fault; neighbors are untouched.
+func TestGuardRegion(t *testing.T) {
+	// Word 2 doubles as the ExMemory vector slot, so the program is
+	// just the lui and the faulting store ahead of it.
+	m := testMachine(
+		isa.EncodeLui(2, 1),               // r2 = 64
+		isa.EncodeMem(isa.OpStw, 1, 2, 0), // inside the guard: faults
+		isa.EncodeVop(isa.VopHlt),
+	)
+	m.AddGuard(64, 127)
+	stepN(m, 10)
+	if got := m.spr[isa.SprCause]; got != isa.ExMemory {
+		t.Errorf("cause = %d, want ExMemory", got)
+	}
+	if m.spr[isa.SprEpc] != isa.VectorBase+1 {
+		t.Errorf("epc = 0x%04X, want the guarded store", m.spr[isa.SprEpc])
+	}
+
+	// Without the guard the same store succeeds.
+	m = testMachine(
+		isa.EncodeLui(2, 1),
+		isa.EncodeMem(isa.OpStw, 1, 2, 0),
+		isa.EncodeVop(isa.VopHlt),
+	)
+	stepN(m, 10)
+	if m.spr[isa.SprCause] != 0 {
+		t.Errorf("unguarded store faulted with cause %d", m.spr[isa.SprCause])
+	}
+}
+
+// A kernel can move the vector table by writing the vbase SPR; the
+// next exception dispatches through the relocated table.
+func TestVectorBaseSpr(t *testing.T) {
+	m := testMachine(
+		isa.EncodeLui(2, 1), // r2 = 64
+		isa.EncodeSpr(isa.YopSsp, 2, isa.SprVbase),
+		isa.EncodeYop(14, 0), // reserved: ExIllegal
+	)
+	m.imem[64+isa.ExIllegal] = isa.EncodeVop(isa.VopHlt)
+	stepN(m, 10)
+	if got := m.spr[isa.SprCause]; got != isa.ExIllegal {
+		t.Fatalf("cause = %d, want ExIllegal", got)
+	}
+	if m.pc != 64+isa.ExIllegal+1 {
+		t.Errorf("pc = 0x%04X, want halt past the relocated vector", m.pc)
+	}
+}
+
+// A memory fault records the faulting address and access type in the
+// far and ftype SPRs.
+func TestFaultAddressSpr(t *testing.T) {
+	m := testMachine(
+		isa.EncodeLui(2, 1), // r2 = 64
+		isa.EncodeMem(isa.OpStw, 1, 2, 0),
+		isa.EncodeVop(isa.VopHlt), // doubles as the ExMemory vector
+	)
+	m.AddGuard(64, 127)
+	stepN(m, 10)
+	if m.spr[isa.SprFar] != 64 || m.spr[isa.SprFtype] != isa.FtWrite {
+		t.Errorf("far=0x%04X ftype=%d, want 0x0040/FtWrite",
+			m.spr[isa.SprFar], m.spr[isa.SprFtype])
+	}
+
+	m = testMachine(
+		isa.EncodeMem(isa.OpLdw, 2, 0, 33), // misaligned read
+		isa.EncodeVop(isa.VopHlt),
+		isa.EncodeVop(isa.VopHlt), // ExMemory vector
+	)
+	stepN(m, 10)
+	if m.spr[isa.SprFar] != 33 || m.spr[isa.SprFtype] != isa.FtRead {
+		t.Errorf("far=0x%04X ftype=%d, want 0x0021/FtRead",
+			m.spr[isa.SprFar], m.spr[isa.SprFtype])
+	}
+}
+
+// The SPR access rules, for every index in kernel mode. Assigned
+// registers other than cause round-trip; cause discards writes;
+// reserved numbers read as zero and discard writes. Under -strict
+// every discard is ExIllegal instead.
+func TestSprAccessRules(t *testing.T) {
+	for n := 0; n < isa.NumSpr; n++ {
+		m := testMachine(isa.EncodeVop(isa.VopHlt))
+		if !m.storeSpr(n, 0x123) {
+			t.Errorf("permissive storeSpr(%d) faulted", n)
+		}
+		v, ok := m.loadSpr(n)
+		if !ok {
+			t.Errorf("permissive loadSpr(%d) faulted", n)
+		}
+		switch {
+		case n == isa.SprCause || n >= isa.NumAssignedSpr:
+			if v != 0 {
+				t.Errorf("spr %d = 0x%04X after discarded write, want 0", n, v)
+			}
+		default:
+			if v != 0x123 {
+				t.Errorf("spr %d = 0x%04X, want the written 0x123", n, v)
+			}
+		}
+
+		strict := func(fault bool, what string) {
+			m := testMachine(isa.EncodeVop(isa.VopHlt))
+			m.imem[isa.VectorBase+isa.ExIllegal] = isa.EncodeVop(isa.VopHlt)
+			m.SetStrict(true)
+			var ok bool
+			if what == "store" {
+				ok = m.storeSpr(n, 0x123)
+			} else {
+				_, ok = m.loadSpr(n)
+			}
+			if ok == fault {
+				t.Errorf("strict %s spr %d: faulted=%v, want %v", what, n, !ok, fault)
+			}
+		}
+		strict(n == isa.SprCause || n >= isa.NumAssignedSpr, "store")
+		strict(n >= isa.NumAssignedSpr, "load")
+	}
+}
+
+// In user mode lsp and ssp are ExIllegal for every index: privilege
+// is checked before any per-register rule applies.
+func TestSprUserModeIllegal(t *testing.T) {
+	for n := 0; n < isa.NumSpr; n++ {
+		for _, yop := range []int{isa.YopLsp, isa.YopSsp} {
+			m := testMachine(
+				isa.EncodeSpr(yop, 2, n),
+				isa.EncodeVop(isa.VopHlt), // doubles as the ExIllegal vector
+			)
+			m.spr[isa.SprPsw] &^= isa.PswK
+			stepN(m, 10)
+			if got := m.spr[isa.SprCause]; got != isa.ExIllegal {
+				t.Errorf("user yop %d spr %d: cause = %d, want ExIllegal", yop, n, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,663 @@
+// func is the functional (behavioral) emulator for the WUT-4. It
+// loads a binary image into instruction memory and interprets it at
+// architectural level.
+//
+// Usage: func [options] image.bin
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+	"github.com/gmofishsauce/y4/wire"
+)
+
+var quiet = flag.Bool("q", false, "suppress the run summary")
+var noMath = flag.Bool("nomath", false, "detach the math unit (exercises software fallbacks)")
+var stackBounds = flag.String("stack", "", "warn when sp (r6) leaves lo:hi, e.g. -stack 0x8000:0xFF00")
+var stats = flag.Bool("stats", false, "print machine-readable run statistics")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+var inFile = flag.String("in", "", "attach a file to the semihosting input device")
+var coverFile = flag.String("cover", "", "write a coverage report to this file")
+var taintMode = flag.Bool("taint", false, "track taint from input devices (experimental)")
+var deterministic = flag.Bool("deterministic", false,
+	"fix all sources of nondeterminism so identical runs produce identical traces")
+var benchCycles = flag.Int("benchcycles", 2_000_000, "cycle budget per benchmark (func bench)")
+var linesFile = flag.String("lines", "", "line map from asm -lines, for coverage source positions")
+var entryPoint = flag.String("entry", "", "override the initial pc (default: the image entry point)")
+var initSpr = flag.String("initspr", "", "initial SPR contents, e.g. -initspr 0=3,2=0x100")
+var userStart = flag.Bool("user", false, "start in user mode instead of kernel mode")
+var strict = flag.Bool("strict", false,
+	"fault on behavior real hardware would reject, instead of warning")
+var logSys = flag.String("logsys", "", "log exceptions, rti, mode switches, and SPR writes to this file")
+var symsFile = flag.String("syms", "", "symbol table from asm -syms, for debugger commands")
+var dataFiles = flag.String("data", "",
+	"load raw byte images into dmem, e.g. -data table.bin@0x1000,fs.img@0x4000")
+var poisonFill = flag.String("poison", "",
+	"fill dmem with this byte and warn on read-before-write, e.g. -poison 0xCC")
+var guardSpec = flag.String("guard", "",
+	"dmem regions that fault on access, e.g. -guard 0x7F00:0x7FFF,0xFF00:0xFFFF")
+var isaRev = flag.Int("isa", isa.Version, "ISA revision to emulate; newer instructions are illegal")
+var verifyMem = flag.String("verifymem", "",
+	"compare dmem at halt against a reference, e.g. -verifymem golden.dat:0x100")
+var tracePoints = flag.String("tracepoint", "",
+	"log values at locations without stopping, e.g. -tracepoint 'loop=r2+r3,flush=@bufpos'")
+var traceOut = flag.String("traceout", "", "tracepoint log file (default stderr)")
+var watchLog = flag.String("watchlog", "",
+	"log every write to a dmem range, dumped at halt, e.g. -watchlog count:4 or -watchlog 0x2000")
+var snapEvery = flag.Uint64("snapevery", 0, "save a state snapshot every N cycles; 0 disables")
+var snapKeep = flag.Int("snapkeep", 4, "number of rolling snapshots to keep")
+var snapOut = flag.String("snapout", "", "snapshot path prefix (default: the binary path + .snap)")
+var restoreFile = flag.String("restore", "", "restore a state snapshot before running")
+var emuName = flag.String("emu", "", "attach a trap-and-emulate ISA experiment; see emulate.go")
+var profileFile = flag.String("profile", "",
+	"write a per-function cycle profile to this file; symbolize with -syms")
+var pprofFile = flag.String("pprof", "",
+	"write the function profile in pprof format, for go tool pprof")
+var timeLine = flag.String("timeline", "",
+	"write a Chrome trace-event JSON timeline of the run to this file")
+var timelineCalls = flag.Bool("timelinecalls", false,
+	"include function entry/exit slices in the -timeline export")
+var conOut = flag.String("conout", "",
+	"console output destination: a file path (including a pty device) or tcp:host:port (default stdout)")
+var selfTest = flag.Bool("selftest", false,
+	"run the embedded architectural test ROM and report pass or fail")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("func isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() == 1 && flag.Arg(0) == "bench" {
+		runBench(os.Stdout, *benchCycles)
+		return
+	}
+	if *selfTest {
+		os.Exit(runSelftest(os.Stdout))
+	}
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: func [options] image.bin | func bench")
+		os.Exit(2)
+	}
+
+	out, err := openConsoleOut(*conOut)
+	if err != nil {
+		fatal("%v", err)
+	}
+	io := NewIoSystem()
+	if *deterministic {
+		// All console input is read before the first cycle, so its
+		// arrival (and the interrupts it raises) cannot depend on
+		// host scheduling.
+		io.AddDevice(NewBufferedConsole(io, readAllStdin(), out), PortConOut, PortConIn, PortConStatus)
+	} else {
+		io.AddDevice(NewConsole(io, out), PortConOut, PortConIn, PortConStatus)
+	}
+	if !*noMath {
+		io.AddDevice(&MathUnit{}, PortMathPresent, PortMathOpA, PortMathOpB,
+			PortMathProdLo, PortMathProdHi, PortMathQuot, PortMathRem)
+	}
+	if *inFile != "" {
+		b, err := os.ReadFile(*inFile)
+		if err != nil {
+			fatal("%v", err)
+		}
+		io.AddDevice(NewFileInput(b), PortFileData, PortFileStatus)
+	}
+	mmu := NewMmu()
+	io.AddDevice(mmu, PortMmuCtl, PortMmuIndex, PortMmuMap, PortMmuAsid)
+	iop := NewIoPerm()
+	io.AddDevice(iop, PortIopIndex, PortIopBits)
+	m := NewMachine(io)
+	m.AttachMmu(mmu)
+	m.AttachIoPerm(iop)
+	m.SetStrict(*strict)
+	if *isaRev < 1 || *isaRev > isa.Version {
+		fatal("-isa %d: revisions are 1..%d", *isaRev, isa.Version)
+	}
+	m.SetIsaRev(*isaRev)
+	if *emuName != "" {
+		fn, err := lookupEmulator(*emuName)
+		if err != nil {
+			fatal("%v", err)
+		}
+		m.SetEmulator(fn)
+	}
+
+	if err := applyGuards(m); err != nil {
+		fatal("%v", err)
+	}
+
+	var poison *Poison
+	if *poisonFill != "" {
+		v, err := strconv.ParseUint(*poisonFill, 0, 8)
+		if err != nil {
+			fatal("bad -poison byte %q", *poisonFill)
+		}
+		for i := range m.dmem {
+			m.dmem[i] = byte(v)
+		}
+		poison = NewPoison(os.Stderr)
+		m.AddHook(poison)
+	}
+
+	codeWords, dataBytes, err := loadImage(m, flag.Arg(0))
+	if err != nil {
+		fatal("%v", err)
+	}
+	if poison != nil {
+		poison.MarkWritten(0, dataBytes)
+	}
+
+	if err := loadData(m, poison, dataBytes, flag.Arg(0)); err != nil {
+		fatal("%v", err)
+	}
+
+	if err := applyResetConfig(m); err != nil {
+		fatal("%v", err)
+	}
+
+	if *restoreFile != "" {
+		// The image is still loaded first: the snapshot carries no
+		// symbols or line map, and restoring over the same binary
+		// keeps those flags meaningful.
+		if err := m.RestoreSnapshot(*restoreFile); err != nil {
+			fatal("%v", err)
+		}
+	}
+
+	var cover *Coverage
+	if *coverFile != "" {
+		cover = NewCoverage(codeWords)
+		m.AddHook(cover)
+	}
+	var taint *Taint
+	if *taintMode {
+		taint = NewTaint(os.Stderr)
+		m.AddHook(taint)
+	}
+	if *logSys != "" {
+		f, err := os.Create(*logSys)
+		if err != nil {
+			fatal("%v", err)
+		}
+		defer f.Close()
+		m.AddHook(NewSysLog(f, m))
+	}
+	var syms map[string]uint16
+	if *symsFile != "" && (*tracePoints != "" || *watchLog != "" || *timeLine != "" || *profileFile != "" || *pprofFile != "") {
+		var err error
+		if syms, err = obj.ReadSymsMap(*symsFile); err != nil {
+			fatal("%v", err)
+		}
+	}
+	var wlog *WatchLog
+	if *watchLog != "" {
+		var err error
+		if wlog, err = newWatchLog(*watchLog, syms); err != nil {
+			fatal("%v", err)
+		}
+		m.AddHook(wlog)
+	}
+	if *tracePoints != "" {
+		out := os.Stderr
+		if *traceOut != "" {
+			f, err := os.Create(*traceOut)
+			if err != nil {
+				fatal("%v", err)
+			}
+			defer f.Close()
+			out = f
+		}
+		tp, err := newTracepoints(out, *tracePoints, syms)
+		if err != nil {
+			fatal("%v", err)
+		}
+		m.AddHook(tp)
+	}
+	var tl *Timeline
+	if *timeLine != "" {
+		tl = NewTimeline(m, syms, *timelineCalls)
+		m.AddHook(tl)
+	}
+	var prof *Profile
+	if *profileFile != "" || *pprofFile != "" {
+		prof = NewProfile(syms)
+		if *pprofFile != "" {
+			prof.EnableSampling()
+		}
+		m.AddHook(prof)
+	}
+
+	start := time.Now()
+	run(m)
+	elapsed := time.Since(start)
+	if cover != nil {
+		if err := writeCoverReport(cover); err != nil {
+			fatal("%v", err)
+		}
+	}
+	if taint != nil {
+		taint.Summary(os.Stderr)
+	}
+	if !*quiet {
+		fmt.Fprintf(os.Stderr, "func: halted at pc 0x%04X after %d cycles\n", m.pc, m.cycles)
+	}
+	if wlog != nil {
+		wlog.Dump(os.Stderr)
+	}
+	if tl != nil {
+		f, err := os.Create(*timeLine)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if err := tl.Write(f); err != nil {
+			fatal("%v", err)
+		}
+		f.Close()
+	}
+	if prof != nil && *profileFile != "" {
+		f, err := os.Create(*profileFile)
+		if err != nil {
+			fatal("%v", err)
+		}
+		prof.Report(f)
+		f.Close()
+	}
+	if prof != nil && *pprofFile != "" {
+		f, err := os.Create(*pprofFile)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if err := prof.WritePprof(f); err != nil {
+			fatal("%v", err)
+		}
+		f.Close()
+	}
+	if *verifyMem != "" {
+		if err := doVerifyMem(m, *verifyMem); err != nil {
+			fatal("%v", err)
+		}
+	}
+	if *stats {
+		// One fixed-format line on stderr, for itf's performance
+		// tracking and anything else that wants to scrape it. Under
+		// -deterministic the host clock must not leak into the
+		// output, so the timing fields are fixed at zero.
+		secs := elapsed.Seconds()
+		if *deterministic {
+			secs = 0
+		}
+		cps := 0.0
+		if secs > 0 {
+			cps = float64(m.cycles) / secs
+		}
+		fmt.Fprintf(os.Stderr, "func-stats: cycles=%d seconds=%.6f cps=%.0f\n",
+			m.cycles, secs, cps)
+		if u := m.mmu; u != nil && u.reads[0]+u.reads[1]+u.writes[0]+u.writes[1]+u.misses != 0 {
+			fmt.Fprintf(os.Stderr,
+				"func-mmu-stats: reads-user=%d reads-kernel=%d writes-user=%d writes-kernel=%d misses=%d\n",
+				u.reads[0], u.reads[1], u.writes[0], u.writes[1], u.misses)
+		}
+	}
+}
+
+// doVerifyMem compares a region of dmem after the run against a
+// reference file, so a memory-result test is just a golden file and
+// an exit status instead of a custom harness. The spec is file or
+// file:offset; the region is the length of the file.
+func doVerifyMem(m *Machine, spec string) error {
+	path, offs, has := strings.Cut(spec, ":")
+	off := uint64(0)
+	if has {
+		var err error
+		if off, err = strconv.ParseUint(offs, 0, 16); err != nil {
+			return fmt.Errorf("bad -verifymem offset %q", offs)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if int(off)+len(want) > DmemBytes {
+		return fmt.Errorf("%s: %d bytes at 0x%04X run off dmem", path, len(want), off)
+	}
+	for i, b := range want {
+		if got := m.dmem[int(off)+i]; got != b {
+			return fmt.Errorf("verifymem: dmem[0x%04X] = 0x%02X, want 0x%02X (%s byte %d)",
+				int(off)+i, got, b, path, i)
+		}
+	}
+	return nil
+}
+
+// applyGuards parses -guard and installs the regions. A guard
+// between the kernel's data and the region a user image loads into
+// turns a silent overflow across the boundary into a memory fault.
+func applyGuards(m *Machine) error {
+	if *guardSpec == "" {
+		return nil
+	}
+	for _, r := range strings.Split(*guardSpec, ",") {
+		los, his, ok := strings.Cut(r, ":")
+		lo, err1 := strconv.ParseUint(los, 0, 16)
+		hi, err2 := strconv.ParseUint(his, 0, 16)
+		if !ok || err1 != nil || err2 != nil || lo > hi {
+			return fmt.Errorf("bad -guard region %q (want lo:hi)", r)
+		}
+		m.AddGuard(uint16(lo), uint16(hi))
+	}
+	return nil
+}
+
+// loadData overlays raw byte images from -data onto dmem after the
+// binary's data segment is placed, so test fixtures (lookup tables,
+// filesystem images) can be injected without embedding them in the
+// binary. Each comma-separated entry is file or file@addr; with no
+// @addr the image loads at 0.
+//
+// Every placement — the binary's data segment included — is checked
+// against the others and against the -guard regions, so an image
+// that has quietly grown into its neighbor's space fails at load
+// time with the offending file named, not cycles later with
+// clobbered memory.
+func loadData(m *Machine, poison *Poison, imageBytes int, imagePath string) error {
+	type region struct {
+		lo, hi int
+		name   string
+	}
+	var placed []region
+	add := func(lo, hi int, name string) error {
+		for _, r := range placed {
+			if lo <= r.hi && hi >= r.lo {
+				return fmt.Errorf("%s: bytes 0x%04X-0x%04X overlap %s (0x%04X-0x%04X)",
+					name, lo, hi, r.name, r.lo, r.hi)
+			}
+		}
+		if m.guarded(uint16(lo), hi-lo+1) {
+			return fmt.Errorf("%s: bytes 0x%04X-0x%04X fall in a -guard region", name, lo, hi)
+		}
+		placed = append(placed, region{lo, hi, name})
+		return nil
+	}
+	if imageBytes > 0 {
+		if err := add(0, imageBytes-1, imagePath); err != nil {
+			return err
+		}
+	}
+	if *dataFiles == "" {
+		return nil
+	}
+	for _, spec := range strings.Split(*dataFiles, ",") {
+		path, at, hasAt := strings.Cut(spec, "@")
+		var addr uint64
+		if hasAt {
+			var err error
+			addr, err = strconv.ParseUint(at, 0, 16)
+			if err != nil {
+				return fmt.Errorf("bad -data entry %q (want file or file@addr)", spec)
+			}
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if int(addr)+len(b) > DmemBytes {
+			return fmt.Errorf("%s: %d bytes at 0x%04X overflow dmem", path, len(b), addr)
+		}
+		if len(b) == 0 {
+			continue
+		}
+		if err := add(int(addr), int(addr)+len(b)-1, path); err != nil {
+			return err
+		}
+		copy(m.dmem[addr:], b)
+		if poison != nil {
+			poison.MarkWritten(int(addr), len(b))
+		}
+	}
+	return nil
+}
+
+// applyResetConfig applies -entry, -initspr, and -user on top of the
+// architected reset state and the image entry point, so a trap
+// handler or kernel fragment can be started in an arbitrary state
+// instead of booting through the reset vector.
+func applyResetConfig(m *Machine) error {
+	if *entryPoint != "" {
+		v, err := strconv.ParseUint(*entryPoint, 0, 16)
+		if err != nil {
+			return fmt.Errorf("bad -entry %q", *entryPoint)
+		}
+		m.pc = uint16(v)
+	}
+	if *initSpr != "" {
+		for _, kv := range strings.Split(*initSpr, ",") {
+			ks, vs, ok := strings.Cut(kv, "=")
+			n, err1 := strconv.Atoi(ks)
+			v, err2 := strconv.ParseUint(vs, 0, 16)
+			if !ok || err1 != nil || err2 != nil || n < 0 || n >= isa.NumSpr {
+				return fmt.Errorf("bad -initspr entry %q (want n=value, n 0..%d)", kv, isa.NumSpr-1)
+			}
+			m.spr[n] = uint16(v)
+		}
+	}
+	if *userStart {
+		m.spr[isa.SprPsw] &^= isa.PswK
+	}
+	return nil
+}
+
+// openConsoleOut resolves the -conout destination. Program console
+// output is the only thing written there; all simulator diagnostics
+// stay on stderr, so a harness capturing the destination sees exactly
+// what the program printed. A pty is just a device file here: pass
+// its path.
+func openConsoleOut(spec string) (io.Writer, error) {
+	switch {
+	case spec == "":
+		return os.Stdout, nil
+	case strings.HasPrefix(spec, "tcp:"):
+		return net.Dial("tcp", strings.TrimPrefix(spec, "tcp:"))
+	default:
+		return os.OpenFile(spec, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	}
+}
+
+// readAllStdin drains stdin to end of file.
+func readAllStdin() []byte {
+	var all []byte
+	b := make([]byte, 4096)
+	for {
+		n, err := os.Stdin.Read(b)
+		all = append(all, b[:n]...)
+		if err != nil {
+			return all
+		}
+	}
+}
+
+// run steps the machine until it halts. While the CPU is idle in wfi,
+// the host thread sleeps on the I/O event channel instead of spinning.
+func run(m *Machine) {
+	watch, err := newStackWatch(*stackBounds)
+	if err != nil {
+		fatal("%v", err)
+	}
+	var syms map[string]uint16
+	if *symsFile != "" {
+		if syms, err = obj.ReadSymsMap(*symsFile); err != nil {
+			fatal("%v", err)
+		}
+	}
+	var snap *snapper
+	if *snapEvery > 0 {
+		prefix := *snapOut
+		if prefix == "" {
+			prefix = flag.Arg(0) + ".snap"
+		}
+		if *snapKeep < 1 {
+			fatal("-snapkeep %d: need at least one", *snapKeep)
+		}
+		snap = &snapper{prefix: prefix, every: *snapEvery, keep: *snapKeep}
+	}
+	brk := newBreaker(m, syms)
+	for {
+		if brk.pending() && !brk.prompt() {
+			return
+		}
+		if m.idle {
+			// In deterministic mode no input can arrive after the
+			// start, so an idle machine with nothing pending would
+			// wait forever; end the run instead of hanging CI.
+			if *deterministic {
+				if !m.io.Pending() {
+					fmt.Fprintln(os.Stderr, "func: wfi with no further input in deterministic mode")
+					return
+				}
+			} else {
+				select {
+				case <-m.io.Event():
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		}
+		if !m.Step() {
+			return
+		}
+		if snap != nil {
+			if err := snap.tick(m); err != nil {
+				fatal("%v", err)
+			}
+		}
+		if watch != nil {
+			watch.check(m)
+		}
+	}
+}
+
+// stackWatch implements the optional -stack lo:hi monitoring of the
+// conventional stack register r6. One warning is printed per
+// excursion outside the bounds, not per cycle.
+type stackWatch struct {
+	lo, hi  uint16
+	outside bool
+}
+
+func newStackWatch(spec string) (*stackWatch, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var w stackWatch
+	lo, hi, ok := strings.Cut(spec, ":")
+	if ok {
+		l, err1 := strconv.ParseUint(lo, 0, 16)
+		h, err2 := strconv.ParseUint(hi, 0, 16)
+		if err1 == nil && err2 == nil && l <= h {
+			w.lo, w.hi = uint16(l), uint16(h)
+			return &w, nil
+		}
+	}
+	return nil, fmt.Errorf("bad -stack bounds %q (want lo:hi)", spec)
+}
+
+func (w *stackWatch) check(m *Machine) {
+	sp := m.reg[6]
+	if sp < w.lo || sp > w.hi {
+		if !w.outside {
+			w.outside = true
+			kind := "overflow"
+			if sp > w.hi {
+				kind = "underflow"
+			}
+			fmt.Fprintf(os.Stderr,
+				"func: stack %s: sp=0x%04X outside 0x%04X:0x%04X at pc 0x%04X (cycle %d)\n",
+				kind, sp, w.lo, w.hi, m.pc, m.cycles)
+		}
+	} else {
+		w.outside = false
+	}
+}
+
+// writeCoverReport writes the -cover report, annotated with source
+// lines when -lines was given.
+func writeCoverReport(cover *Coverage) error {
+	var lines map[int]int
+	if *linesFile != "" {
+		var err error
+		if lines, err = loadLineMap(*linesFile); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(*coverFile)
+	if err != nil {
+		return err
+	}
+	cover.Report(f, lines)
+	return f.Close()
+}
+
+// loadImage loads a WUT4 format or WUT-4 ELF binary, or falls back to
+// treating the file as a raw image of little-endian instruction words
+// at word 0.
+// It returns the extent of the code segment in words.
+func loadImage(m *Machine, path string) (codeWords, dataBytes int, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	return loadImageBytes(m, b, path)
+}
+
+// loadImageBytes is loadImage over bytes already in hand; path is
+// only for error messages. The embedded -selftest ROM loads through
+// here without touching the filesystem.
+func loadImageBytes(m *Machine, b []byte, path string) (codeWords, dataBytes int, err error) {
+	if obj.IsObject(b) || obj.IsELF(b) {
+		var im *obj.Image
+		var err error
+		if obj.IsELF(b) {
+			im, err = obj.ReadELF(path)
+		} else {
+			im, err = obj.ReadBytes(b, path)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(im.Code) > ImemWords || len(im.Data) > DmemBytes {
+			return 0, 0, fmt.Errorf("%s: segments larger than memory", path)
+		}
+		if im.IsaRev != 0 && int(im.IsaRev) > m.isaRev {
+			return 0, 0, fmt.Errorf("%s: assembled for ISA revision %d, emulating revision %d",
+				path, im.IsaRev, m.isaRev)
+		}
+		copy(m.imem, im.Code)
+		copy(m.dmem, im.Data)
+		m.pc = im.Entry
+		return len(im.Code), len(im.Data), nil
+	}
+	if len(b)%2 != 0 {
+		return 0, 0, fmt.Errorf("%s: odd length, not a word image", path)
+	}
+	if len(b)/2 > ImemWords {
+		return 0, 0, fmt.Errorf("%s: image larger than imem", path)
+	}
+	for i := 0; i < len(b); i += 2 {
+		m.imem[i/2] = wire.Word(b[i:])
+	}
+	return len(b) / 2, 0, nil
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "func: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A matching region passes; the first differing address is named in
+// the error; a region running off dmem is rejected.
+func TestVerifyMem(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "golden.dat")
+	if err := os.WriteFile(golden, []byte{1, 2, 3}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := testMachine()
+	m.dmem[0x100], m.dmem[0x101], m.dmem[0x102] = 1, 2, 3
+
+	if err := doVerifyMem(m, golden+":0x100"); err != nil {
+		t.Errorf("matching region: %v", err)
+	}
+	m.dmem[0x101] = 9
+	err := doVerifyMem(m, golden+":0x100")
+	if err == nil || !strings.Contains(err.Error(), "0x0101") {
+		t.Errorf("mismatch error = %v, want the differing address", err)
+	}
+	err = doVerifyMem(m, golden+":0xFFFF")
+	if err == nil || !strings.Contains(err.Error(), "run off dmem") {
+		t.Errorf("overflow error = %v", err)
+	}
+}
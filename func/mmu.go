@@ -0,0 +1,210 @@
+// Data-address translation. The 64K byte data space is divided into
+// sixteen 4K pages; a software-loaded map takes each virtual page to
+// a physical page with read and write permission bits. Translation
+// applies to every data access while enabled — a kernel that wants
+// its own view identity-maps its pages — and a miss or permission
+// failure raises ExMemory with far/ftype filled in, which is the
+// hook demand paging needs. Instruction fetch is never translated;
+// imem covers the whole address space.
+//
+// The map is programmed through I/O ports, the same way the math
+// unit is reached: write the virtual page number to PortMmuIndex,
+// then the entry to PortMmuMap. Aligned word accesses cannot cross a
+// page, so one lookup covers any access.
+//
+// Copying to and from user memory is architected on the same ports:
+// the kernel selects the user page with PortMmuIndex, reads the
+// entry back from PortMmuMap to find the physical frame (checking
+// valid, permissions, and ASID itself), and copies through the
+// physical window. A kernel that instead dereferences a user virtual
+// address through the current mapping is flagged — a warning by
+// default, ExMemory under -strict — because whichever mapping is
+// live at the time is rarely the one the pointer came from.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// MMU ports.
+const (
+	PortMmuCtl   = 24 // MmuEnable | MmuKernPhys
+	PortMmuIndex = 25 // virtual page number 0..15 selected for PortMmuMap
+	PortMmuMap   = 26 // the selected entry: MmuValid|MmuRead|MmuWrite|asid|ppage
+	PortMmuAsid  = 27 // the current address space identifier, 0..15
+)
+
+// Map entry bits. The low bits hold the physical page number; an
+// entry matches only when its ASID tag equals the current ASID, so a
+// context switch is one write to PortMmuAsid and stale entries miss
+// instead of mapping the wrong process. The tag costs four flops per
+// entry in hardware; the alternative is rewriting the whole map on
+// every switch.
+const (
+	MmuValid     = 1 << 15
+	MmuRead      = 1 << 8
+	MmuWrite     = 1 << 9
+	MmuAsidShift = 4
+	MmuAsid      = 0xF << MmuAsidShift
+	MmuPage      = 0xF
+)
+
+// Control register bits. MmuKernPhys opens the kernel's physical
+// window: kernel-mode accesses bypass translation entirely, the way
+// the kernel reaches frames it has not mapped — initializing a new
+// process's pages, or copying between address spaces. It went in as
+// a mode bit rather than load/store-physical instructions because
+// the YOP space has a single free slot left.
+const (
+	MmuEnable   = 1 << 0
+	MmuKernPhys = 1 << 1
+)
+
+const mmuPageShift = 12
+
+// MmuPages is the number of map entries: one per 4 KiB virtual page.
+const MmuPages = 1 << (16 - mmuPageShift)
+
+// Mmu is both the translation state the memory stage consults and
+// the device a kernel programs it through.
+type Mmu struct {
+	enabled  bool
+	kernPhys bool
+	index    uint16
+	asid     uint16
+	entries  [MmuPages]uint16
+	// Translation counters, split by mode and access kind; tas
+	// counts as both a read and a write. misses counts failed
+	// lookups of either kind.
+	reads, writes [2]uint64 // indexed 0 user, 1 kernel
+	misses        uint64
+}
+
+func NewMmu() *Mmu { return &Mmu{} }
+
+func (u *Mmu) Read(port uint16) uint16 {
+	switch port {
+	case PortMmuCtl:
+		var v uint16
+		if u.enabled {
+			v |= MmuEnable
+		}
+		if u.kernPhys {
+			v |= MmuKernPhys
+		}
+		return v
+	case PortMmuIndex:
+		return u.index
+	case PortMmuMap:
+		return u.entries[u.index]
+	case PortMmuAsid:
+		return u.asid
+	}
+	return 0
+}
+
+func (u *Mmu) Write(port uint16, v uint16) {
+	switch port {
+	case PortMmuCtl:
+		u.enabled = v&MmuEnable != 0
+		u.kernPhys = v&MmuKernPhys != 0
+	case PortMmuIndex:
+		u.index = v & 0xF
+	case PortMmuMap:
+		u.entries[u.index] = v
+	case PortMmuAsid:
+		u.asid = v & 0xF
+	}
+}
+
+func (u *Mmu) Pending() bool { return false }
+
+// translate maps a virtual data address, counting the access. It
+// reports false on a missing entry or insufficient permission; the
+// caller raises the fault with the virtual address.
+func (u *Mmu) translate(addr, ftype uint16, kernel bool) (uint16, bool) {
+	e := u.entries[addr>>mmuPageShift]
+	if e&MmuValid == 0 || e&MmuAsid>>MmuAsidShift != u.asid ||
+		(ftype&isa.FtRead != 0 && e&MmuRead == 0) ||
+		(ftype&isa.FtWrite != 0 && e&MmuWrite == 0) {
+		u.misses++
+		return 0, false
+	}
+	mode := 0
+	if kernel {
+		mode = 1
+	}
+	if ftype&isa.FtRead != 0 {
+		u.reads[mode]++
+	}
+	if ftype&isa.FtWrite != 0 {
+		u.writes[mode]++
+	}
+	return e&MmuPage<<mmuPageShift | addr&(1<<mmuPageShift-1), true
+}
+
+// dump prints the current mappings as one line per valid entry, and
+// the translation counters.
+func (u *Mmu) dump(w io.Writer) {
+	state := "disabled"
+	if u.enabled {
+		state = "enabled"
+	}
+	fmt.Fprintf(w, "mmu: translation %s, asid %d\n", state, u.asid)
+	any := false
+	for i, e := range u.entries {
+		if e&MmuValid == 0 {
+			continue
+		}
+		any = true
+		flags := []byte("--")
+		if e&MmuRead != 0 {
+			flags[0] = 'r'
+		}
+		if e&MmuWrite != 0 {
+			flags[1] = 'w'
+		}
+		p := e & MmuPage
+		fmt.Fprintf(w, "  0x%04X-0x%04X -> 0x%04X-0x%04X  %s asid %d\n",
+			i<<mmuPageShift, i<<mmuPageShift|0xFFF,
+			p<<mmuPageShift, p<<mmuPageShift|0xFFF, flags, e&MmuAsid>>MmuAsidShift)
+	}
+	if !any {
+		fmt.Fprintln(w, "  no valid entries")
+	}
+	fmt.Fprintf(w, "  reads user=%d kernel=%d writes user=%d kernel=%d misses=%d\n",
+		u.reads[0], u.reads[1], u.writes[0], u.writes[1], u.misses)
+}
+
+// xlate runs a data address through the MMU when translation is on,
+// raising the memory fault itself on failure.
+func (m *Machine) xlate(addr, ftype uint16) (uint16, bool) {
+	if m.mmu == nil || !m.mmu.enabled {
+		return addr, true
+	}
+	if m.mmu.kernPhys && m.kernel() {
+		return addr, true
+	}
+	// Kernel accesses that reach translation are flagged: with
+	// translation enabled the architected way to touch user memory
+	// is to look the page up through the map ports and copy through
+	// the physical window, not to dereference the user's virtual
+	// address through whatever mapping happens to be current.
+	if m.kernel() &&
+		m.violate(isa.ExMemory, "kuser",
+			"kernel dereference of user virtual address 0x%04X at pc 0x%04X", addr, m.pc) {
+		m.spr[isa.SprFar] = addr
+		m.spr[isa.SprFtype] = ftype
+		return 0, false
+	}
+	p, ok := m.mmu.translate(addr, ftype, m.kernel())
+	if !ok {
+		m.memFault(addr, ftype)
+		return 0, false
+	}
+	return p, true
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+func TestPoisonReadBeforeWrite(t *testing.T) {
+	prog := []uint16{
+		isa.EncodeMem(isa.OpStw, 1, 0, 16), // stw r1, r0, 16
+		isa.EncodeMem(isa.OpLdw, 2, 0, 16), // ldw r2, r0, 16: written, quiet
+		isa.EncodeMem(isa.OpLdw, 3, 0, 32), // ldw r3, r0, 32: uninitialized
+		isa.EncodeMem(isa.OpLdw, 3, 0, 32), // repeated read reports once
+		isa.EncodeVop(isa.VopHlt),
+	}
+	m := testMachine(prog...)
+	var out strings.Builder
+	p := NewPoison(&out)
+	p.MarkWritten(0, 8) // pretend a loader placed 8 data bytes
+	m.AddHook(p)
+	stepN(m, 10)
+
+	got := out.String()
+	if strings.Count(got, "func-poison:") != 2 {
+		t.Fatalf("want one report per uninitialized byte, got:\n%s", got)
+	}
+	if !strings.Contains(got, "byte 0x0020") || !strings.Contains(got, "byte 0x0021") {
+		t.Errorf("reports should name bytes 0x0020 and 0x0021, got:\n%s", got)
+	}
+	if strings.Contains(got, "0x0010") {
+		t.Errorf("written address reported:\n%s", got)
+	}
+}
@@ -0,0 +1,142 @@
+// The -pprof export: the function profile in pprof's gzipped
+// protobuf format, so `go tool pprof` (top, flame graphs, the web
+// UI) works on WUT-4 programs. Samples come from the shadow-stack
+// fetch counts, one sample per distinct stack with its cycle count,
+// so the viewer reconstructs the real call graph. The handful of
+// proto fields pprof needs are encoded by hand here; pulling in a
+// protobuf library for one fixed message is not worth a dependency.
+
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"strings"
+)
+
+// pbuf builds a protobuf message: varint (wire type 0) and
+// length-delimited (wire type 2) fields are all profile.proto uses.
+type pbuf struct {
+	b []byte
+}
+
+func (p *pbuf) varint(v uint64) {
+	for v >= 0x80 {
+		p.b = append(p.b, byte(v)|0x80)
+		v >>= 7
+	}
+	p.b = append(p.b, byte(v))
+}
+
+func (p *pbuf) uint(field int, v uint64) {
+	p.varint(uint64(field)<<3 | 0)
+	p.varint(v)
+}
+
+func (p *pbuf) bytes(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+func (p *pbuf) str(field int, s string) {
+	p.bytes(field, []byte(s))
+}
+
+// packed encodes a packed repeated varint field.
+func (p *pbuf) packed(field int, vs []uint64) {
+	var inner pbuf
+	for _, v := range vs {
+		inner.varint(v)
+	}
+	p.bytes(field, inner.b)
+}
+
+// WritePprof serializes the sampled stacks as a gzipped
+// profile.proto message. EnableSampling must have been on for the
+// run, or the profile is empty.
+func (p *Profile) WritePprof(w io.Writer) error {
+	// String table; index 0 must be the empty string.
+	strs := []string{""}
+	strIdx := map[string]uint64{"": 0}
+	intern := func(s string) uint64 {
+		if i, ok := strIdx[s]; ok {
+			return i
+		}
+		strIdx[s] = uint64(len(strs))
+		strs = append(strs, s)
+		return strIdx[s]
+	}
+
+	// One function and one location per distinct frame name, ids
+	// starting at 1, in sorted order so output is reproducible.
+	names := make(map[string]uint64)
+	for key := range p.stacks {
+		for _, name := range strings.Split(key, "\x00") {
+			names[name] = 0
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for i, name := range sorted {
+		names[name] = uint64(i + 1)
+	}
+
+	var prof pbuf
+	var vt pbuf // sample_type: cycles/count
+	vt.uint(1, intern("cycles"))
+	vt.uint(2, intern("count"))
+	prof.bytes(1, vt.b)
+
+	keys := make([]string, 0, len(p.stacks))
+	for key := range p.stacks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		frames := strings.Split(key, "\x00")
+		locs := make([]uint64, len(frames))
+		for i, name := range frames {
+			// pprof wants the leaf first; frames are root first.
+			locs[len(frames)-1-i] = names[name]
+		}
+		var sample pbuf
+		sample.packed(1, locs)
+		sample.packed(2, []uint64{p.stacks[key]})
+		prof.bytes(2, sample.b)
+	}
+
+	for _, name := range sorted {
+		id := names[name]
+		var loc pbuf
+		loc.uint(1, id)
+		var line pbuf
+		line.uint(1, id)
+		loc.bytes(4, line.b)
+		prof.bytes(4, loc.b)
+	}
+	for _, name := range sorted {
+		var fn pbuf
+		fn.uint(1, names[name])
+		fn.uint(2, intern(name))
+		prof.bytes(5, fn.b)
+	}
+	for _, s := range strs {
+		prof.str(6, s)
+	}
+	var pt pbuf // period_type: one sample per cycle
+	pt.uint(1, intern("cycles"))
+	pt.uint(2, intern("count"))
+	prof.bytes(11, pt.b)
+	prof.uint(12, 1)
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(prof.b); err != nil {
+		return err
+	}
+	return z.Close()
+}
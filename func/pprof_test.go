@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// pbWalk decodes the top-level fields of an encoded message, calling
+// visit with each field number and its varint value or sub-message.
+func pbWalk(t *testing.T, b []byte, visit func(field int, v uint64, sub []byte)) {
+	t.Helper()
+	for len(b) > 0 {
+		key, n := pbVarint(b)
+		b = b[n:]
+		field, wire := int(key>>3), key&7
+		switch wire {
+		case 0:
+			v, n := pbVarint(b)
+			b = b[n:]
+			visit(field, v, nil)
+		case 2:
+			l, n := pbVarint(b)
+			b = b[n:]
+			visit(field, 0, b[:l])
+			b = b[l:]
+		default:
+			t.Fatalf("wire type %d in profile", wire)
+		}
+	}
+}
+
+func pbVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i, c := range b {
+		v |= uint64(c&0x7F) << (7 * i)
+		if c < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// The pprof export is valid gzip wrapping a profile whose string
+// table carries the function names and whose samples cover every
+// executed cycle.
+func TestWritePprof(t *testing.T) {
+	m := testMachine(
+		isa.EncodeJlr(1, 0, 3), // call fn
+		isa.EncodeVop(isa.VopHlt),
+		0,
+		isa.EncodeMem(isa.OpAdi, 2, 2, 1), // fn
+		isa.EncodeJlr(0, 1, 0),            // rtl
+	)
+	p := NewProfile(map[string]uint16{"fn": 3})
+	p.EnableSampling()
+	m.AddHook(p)
+	stepN(m, 10)
+
+	var buf bytes.Buffer
+	if err := p.WritePprof(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("not gzip: %v", err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var strs []string
+	var cycles uint64
+	samples := 0
+	pbWalk(t, raw, func(field int, v uint64, sub []byte) {
+		switch field {
+		case 2: // Sample
+			samples++
+			pbWalk(t, sub, func(field int, v uint64, sub []byte) {
+				if field == 2 { // packed value
+					n, _ := pbVarint(sub)
+					cycles += n
+				}
+			})
+		case 6: // string_table entry
+			strs = append(strs, string(sub))
+		}
+	})
+	if samples != 2 { // <top> alone and <top>/fn
+		t.Errorf("samples = %d, want 2", samples)
+	}
+	if cycles != m.cycles {
+		t.Errorf("sampled cycles = %d, want %d", cycles, m.cycles)
+	}
+	names := map[string]bool{}
+	for _, s := range strs {
+		names[s] = true
+	}
+	if len(strs) == 0 || strs[0] != "" || !names["fn"] || !names["<top>"] {
+		t.Errorf("string table = %q", strs)
+	}
+}
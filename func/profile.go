@@ -0,0 +1,184 @@
+// The -profile function-level profiler. A shadow call stack tracks
+// jlr-with-link calls and returns through lnk, attributing every
+// executed cycle to the function on top, and the report at halt is a
+// gprof-like flat profile plus a caller/callee table. As with gprof,
+// inclusive counts for recursive functions overstate: each active
+// frame of a recursion accumulates the same cycles.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+type profNode struct {
+	name       string
+	calls      int
+	excl, incl uint64
+}
+
+type profEdge struct {
+	calls  int
+	cycles uint64
+}
+
+type profFrame struct {
+	name  string
+	start uint64
+}
+
+// Profile accumulates per-function cycle counts via the hooks.
+type Profile struct {
+	NopHooks
+	names   map[uint16]string
+	nodes   map[string]*profNode
+	edges   map[[2]string]*profEdge
+	stack   []profFrame
+	pending bool
+	total   uint64
+	last    uint64
+
+	// Stack sampling for -pprof: a fetch count per distinct shadow
+	// stack, keyed by the NUL-joined frame names. The key is updated
+	// incrementally on push and pop so the per-cycle cost is one map
+	// increment.
+	sampling bool
+	stacks   map[string]uint64
+	key      string
+}
+
+func NewProfile(syms map[string]uint16) *Profile {
+	p := &Profile{
+		names: make(map[uint16]string),
+		nodes: make(map[string]*profNode),
+		edges: make(map[[2]string]*profEdge),
+		stack: []profFrame{{name: "<top>"}},
+	}
+	for name, addr := range syms {
+		p.names[addr] = name
+	}
+	return p
+}
+
+// EnableSampling turns on the per-stack fetch counts that the pprof
+// export is built from; the text report does not need them.
+func (p *Profile) EnableSampling() {
+	p.sampling = true
+	p.stacks = make(map[string]uint64)
+	p.key = p.stack[0].name
+}
+
+func (p *Profile) node(name string) *profNode {
+	n := p.nodes[name]
+	if n == nil {
+		n = &profNode{name: name}
+		p.nodes[name] = n
+	}
+	return n
+}
+
+func (p *Profile) OnFetch(m *Machine, pc uint16, w uint16) {
+	p.last = m.cycles
+	p.total++
+	if p.pending {
+		p.pending = false
+		name, ok := p.names[pc]
+		if !ok {
+			name = fmt.Sprintf("fn 0x%04X", pc)
+		}
+		caller := p.stack[len(p.stack)-1].name
+		p.stack = append(p.stack, profFrame{name: name, start: m.cycles})
+		p.node(name).calls++
+		e := p.edges[[2]string{caller, name}]
+		if e == nil {
+			e = &profEdge{}
+			p.edges[[2]string{caller, name}] = e
+		}
+		e.calls++
+		if p.sampling {
+			p.key += "\x00" + name
+		}
+	}
+	p.node(p.stack[len(p.stack)-1].name).excl++
+	if p.sampling {
+		p.stacks[p.key]++
+	}
+}
+
+func (p *Profile) OnExecute(m *Machine, pc uint16, w uint16) {
+	if isa.GetOp(w) != isa.OpExt || isa.GetXop(w) != isa.XopJlr {
+		return
+	}
+	if isa.GetRa(w) == 1 {
+		// Link written: a call. The callee is named at the next fetch.
+		p.pending = true
+	} else if isa.GetRa(w) == 0 && isa.GetRb(w) == 1 && len(p.stack) > 1 {
+		// Return through lnk: charge the frame's whole span, the
+		// entry fetch through this return, to the callee inclusively.
+		fr := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		dur := m.cycles - fr.start + 1
+		p.node(fr.name).incl += dur
+		caller := p.stack[len(p.stack)-1].name
+		p.edges[[2]string{caller, fr.name}].cycles += dur
+		if p.sampling {
+			p.key = p.key[:len(p.key)-len(fr.name)-1]
+		}
+	}
+}
+
+// Report writes the flat profile and the call-graph edge table.
+// Frames still live at halt are charged through the final cycle.
+func (p *Profile) Report(w io.Writer) {
+	for len(p.stack) > 1 {
+		fr := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		p.node(fr.name).incl += p.last - fr.start + 1
+	}
+	root := p.node("<top>")
+	root.incl = p.total
+
+	nodes := make([]*profNode, 0, len(p.nodes))
+	for _, n := range p.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].excl != nodes[j].excl {
+			return nodes[i].excl > nodes[j].excl
+		}
+		return nodes[i].name < nodes[j].name
+	})
+	fmt.Fprintf(w, "flat profile: %d cycles\n", p.total)
+	fmt.Fprintf(w, "%6s %8s %8s %6s  %s\n", "%", "excl", "incl", "calls", "function")
+	for _, n := range nodes {
+		pct := 0.0
+		if p.total > 0 {
+			pct = 100 * float64(n.excl) / float64(p.total)
+		}
+		fmt.Fprintf(w, "%6.1f %8d %8d %6d  %s\n", pct, n.excl, n.incl, n.calls, n.name)
+	}
+
+	if len(p.edges) == 0 {
+		return
+	}
+	keys := make([][2]string, 0, len(p.edges))
+	for k := range p.edges {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	fmt.Fprintf(w, "call graph:\n")
+	fmt.Fprintf(w, "%6s %8s  %s\n", "calls", "cycles", "caller -> callee")
+	for _, k := range keys {
+		e := p.edges[k]
+		fmt.Fprintf(w, "%6d %8d  %s -> %s\n", e.calls, e.cycles, k[0], k[1])
+	}
+}
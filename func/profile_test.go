@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// A call to fn runs three instructions (entry adi, another adi, the
+// return) and is charged those cycles exclusively; the caller's two
+// instructions stay with <top>. The call-graph table records the edge.
+func TestProfile(t *testing.T) {
+	m := testMachine(
+		isa.EncodeJlr(1, 0, 3), // call fn
+		isa.EncodeVop(isa.VopHlt),
+		0,
+		isa.EncodeMem(isa.OpAdi, 2, 2, 1), // fn
+		isa.EncodeMem(isa.OpAdi, 2, 2, 1),
+		isa.EncodeJlr(0, 1, 0), // rtl
+	)
+	p := NewProfile(map[string]uint16{"fn": 3})
+	m.AddHook(p)
+	stepN(m, 10)
+	n := p.nodes["fn"]
+	if n == nil || n.calls != 1 || n.excl != 3 || n.incl != 3 {
+		t.Fatalf("fn node = %+v", n)
+	}
+	if top := p.nodes["<top>"]; top == nil || top.excl != 2 {
+		t.Errorf("<top> node = %+v", top)
+	}
+	var out bytes.Buffer
+	p.Report(&out)
+	s := out.String()
+	if !strings.Contains(s, "flat profile: 5 cycles") ||
+		!strings.Contains(s, "<top> -> fn") {
+		t.Errorf("report = %q", s)
+	}
+}
+
+// An unknown call target gets a synthetic name, and a frame still
+// open at halt is charged through the final cycle by Report.
+func TestProfileOpenFrame(t *testing.T) {
+	m := testMachine(
+		isa.EncodeJlr(1, 0, 2), // call with no symbol
+		0,
+		isa.EncodeVop(isa.VopHlt), // halt inside the callee
+	)
+	p := NewProfile(nil)
+	m.AddHook(p)
+	stepN(m, 10)
+	var out bytes.Buffer
+	p.Report(&out)
+	n := p.nodes["fn 0x0002"]
+	if n == nil || n.calls != 1 || n.incl != 1 {
+		t.Fatalf("callee node = %+v", n)
+	}
+}
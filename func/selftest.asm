@@ -0,0 +1,312 @@
+; WUT-4 architectural self-test ROM, run by func -selftest.
+;
+; The ROM exercises every instruction and the recoverable exception
+; paths, reporting through the monitor device at port 30: each passing
+; check writes an incrementing progress code, the final check writes
+; 0x600D, and any failure writes 0xBAD0 and halts. The host names the
+; last progress code on failure, which identifies the check that broke.
+;
+; Register conventions, held throughout:
+;   r7  monitor port number (30)
+;   r2  progress counter, written after each check
+;   r3  expected value / expected exception cause
+;   r4  value under test
+;   r5  scratch, and the resume address for exception checks
+;   r1  lnk and scratch; r6 is the stack pointer for the push/pop check
+;
+; The exception checks share one handler: the vector slot jumps to
+; trap, which compares the cause SPR against r3, rewrites epc from r5,
+; and returns. brk and wfi are the two instructions not covered: both
+; stop issue (brk halts, wfi idles with no interrupt source attached),
+; so they are exercised by the emulator's unit tests instead.
+;
+; Regenerate the prebuilt image with: asm -o selftest.bin selftest.asm
+
+; The vector table. Word 0 is never dispatched (cause 0 is unassigned)
+; and doubles as the entry path for a raw-image load; causes that the
+; ROM never raises vector straight to the failure exit.
+	jmp _start		; word 0
+	jmp trap		; 1 illegal
+	jmp trap		; 2 memory
+	jmp fail0		; 3 double
+	jmp fail0		; 4 irq
+	jmp trap		; 5 ioperm
+	jmp fail0		; 6 reserved causes...
+	jmp fail0
+	jmp fail0
+	jmp fail0
+	jmp fail0
+	jmp fail0
+	jmp fail0
+	jmp fail0
+	jmp fail0
+	jmp fail0		; ...15
+	jmp trap		; 16 sys 0
+
+; Shared exception handler: the cause must match r3, and execution
+; resumes at r5 rather than the faulting instruction.
+trap:
+	lsp r4, 1		; cause
+	bne r4, r3, fail0
+	ssp r5, 2		; epc = resume address
+	rti
+
+fail0:
+	li r4, 0xBAD0
+	sio r4, r7
+	hlt
+
+_start:
+	adi r7, r0, 30		; monitor port
+	adi r2, r0, 0		; progress counter
+
+; 1: immediates: li against a hand-split lui/adi pair.
+	li r3, 0x1234
+	lui r4, 0x48
+	adi r4, r4, 0x34
+	bne r4, r3, fail0
+	adi r2, r2, 1
+	sio r2, r7
+
+; 2: add wraps to zero and sets carry in the psw.
+	li r4, 0xFFFF
+	adi r3, r0, 1
+	add r4, r3
+	bne r4, r0, fail0
+	lsp r4, 0		; psw
+	adi r3, r0, 4		; the carry bit
+	and r4, r3
+	bne r4, r3, fail0
+	adi r2, r2, 1
+	sio r2, r7
+
+; 3: sub below zero borrows.
+	adi r4, r0, 5
+	adi r3, r0, 7
+	sub r4, r3
+	li r3, -2
+	bne r4, r3, fail0
+	adi r2, r2, 1
+	sio r2, r7
+
+; 4: and, or, xor.
+	li r4, 0x0F0F
+	li r3, 0x00FF
+	and r4, r3
+	adi r3, r0, 0x0F
+	bne r4, r3, fail0
+	adi r4, r0, 0x30
+	or r4, r3		; 0x3F
+	adi r3, r0, 0x3F
+	bne r4, r3, fail0
+	xor r4, r3		; 0
+	bne r4, r0, fail0
+	adi r2, r2, 1
+	sio r2, r7
+
+	jmp t5
+fail1:	jmp fail0
+t5:
+
+; 5: slt is a signed compare.
+	li r4, -5
+	adi r3, r0, 3
+	slt r4, r3
+	adi r3, r0, 1
+	bne r4, r3, fail1
+	adi r2, r2, 1
+	sio r2, r7
+
+; 6: not then neg: -(~5) = 6.
+	adi r4, r0, 5
+	not r4
+	neg r4
+	adi r3, r0, 6
+	bne r4, r3, fail1
+	adi r2, r2, 1
+	sio r2, r7
+
+; 7: one-bit shifts.
+	adi r4, r0, 1
+	lsl r4
+	lsl r4
+	lsr r4			; 2
+	adi r3, r0, 2
+	bne r4, r3, fail1
+	li r4, 0x8000
+	asr r4			; sign extends
+	li r3, 0xC000
+	bne r4, r3, fail1
+	adi r2, r2, 1
+	sio r2, r7
+
+; 8: shift-by-n immediates.
+	adi r4, r0, 1
+	lsli r4, 7
+	lsri r4, 4		; 8
+	adi r3, r0, 8
+	bne r4, r3, fail1
+	li r4, 0x8000
+	asri r4, 7
+	li r3, 0xFF00
+	bne r4, r3, fail1
+	adi r2, r2, 1
+	sio r2, r7
+
+	jmp t9
+fail2:	jmp fail1
+t9:
+
+; 9: word and byte loads and stores round-trip through dmem.
+	li r3, 0x1000
+	li r4, 0xBEEF
+	stw r4, r3, 0
+	adi r4, r0, 0
+	ldw r4, r3, 0
+	li r5, 0xBEEF
+	bne r4, r5, fail2
+	stb r4, r3, 2		; low byte only
+	adi r4, r0, 0
+	ldb r4, r3, 2
+	li r5, 0x00EF
+	bne r4, r5, fail2
+	adi r2, r2, 1
+	sio r2, r7
+
+; 10: tas returns the old word and leaves 0xFFFF behind.
+	tas r4, r3		; r3 still 0x1000
+	li r5, 0xBEEF
+	bne r4, r5, fail2
+	ldw r4, r3, 0
+	adi r5, r0, 0
+	not r5			; 0xFFFF
+	bne r4, r5, fail2
+	adi r2, r2, 1
+	sio r2, r7
+
+; 11: beq both ways: a false take is a failure, a true take skips the
+; failure exit.
+	adi r4, r0, 1
+	beq r4, r0, fail2
+	beq r0, r0, t11b
+	jmp fail2
+t11b:
+	adi r2, r2, 1
+	sio r2, r7
+
+; 12: jlr call and the rtl return through lnk.
+	li r3, func1
+	jlr r1, r3, 0
+	adi r3, r0, 42		; func1's signature value
+	bne r4, r3, fail2
+	adi r2, r2, 1
+	sio r2, r7
+
+	jmp t13
+fail3:	jmp fail2
+t13:
+
+; 13: push and pop through sp, which must come back unmoved.
+	li r6, 0x2000
+	adi r4, r0, 9
+	push r4
+	adi r4, r0, 0
+	pop r4
+	adi r3, r0, 9
+	bne r4, r3, fail2
+	li r3, 0x2000
+	bne r6, r3, fail2
+	adi r2, r2, 1
+	sio r2, r7
+
+; 14: ssp/lsp round-trip through far, which nothing has faulted into
+; yet; the memory-fault check below overwrites it. 63 is the widest
+; positive adi immediate.
+	adi r4, r0, 63
+	ssp r4, 5
+	adi r4, r0, 0
+	lsp r4, 5
+	adi r3, r0, 63
+	bne r4, r3, fail3
+	adi r2, r2, 1
+	sio r2, r7
+
+; 15: di clears the interrupt-enable bit, ei sets it.
+	di
+	lsp r4, 0
+	adi r3, r0, 2		; the ie bit
+	and r4, r3
+	bne r4, r0, fail3
+	ei
+	lsp r4, 0
+	and r4, r3
+	bne r4, r3, fail3
+	di			; stay uninterruptible
+	adi r2, r2, 1
+	sio r2, r7
+
+; 16: lio reads back the progress code the monitor last latched.
+	lio r4, r7
+	bne r4, r2, fail3
+	adi r2, r2, 1
+	sio r2, r7
+
+	jmp t17
+fail4:	jmp fail3
+t17:
+
+; 17: an illegal instruction vectors with cause 1 and resumes at r5.
+	adi r3, r0, 1
+	li r5, res17
+	.word 0xFB80		; reserved encoding
+res17:
+	adi r2, r2, 1
+	sio r2, r7
+
+; 18: a misaligned load faults with cause 2 and records the address
+; and access type in far and ftype.
+	adi r3, r0, 2
+	li r5, res18
+	ldw r4, r0, 33
+res18:
+	lsp r4, 5		; far
+	adi r3, r0, 33
+	bne r4, r3, fail4
+	lsp r4, 6		; ftype
+	adi r3, r0, 1		; FtRead
+	bne r4, r3, fail4
+	adi r2, r2, 1
+	sio r2, r7
+
+; 19: sys 0 traps with cause 16.
+	adi r3, r0, 16
+	li r5, res19
+	sys 0
+res19:
+	adi r2, r2, 1
+	sio r2, r7
+
+; 20: in user mode the monitor port is not granted, so lio faults
+; with cause 5; the trap puts the ROM back in kernel mode and far
+; holds the port number.
+	adi r3, r0, 5
+	li r5, res20
+	lsp r4, 0
+	li r1, 0xFFFE
+	and r4, r1		; clear the kernel bit
+	ssp r4, 0		; user mode from here
+	lio r4, r7
+res20:
+	lsp r4, 5		; far = the denied port
+	bne r4, r7, fail4
+	adi r2, r2, 1
+	sio r2, r7
+
+; All checks passed.
+	li r4, 0x600D
+	sio r4, r7
+	hlt
+
+func1:
+	adi r4, r0, 42
+	rtl
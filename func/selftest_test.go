@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// The embedded ROM must pass on the tree as built, and every check
+// must run: a check count below the ROM's 20 means part of the test
+// sequence was silently skipped.
+func TestSelftestRomPasses(t *testing.T) {
+	var out bytes.Buffer
+	if status := runSelftest(&out); status != 0 {
+		t.Fatalf("selftest failed (status %d):\n%s", status, out.String())
+	}
+	if !strings.Contains(out.String(), "PASS (20 checks") {
+		t.Errorf("unexpected selftest report: %s", out.String())
+	}
+}
@@ -0,0 +1,174 @@
+// State snapshots. A snapshot captures everything the interpreter
+// core needs to resume — registers, SPRs, pc, cycle count, both
+// memories, and the MMU map when one is attached — so a long run can
+// be bisected: autosave with -snapevery, then restore near the
+// trouble and re-run the short remainder with tracing or -strict on,
+// paying their overhead only over that stretch.
+//
+// Device state (console position, math unit latches, open files) is
+// host-side and is not captured; a restored run is exact only for
+// the compute between snapshot and failure, which is the bisecting
+// use case. The I/O counters and exception history restart at zero.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/wire"
+)
+
+var snapMagic = [4]byte{'W', 'U', 'T', 'S'}
+
+const snapVersion = 1
+
+const (
+	snapHalted = 1 << iota
+	snapIdle
+	snapInExc
+	snapHasMmu
+)
+
+// SaveSnapshot writes the machine state to path.
+func (m *Machine) SaveSnapshot(path string) error {
+	var b bytes.Buffer
+	b.Write(snapMagic[:])
+	wire.WriteWord(&b, snapVersion)
+	flags := uint16(0)
+	if m.halted {
+		flags |= snapHalted
+	}
+	if m.idle {
+		flags |= snapIdle
+	}
+	if m.inExc {
+		flags |= snapInExc
+	}
+	if m.mmu != nil {
+		flags |= snapHasMmu
+	}
+	wire.WriteWord(&b, flags)
+	wire.WriteLong(&b, uint32(m.cycles))
+	wire.WriteLong(&b, uint32(m.cycles>>32))
+	wire.WriteWord(&b, m.pc)
+	for _, r := range m.reg {
+		wire.WriteWord(&b, r)
+	}
+	for _, s := range m.spr {
+		wire.WriteWord(&b, s)
+	}
+	for _, w := range m.imem {
+		wire.WriteWord(&b, w)
+	}
+	b.Write(m.dmem)
+	if m.mmu != nil {
+		u := m.mmu
+		mf := uint16(0)
+		if u.enabled {
+			mf |= MmuEnable
+		}
+		if u.kernPhys {
+			mf |= MmuKernPhys
+		}
+		wire.WriteWord(&b, mf)
+		wire.WriteWord(&b, u.index)
+		wire.WriteWord(&b, u.asid)
+		for _, e := range u.entries {
+			wire.WriteWord(&b, e)
+		}
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+// RestoreSnapshot replaces the machine state with a saved one. The
+// machine must have an MMU attached iff the snapshot was taken with
+// one, so the restored map has somewhere to go.
+func (m *Machine) RestoreSnapshot(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	const fixed = 4 + 2 + 2 + 8 + 2 + 2*8 + 2*isa.NumSpr
+	if len(b) < fixed || !bytes.Equal(b[:4], snapMagic[:]) {
+		return fmt.Errorf("%s: not a snapshot", path)
+	}
+	if v := wire.Word(b[4:]); v != snapVersion {
+		return fmt.Errorf("%s: unsupported snapshot version %d", path, v)
+	}
+	flags := wire.Word(b[6:])
+	want := fixed + 2*ImemWords + DmemBytes
+	if flags&snapHasMmu != 0 {
+		want += 2 * (3 + MmuPages)
+	}
+	if len(b) != want {
+		return fmt.Errorf("%s: truncated snapshot (%d bytes, want %d)", path, len(b), want)
+	}
+	if (flags&snapHasMmu != 0) != (m.mmu != nil) {
+		return fmt.Errorf("%s: snapshot and machine disagree about an MMU", path)
+	}
+	m.halted = flags&snapHalted != 0
+	m.idle = flags&snapIdle != 0
+	m.inExc = flags&snapInExc != 0
+	m.cycles = uint64(wire.Long(b[8:])) | uint64(wire.Long(b[12:]))<<32
+	m.pc = wire.Word(b[16:])
+	p := 18
+	for i := range m.reg {
+		m.reg[i] = wire.Word(b[p:])
+		p += 2
+	}
+	for i := range m.spr {
+		m.spr[i] = wire.Word(b[p:])
+		p += 2
+	}
+	for i := range m.imem {
+		m.imem[i] = wire.Word(b[p:])
+		p += 2
+	}
+	copy(m.dmem, b[p:])
+	p += DmemBytes
+	if m.mmu != nil {
+		u := m.mmu
+		mf := wire.Word(b[p:])
+		u.enabled = mf&MmuEnable != 0
+		u.kernPhys = mf&MmuKernPhys != 0
+		u.index = wire.Word(b[p+2:])
+		u.asid = wire.Word(b[p+4:])
+		p += 6
+		for i := range u.entries {
+			u.entries[i] = wire.Word(b[p:])
+			p += 2
+		}
+	}
+	m.dec = nil // imem changed; predecode again on the next Step
+	return nil
+}
+
+// A snapper implements -snapevery: it saves a snapshot every N
+// cycles and keeps only the most recent -snapkeep of them, so a
+// multi-million-cycle run leaves a bounded trail behind it.
+type snapper struct {
+	prefix  string
+	every   uint64
+	keep    int
+	written []string
+}
+
+// tick saves a snapshot when the cycle counter crosses the interval.
+func (s *snapper) tick(m *Machine) error {
+	if m.cycles%s.every != 0 {
+		return nil
+	}
+	path := fmt.Sprintf("%s-%d", s.prefix, m.cycles)
+	if err := m.SaveSnapshot(path); err != nil {
+		return err
+	}
+	s.written = append(s.written, path)
+	if len(s.written) > s.keep {
+		os.Remove(s.written[0])
+		s.written = s.written[1:]
+	}
+	return nil
+}
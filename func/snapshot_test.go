@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// A restored machine must resume exactly where the saved one was:
+// same registers, pc, cycle count, memories, and MMU map.
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	m, u := mmuMachine()
+	copy(m.imem[isa.VectorBase:], []uint16{
+		isa.EncodeMem(isa.OpAdi, 2, 0, 5),
+		isa.EncodeMem(isa.OpStw, 2, 0, 32),
+		isa.EncodeVop(isa.VopHlt),
+	})
+	u.entries[0] = MmuValid | MmuRead | MmuWrite
+	stepN(m, 2)
+	if err := m.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	stepN(m, 5) // run to halt, diverging from the snapshot
+
+	r, ru := mmuMachine()
+	if err := r.RestoreSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if r.pc != 2 || r.cycles != 2 || r.reg[2] != 5 || r.halted {
+		t.Errorf("restored pc=%d cycles=%d r2=%d halted=%v", r.pc, r.cycles, r.reg[2], r.halted)
+	}
+	if r.dmem[32] != 5 || ru.entries[0] != MmuValid|MmuRead|MmuWrite {
+		t.Errorf("restored dmem[32]=%d mmu[0]=0x%04X", r.dmem[32], ru.entries[0])
+	}
+	stepN(r, 5)
+	if !r.halted || r.cycles != 3 {
+		t.Errorf("restored run: halted=%v cycles=%d, want halt at cycle 3", r.halted, r.cycles)
+	}
+
+	if err := testMachine().RestoreSnapshot(path); err == nil ||
+		!strings.Contains(err.Error(), "MMU") {
+		t.Errorf("restore without an MMU: %v", err)
+	}
+}
+
+// The rolling set keeps only the newest -snapkeep snapshots.
+func TestSnapperRolling(t *testing.T) {
+	dir := t.TempDir()
+	m := testMachine(isa.EncodeVop(isa.VopHlt))
+	s := &snapper{prefix: filepath.Join(dir, "s"), every: 1, keep: 2}
+	for i := 0; i < 4; i++ {
+		m.cycles = uint64(i + 1)
+		if err := s.tick(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := filepath.Glob(filepath.Join(dir, "s-*"))
+	if len(got) != 2 {
+		t.Fatalf("kept %d snapshots: %v", len(got), got)
+	}
+	for _, p := range got {
+		if !strings.HasSuffix(p, "s-3") && !strings.HasSuffix(p, "s-4") {
+			t.Errorf("stale snapshot survived: %s", p)
+		}
+	}
+}
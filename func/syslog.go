@@ -0,0 +1,59 @@
+// The -logsys system-level trace: exception entries, rti, mode
+// switches, and SPR writes, each with a cycle number. Kernel work
+// mostly needs to know when and why control crossed a privilege or
+// exception boundary, and a full instruction trace buries that; this
+// log carries only the crossings. It is an ordinary Hooks observer.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+type SysLog struct {
+	NopHooks
+	w     io.Writer
+	kmode bool
+}
+
+func NewSysLog(w io.Writer, m *Machine) *SysLog {
+	return &SysLog{w: w, kmode: m.kernel()}
+}
+
+func (l *SysLog) OnException(m *Machine, cause uint16, pc uint16) {
+	fmt.Fprintf(l.w, "%10d exception cause=%d (%s) pc=0x%04X vector=0x%04X\n",
+		m.cycles, cause, isa.CauseName(cause), pc, uint16(isa.VectorBase)+cause)
+	// Taking an exception forces kernel mode; log the switch here
+	// since OnExecute does not run for the faulting instruction.
+	if !l.kmode {
+		l.kmode = true
+		fmt.Fprintf(l.w, "%10d mode kernel pc=0x%04X\n", m.cycles, pc)
+	}
+}
+
+func (l *SysLog) OnExecute(m *Machine, pc uint16, w uint16) {
+	if isa.GetOp(w) == isa.OpExt && isa.GetXop(w) == isa.XopYop {
+		switch isa.GetYop(w) {
+		case isa.YopSsp:
+			n := isa.GetSpr(w)
+			fmt.Fprintf(l.w, "%10d ssp spr%d=0x%04X pc=0x%04X\n",
+				m.cycles, n, m.spr[n], pc)
+		case isa.YopVop:
+			if isa.GetVop(w) == isa.VopRti {
+				fmt.Fprintf(l.w, "%10d rti to=0x%04X pc=0x%04X\n",
+					m.cycles, m.spr[isa.SprEpc], pc)
+			}
+		}
+	}
+	if k := m.kernel(); k != l.kmode {
+		l.kmode = k
+		mode := "user"
+		if k {
+			mode = "kernel"
+		}
+		fmt.Fprintf(l.w, "%10d mode %s pc=0x%04X\n", m.cycles, mode, pc)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// The system log must record SPR writes, mode switches in both
+// directions, and exception entry, each with a cycle number.
+func TestSysLogTransitions(t *testing.T) {
+	m := testMachine(
+		isa.EncodeMem(isa.OpAdi, 1, 0, 5), // r1 = 5
+		isa.EncodeSpr(isa.YopSsp, 1, 5),   // spr5 = 5
+		isa.EncodeSpr(isa.YopSsp, 0, 0),   // psw = 0: drop to user mode
+		isa.EncodeSys(0),                  // trap back to kernel
+	)
+	m.imem[isa.VectorBase+isa.ExSysBase] = isa.EncodeVop(isa.VopHlt)
+	var log bytes.Buffer
+	m.AddHook(NewSysLog(&log, m))
+	stepN(m, 100)
+
+	for _, want := range []string{
+		"ssp spr5=0x0005",
+		"mode user",
+		"exception cause=16",
+		"mode kernel",
+	} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("log missing %q:\n%s", want, log.String())
+		}
+	}
+}
+
+// rti is logged with the pc it returns to.
+func TestSysLogRti(t *testing.T) {
+	m := testMachine(isa.EncodeSys(0))
+	m.imem[isa.VectorBase+isa.ExSysBase] = isa.EncodeVop(isa.VopRti)
+	var log bytes.Buffer
+	m.AddHook(NewSysLog(&log, m))
+	stepN(m, 6)
+	if !strings.Contains(log.String(), "rti to=0x0000") {
+		t.Errorf("log missing rti line:\n%s", log.String())
+	}
+}
@@ -0,0 +1,145 @@
+// Experimental taint tracking, built on the hook interface. Bytes
+// arriving from input devices (console input, the semihosting file
+// device) are tagged, the tag propagates through register and memory
+// dataflow, and a report fires when tainted data reaches the pc
+// (through jlr) or a special register. The analysis follows explicit
+// dataflow only: a branch on a tainted value does not taint the pc,
+// which is the classic explicit-flow taint model and is plenty for
+// teaching and for auditing what kernel code does with device input.
+//
+// Propagation runs in OnFetch, before the instruction executes, so
+// the source registers still hold the values the instruction will
+// read. An instruction that faults mid-way can leave the shadow
+// state slightly ahead of the machine; the mode is an analysis aid,
+// not an architectural feature, and accepts that.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// Taint is a Hooks implementation carrying the shadow taint state.
+type Taint struct {
+	NopHooks
+	reg      [8]bool
+	mem      []bool
+	spr      [isa.NumSpr]bool
+	sources  map[uint16]bool // input ports whose reads are tainted
+	reported map[string]bool // one report per site
+	out      io.Writer
+}
+
+func NewTaint(out io.Writer) *Taint {
+	return &Taint{
+		mem:      make([]bool, DmemBytes),
+		sources:  map[uint16]bool{PortConIn: true, PortFileData: true},
+		reported: make(map[string]bool),
+		out:      out,
+	}
+}
+
+func (t *Taint) report(pc uint16, what string) {
+	key := fmt.Sprintf("%s@%04X", what, pc)
+	if t.reported[key] {
+		return
+	}
+	t.reported[key] = true
+	fmt.Fprintf(t.out, "func-taint: %s at pc 0x%04X\n", what, pc)
+}
+
+// regTaint reads a register's taint; r0 is never tainted.
+func (t *Taint) regTaint(r int) bool { return r != 0 && t.reg[r] }
+
+// setReg writes a register's taint; writes to r0 are discarded like
+// the register itself.
+func (t *Taint) setReg(r int, v bool) {
+	if r != 0 {
+		t.reg[r] = v
+	}
+}
+
+func (t *Taint) OnFetch(m *Machine, pc, w uint16) {
+	ra, rb := isa.GetRa(w), isa.GetRb(w)
+	switch isa.GetOp(w) {
+	case isa.OpLdw:
+		addr := m.get(rb) + uint16(isa.GetImm7(w))
+		t.setReg(ra, t.mem[addr] || t.mem[addr+1])
+	case isa.OpLdb:
+		addr := m.get(rb) + uint16(isa.GetImm7(w))
+		t.setReg(ra, t.mem[addr])
+	case isa.OpStw:
+		addr := m.get(rb) + uint16(isa.GetImm7(w))
+		t.mem[addr] = t.regTaint(ra)
+		t.mem[addr+1] = t.regTaint(ra)
+	case isa.OpStb:
+		addr := m.get(rb) + uint16(isa.GetImm7(w))
+		t.mem[addr] = t.regTaint(ra)
+	case isa.OpBeq:
+		// Control dependence is not tracked (explicit flows only).
+	case isa.OpAdi:
+		t.setReg(ra, t.regTaint(rb))
+	case isa.OpLui:
+		t.setReg(ra, false)
+	case isa.OpExt:
+		t.ext(m, pc, w, ra, rb)
+	}
+}
+
+func (t *Taint) ext(m *Machine, pc, w uint16, ra, rb int) {
+	switch isa.GetXop(w) {
+	case isa.XopAdd, isa.XopSub, isa.XopAnd, isa.XopOr, isa.XopXor, isa.XopSlt:
+		t.setReg(ra, t.regTaint(ra) || t.regTaint(rb))
+	case isa.XopJlr:
+		if t.regTaint(rb) {
+			t.report(pc, "tainted jump target")
+		}
+		t.setReg(ra, false) // the link address is a clean pc value
+	case isa.XopYop:
+		t.yop(m, pc, w, ra, rb)
+	}
+}
+
+func (t *Taint) yop(m *Machine, pc, w uint16, ra, rb int) {
+	switch isa.GetYop(w) {
+	case isa.YopLsr, isa.YopLsl, isa.YopAsr, isa.YopNot, isa.YopNeg,
+		isa.YopLsri, isa.YopLsli, isa.YopAsri:
+		// In-place on ra: taint unchanged.
+	case isa.YopLsp:
+		t.setReg(ra, t.spr[isa.GetSpr(w)])
+	case isa.YopSsp:
+		if t.regTaint(ra) {
+			t.report(pc, fmt.Sprintf("tainted write to spr %d", isa.GetSpr(w)))
+		}
+		t.spr[isa.GetSpr(w)] = t.regTaint(ra)
+	case isa.YopLio:
+		t.setReg(ra, t.sources[m.get(rb)])
+	case isa.YopSio:
+		// Data leaving through a port drops out of the analysis.
+	case isa.YopTas:
+		addr := m.get(rb)
+		t.setReg(ra, t.mem[addr] || t.mem[addr+1])
+		t.mem[addr] = false // tas stores the constant 0xFFFF
+		t.mem[addr+1] = false
+	}
+}
+
+// Summary writes the final extent of the taint.
+func (t *Taint) Summary(w io.Writer) {
+	regs, bytes := 0, 0
+	for r := 1; r < len(t.reg); r++ {
+		if t.reg[r] {
+			regs++
+		}
+	}
+	for _, b := range t.mem {
+		if b {
+			bytes++
+		}
+	}
+	fmt.Fprintf(w, "func-taint: %d register(s) and %d dmem byte(s) tainted at halt\n",
+		regs, bytes)
+}
@@ -0,0 +1,148 @@
+// The -timeline export: a Chrome trace-event JSON timeline of the
+// run, viewable in chrome://tracing or Perfetto. Exception handling
+// appears as slices (opened at the exception, closed at rti), mode
+// switches and interrupts as instants, and with -timelinecalls each
+// jlr-with-link call opens a slice that the matching return through
+// lnk closes, named from -syms. Cycle numbers stand in for
+// microseconds, so the viewer's time axis reads directly in cycles.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// tlEvent is one trace_event object. Ts is the cycle count.
+type tlEvent struct {
+	Name string            `json:"name"`
+	Ph   string            `json:"ph"`
+	Ts   uint64            `json:"ts"`
+	Pid  int               `json:"pid"`
+	Tid  int               `json:"tid"`
+	S    string            `json:"s,omitempty"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// Track (tid) assignments. Slices on one track must nest, so calls
+// and exceptions get separate tracks; instants go with the exceptions
+// since they describe the same privilege story.
+const (
+	tlTidExc  = 1
+	tlTidCall = 2
+)
+
+// Timeline accumulates trace events and serializes them at halt.
+type Timeline struct {
+	NopHooks
+	events  []tlEvent
+	names   map[uint16]string // code address -> symbol, for call slices
+	calls   bool
+	kmode   bool
+	excOpen int    // exception slices awaiting rti
+	depth   int    // call slices awaiting return
+	pending bool   // the next fetch is a call target
+	last    uint64 // latest cycle seen, to close open slices at
+}
+
+func NewTimeline(m *Machine, syms map[string]uint16, calls bool) *Timeline {
+	t := &Timeline{calls: calls, kmode: m.kernel()}
+	if calls {
+		t.names = make(map[uint16]string)
+		for name, addr := range syms {
+			t.names[addr] = name
+		}
+	}
+	return t
+}
+
+func (t *Timeline) add(e tlEvent) {
+	e.Pid = 1
+	t.events = append(t.events, e)
+}
+
+func (t *Timeline) instant(cycle uint64, name string, args map[string]string) {
+	t.add(tlEvent{Name: name, Ph: "i", Ts: cycle, Tid: tlTidExc, S: "t", Args: args})
+}
+
+func (t *Timeline) OnFetch(m *Machine, pc uint16, w uint16) {
+	t.last = m.cycles
+	if !t.pending {
+		return
+	}
+	t.pending = false
+	name, ok := t.names[pc]
+	if !ok {
+		name = fmt.Sprintf("fn 0x%04X", pc)
+	}
+	t.add(tlEvent{Name: name, Ph: "B", Ts: m.cycles, Tid: tlTidCall,
+		Args: map[string]string{"pc": fmt.Sprintf("0x%04X", pc)}})
+	t.depth++
+}
+
+func (t *Timeline) OnException(m *Machine, cause uint16, pc uint16) {
+	name := isa.CauseName(cause)
+	if cause == isa.ExIrq {
+		t.instant(m.cycles, "irq", map[string]string{"pc": fmt.Sprintf("0x%04X", pc)})
+	}
+	t.add(tlEvent{Name: name, Ph: "B", Ts: m.cycles, Tid: tlTidExc,
+		Args: map[string]string{"pc": fmt.Sprintf("0x%04X", pc)}})
+	t.excOpen++
+	// Taking an exception forces kernel mode; OnExecute does not run
+	// for the faulting instruction, so note the switch here.
+	if !t.kmode {
+		t.kmode = true
+		t.instant(m.cycles, "mode kernel", nil)
+	}
+}
+
+func (t *Timeline) OnExecute(m *Machine, pc uint16, w uint16) {
+	if isa.GetOp(w) == isa.OpExt {
+		switch isa.GetXop(w) {
+		case isa.XopJlr:
+			if !t.calls {
+				break
+			}
+			if isa.GetRa(w) == 1 {
+				// Link written: a call. The target is the pc of the
+				// next fetch, which also carries the symbol name.
+				t.pending = true
+			} else if isa.GetRb(w) == 1 && t.depth > 0 {
+				// A return through lnk (the rtl expansion).
+				t.add(tlEvent{Ph: "E", Ts: m.cycles, Tid: tlTidCall})
+				t.depth--
+			}
+		case isa.XopYop:
+			if isa.GetYop(w) == isa.YopVop && isa.GetVop(w) == isa.VopRti && t.excOpen > 0 {
+				t.add(tlEvent{Ph: "E", Ts: m.cycles, Tid: tlTidExc})
+				t.excOpen--
+			}
+		}
+	}
+	if k := m.kernel(); k != t.kmode {
+		t.kmode = k
+		mode := "mode user"
+		if k {
+			mode = "mode kernel"
+		}
+		t.instant(m.cycles, mode, nil)
+	}
+}
+
+// Write serializes the timeline as a trace_event JSON array. Slices
+// still open at halt are closed at the final cycle so the viewer
+// shows them full-width instead of dropping them.
+func (t *Timeline) Write(w io.Writer) error {
+	for ; t.excOpen > 0; t.excOpen-- {
+		t.add(tlEvent{Ph: "E", Ts: t.last, Tid: tlTidExc})
+	}
+	for ; t.depth > 0; t.depth-- {
+		t.add(tlEvent{Ph: "E", Ts: t.last, Tid: tlTidCall})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t.events)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+func timelineEvents(t *testing.T, tl *Timeline) []tlEvent {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tl.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var evs []tlEvent
+	if err := json.Unmarshal(buf.Bytes(), &evs); err != nil {
+		t.Fatalf("timeline is not valid JSON: %v\n%s", err, buf.String())
+	}
+	return evs
+}
+
+// An exception opens a slice that rti closes. The rti returns to the
+// faulting vop, which faults again, so ten steps yield several
+// balanced slice pairs — more E events than the single one WriteTo
+// would add for a slice left open.
+func TestTimelineException(t *testing.T) {
+	m := testMachine(
+		isa.EncodeVop(63), // unallocated: ExIllegal, vectors to word 1
+		isa.EncodeVop(isa.VopRti),
+	)
+	tl := NewTimeline(m, nil, false)
+	m.AddHook(tl)
+	stepN(m, 10)
+	evs := timelineEvents(t, tl)
+	var opens, closes int
+	for _, e := range evs {
+		if e.Tid != tlTidExc {
+			continue
+		}
+		switch e.Ph {
+		case "B":
+			if e.Name != "illegal" {
+				t.Errorf("slice name %q, want \"illegal\"", e.Name)
+			}
+			opens++
+		case "E":
+			closes++
+		}
+	}
+	if opens < 2 || closes != opens {
+		t.Errorf("opens=%d closes=%d events=%+v", opens, closes, evs)
+	}
+}
+
+// With -timelinecalls a jlr that writes lnk opens a slice named from
+// the symbol table and the return through lnk closes it.
+func TestTimelineCalls(t *testing.T) {
+	m := testMachine(
+		isa.EncodeJlr(1, 0, 3), // call fn at word 3
+		isa.EncodeVop(isa.VopHlt),
+		0,
+		isa.EncodeJlr(0, 1, 0), // rtl
+	)
+	tl := NewTimeline(m, map[string]uint16{"fn": 3}, true)
+	m.AddHook(tl)
+	stepN(m, 10)
+	evs := timelineEvents(t, tl)
+	var entered, left bool
+	for _, e := range evs {
+		if e.Ph == "B" && e.Name == "fn" && e.Tid == tlTidCall {
+			entered = true
+		}
+		if e.Ph == "E" && e.Tid == tlTidCall {
+			left = true
+		}
+	}
+	if !entered || !left {
+		t.Errorf("entered=%v left=%v events=%+v", entered, left, evs)
+	}
+	if tl.depth != 0 {
+		t.Errorf("call depth %d at halt, want 0", tl.depth)
+	}
+}
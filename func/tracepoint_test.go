@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// A tracepoint logs its items at every hit and never stops the run.
+func TestTracepoints(t *testing.T) {
+	m := testMachine(
+		isa.EncodeMem(isa.OpAdi, 2, 0, 5),
+		isa.EncodeMem(isa.OpStw, 2, 0, 32),
+		isa.EncodeVop(isa.VopHlt),
+	)
+	var out bytes.Buffer
+	syms := map[string]uint16{"store": 1, "buf": 32}
+	tp, err := newTracepoints(&out, "store=r2+pc+@buf", syms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddHook(tp)
+	stepN(m, 10)
+	if !m.halted {
+		t.Fatal("machine did not halt")
+	}
+	want := "tp store cycle=2 r2=0x0005 pc=0x0001 @buf=0x0000\n"
+	if out.String() != want {
+		t.Errorf("log = %q, want %q", out.String(), want)
+	}
+}
+
+func TestTracepointSpecErrors(t *testing.T) {
+	for _, spec := range []string{"loop", "loop=", "3=r9", "3=@nosuch", "nosuch=r1"} {
+		if _, err := newTracepoints(nil, spec, nil); err == nil {
+			t.Errorf("spec %q parsed", spec)
+		}
+	}
+}
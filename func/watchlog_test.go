@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// Only writes that touch the watched range are logged, each with the
+// writing pc and cycle; loads are not.
+func TestWatchLog(t *testing.T) {
+	m := testMachine(
+		isa.EncodeMem(isa.OpAdi, 2, 0, 7),
+		isa.EncodeMem(isa.OpStw, 2, 0, 32), // in range
+		isa.EncodeMem(isa.OpStb, 2, 0, 33), // high byte, still in range
+		isa.EncodeMem(isa.OpStw, 2, 0, 40), // outside
+		isa.EncodeMem(isa.OpLdw, 3, 0, 32), // load: not logged
+		isa.EncodeVop(isa.VopHlt),
+	)
+	wl, err := newWatchLog("0x20:2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddHook(wl)
+	stepN(m, 10)
+	if len(wl.entries) != 2 {
+		t.Fatalf("logged %d writes, want 2: %+v", len(wl.entries), wl.entries)
+	}
+	if e := wl.entries[0]; e.pc != 1 || e.addr != 32 || e.value != 7 || e.size != 2 {
+		t.Errorf("first entry = %+v", e)
+	}
+	if e := wl.entries[1]; e.pc != 2 || e.addr != 33 || e.size != 1 {
+		t.Errorf("second entry = %+v", e)
+	}
+	var out bytes.Buffer
+	wl.Dump(&out)
+	if !strings.Contains(out.String(), "2 writes") ||
+		!strings.Contains(out.String(), "dmem[0x0020] = 0x0007") {
+		t.Errorf("dump = %q", out.String())
+	}
+}
+
+func TestWatchLogSpecErrors(t *testing.T) {
+	for _, spec := range []string{"nosuch", "0x20:0", "0xFFFF:4"} {
+		if _, err := newWatchLog(spec, nil); err == nil {
+			t.Errorf("spec %q parsed", spec)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/gmofishsauce/y4
+
+go 1.21.6
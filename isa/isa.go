@@ -0,0 +1,451 @@
+// Package isa is the single source of truth for the WUT-4 instruction
+// set: opcode assignments, instruction formats, field extraction, and
+// encoding. The assembler, disassembler, emulator and simulator all
+// import this package rather than carrying private copies of the
+// opcode tables, so an encoding change made here is an encoding change
+// made everywhere.
+//
+// A WUT-4 instruction is one 16-bit word:
+//
+//	15  13 12         6 5   3 2   0
+//	[opc ][   imm7    ][ rb  ][ ra ]
+//
+// Opcodes 0..6 are the base operations (loads, stores, beq, adi, lui).
+// Opcode 7 escapes to the extended space, where bits 12..10 select an
+// XOP (two-register operation, result in ra). XOP 6 escapes again to
+// the YOP space: bits 9..6 select a YOP, which has at most one general
+// register operand plus a small second field (special register, port
+// register, or immediate). YOP 15 escapes to the VOP space: bits 5..0
+// select a VOP, which has no operands at all. Unallocated codes in
+// every space are reserved and take an illegal instruction exception.
+package isa
+
+import "fmt"
+
+// Version is the ISA revision implemented by this tree. Every tool
+// reports it under -version, and itf refuses to mix tools built from
+// different revisions. Revision 1 is the base integer ISA; revision
+// 2 added the immediate shifts and the tas atomic.
+const Version = 2
+
+// MinRev returns the first ISA revision in which an instruction is
+// legal. The assembler rejects instructions newer than the -isa
+// target, and the emulator decodes them as illegal, so an image
+// built for an old revision cannot silently rely on new hardware.
+func MinRev(d *Def) int {
+	switch d.Mnemonic {
+	case "lsri", "lsli", "asri", "tas":
+		return 2
+	}
+	return 1
+}
+
+// Attr is a set of architectural side-effect flags for one
+// instruction. Implementations drive their writeback and memory
+// stages from these instead of hand-maintained expressions over op
+// classes, and the functional emulator's test suite checks each
+// instruction's observed state changes against its declared set, so
+// an instruction added with the wrong flags fails fast.
+type Attr uint16
+
+const (
+	AttrWritesRa  Attr = 1 << iota // writes general register ra
+	AttrReadsMem                   // reads data memory
+	AttrWritesMem                  // writes data memory
+	AttrBranches                   // may set pc to other than pc+1
+	AttrSetsC                      // may change the psw carry bit
+	AttrWritesSpr                  // changes SPR state beyond carry
+	AttrIo                         // accesses the I/O port space
+	AttrPriv                       // kernel only
+)
+
+// Attrs returns an instruction's side-effect flags. Like MinRev it is
+// a function over the mnemonic rather than a column in Defs: the
+// flags of an instruction are fixed by the architecture, not data
+// that varies per table entry.
+func Attrs(d *Def) Attr {
+	switch d.Mnemonic {
+	case "ldw", "ldb":
+		return AttrWritesRa | AttrReadsMem
+	case "stw", "stb":
+		return AttrWritesMem
+	case "beq":
+		return AttrBranches
+	case "adi", "lui", "and", "or", "xor", "slt", "not", "neg":
+		return AttrWritesRa
+	case "add", "sub", "lsr", "lsl", "asr", "lsri", "lsli", "asri":
+		return AttrWritesRa | AttrSetsC
+	case "jlr":
+		return AttrWritesRa | AttrBranches
+	case "lsp":
+		return AttrWritesRa | AttrPriv
+	case "ssp":
+		return AttrWritesSpr | AttrPriv
+	case "lio":
+		return AttrWritesRa | AttrIo
+	case "sio":
+		return AttrIo
+	case "sys":
+		return AttrBranches | AttrWritesSpr
+	case "tas":
+		return AttrWritesRa | AttrReadsMem | AttrWritesMem
+	case "hlt":
+		return AttrPriv
+	case "rti":
+		return AttrBranches | AttrWritesSpr | AttrPriv
+	case "di", "ei":
+		return AttrWritesSpr | AttrPriv
+	case "brk", "wfi":
+		return 0
+	}
+	return 0
+}
+
+// Major opcodes (bits 15..13).
+const (
+	OpLdw = 0 // ldw ra, rb, imm7
+	OpLdb = 1 // ldb ra, rb, imm7
+	OpStw = 2 // stw ra, rb, imm7
+	OpStb = 3 // stb ra, rb, imm7
+	OpBeq = 4 // beq ra, rb, imm7 (pc-relative words)
+	OpAdi = 5 // adi ra, rb, imm7
+	OpLui = 6 // lui ra, imm10
+	OpExt = 7 // extended: XOP/YOP/VOP spaces
+)
+
+// XOPs (opcode 7, bits 12..10). XOPs 0..4 are two-register ALU
+// operations that leave their result in ra. XOP 5 is jlr, whose
+// unsigned immediate occupies bits 9..6. XOP 6 escapes to the YOP
+// space.
+const (
+	XopAdd = 0 // add ra, rb
+	XopSub = 1 // sub ra, rb
+	XopAnd = 2 // and ra, rb
+	XopOr  = 3 // or ra, rb
+	XopXor = 4 // xor ra, rb
+	XopJlr = 5 // jlr ra, rb, imm4
+	XopYop = 6 // escape to YOP space
+	XopSlt = 7 // slt ra, rb
+)
+
+// YOPs (opcode 7, XOP 6, bits 9..6). YOPs 0..4 are unary register
+// operations on ra with the rb field reserved as zero. YOPs 5..9 use
+// the rb field as a special register index, a port address register,
+// or part of an immediate. YOP 15 escapes to the VOP space; YOP 14 is
+// unallocated.
+const (
+	YopLsr  = 0  // lsr ra: logical shift right one bit
+	YopLsl  = 1  // lsl ra: logical shift left one bit
+	YopAsr  = 2  // asr ra: arithmetic shift right one bit
+	YopNot  = 3  // not ra: ones complement
+	YopNeg  = 4  // neg ra: twos complement
+	YopLsp  = 5  // lsp ra, spr
+	YopSsp  = 6  // ssp ra, spr
+	YopLio  = 7  // lio ra, rb
+	YopSio  = 8  // sio ra, rb
+	YopSys  = 9  // sys imm6 (imm6 in bits 5..0)
+	YopLsri = 10 // lsri ra, n: logical shift right by n (rb field)
+	YopLsli = 11 // lsli ra, n: logical shift left by n
+	YopAsri = 12 // asri ra, n: arithmetic shift right by n
+	YopTas  = 13 // tas ra, rb: atomic test-and-set
+	YopVop  = 15 // escape to VOP space
+)
+
+// VOPs (opcode 7, XOP 6, YOP 15, bits 5..0). No operands.
+const (
+	VopHlt = 0 // hlt: stop the machine (kernel only)
+	VopRti = 1 // rti: return from exception or interrupt (kernel only)
+	VopDi  = 2 // di: disable interrupts (kernel only)
+	VopEi  = 3 // ei: enable interrupts (kernel only)
+	VopBrk = 4 // brk: break to the debugger
+	VopWfi = 5 // wfi: wait for interrupt; idle until an interrupt is pending
+)
+
+// Special purpose registers, addressed by lsp/ssp.
+const (
+	SprPsw   = 0 // processor status word
+	SprCause = 1 // cause of the most recent exception
+	SprEpc   = 2 // pc saved by the most recent exception
+	SprVbase = 3 // imem word address of the exception vector table
+	SprIpl   = 4 // interrupt priority level: deliver only sources above it
+	SprFar   = 5 // address that raised the most recent memory fault
+	SprFtype = 6 // fault-type bits for the most recent memory fault
+	NumSpr   = 8
+
+	// SPR numbers NumAssignedSpr..NumSpr-1 are reserved. Real
+	// hardware rejects accesses to them; the emulator warns by
+	// default and faults under -strict.
+	NumAssignedSpr = 7
+)
+
+// PSW bits.
+const (
+	PswK  = 1 << 0 // kernel mode
+	PswIe = 1 << 1 // interrupts enabled
+	PswC  = 1 << 2 // carry
+)
+
+// Fault-type bits recorded in the ftype SPR when ExMemory is raised;
+// the faulting address lands in far. tas sets both read and write.
+// Execute is reserved for an implementation whose MMU can fault a
+// fetch; the emulator's imem covers the whole address space.
+const (
+	FtRead  = 1 << 0
+	FtWrite = 1 << 1
+	FtExec  = 1 << 2
+)
+
+// Exception causes. A sys imm6 trap has cause ExSysBase+imm6.
+const (
+	ExIllegal = 1  // illegal or unallocated instruction
+	ExMemory  = 2  // memory fault
+	ExDouble  = 3  // double fault
+	ExIrq     = 4  // external interrupt
+	ExIoPerm  = 5  // user-mode i/o to a port without permission
+	ExSysBase = 16 // base cause for sys traps
+)
+
+// CauseName renders an exception cause for people: the architected
+// name for the hardware causes, "sys n" for a trap, and the bare
+// number for anything unallocated. Every tool that prints a cause
+// goes through here, so the names cannot diverge between the
+// emulator's logs, the debugger, and the disassembler.
+func CauseName(cause uint16) string {
+	switch cause {
+	case ExIllegal:
+		return "illegal"
+	case ExMemory:
+		return "memory"
+	case ExDouble:
+		return "double"
+	case ExIrq:
+		return "irq"
+	case ExIoPerm:
+		return "ioperm"
+	}
+	if cause >= ExSysBase && cause < ExSysBase+64 {
+		return fmt.Sprintf("sys %d", cause-ExSysBase)
+	}
+	return fmt.Sprintf("cause %d", cause)
+}
+
+// VectorBase is the reset value of the vbase SPR, which holds the
+// imem word address of the exception vector table. The slot for cause
+// n is word vbase+n; slot 0 is reset. A kernel can move the table by
+// writing vbase; the zero default keeps old images working.
+const VectorBase = 0
+
+// Format identifies the operand shape of an instruction, which is all
+// the assembler and disassembler need to know beyond the opcode.
+type Format int
+
+const (
+	FmtMem    Format = iota // op ra, rb, imm7 (signed displacement)
+	FmtBranch               // op ra, rb, target (signed pc-relative imm7)
+	FmtAdi                  // op ra, rb, imm7 (signed immediate)
+	FmtLui                  // op ra, imm10
+	FmtXop                  // op ra, rb
+	FmtJlr                  // op ra, rb, imm4 (unsigned)
+	FmtSpr                  // op ra, spr
+	FmtIo                   // op ra, rb (port address in rb)
+	FmtSys                  // op imm6 (unsigned)
+	FmtShi                  // op ra, n (shift count 0..7 in the rb field)
+	FmtYop                  // op ra
+	FmtVop                  // op
+)
+
+// Def describes one instruction for the assembler, disassembler, and
+// documentation tooling.
+type Def struct {
+	Mnemonic string
+	Fmt      Format
+	Op       int // major opcode
+	Xop      int // valid when Op == OpExt
+	Yop      int // valid when Xop == XopYop
+	Vop      int // valid when Yop == YopVop
+	Descr    string
+}
+
+// Defs lists every allocated instruction, in the order used by the
+// documentation generator: base ops, then XOPs, YOPs, VOPs.
+var Defs = []Def{
+	{"ldw", FmtMem, OpLdw, 0, 0, 0, "load word: ra = dmem[rb+imm7]"},
+	{"ldb", FmtMem, OpLdb, 0, 0, 0, "load byte: ra = zext(dmem[rb+imm7])"},
+	{"stw", FmtMem, OpStw, 0, 0, 0, "store word: dmem[rb+imm7] = ra"},
+	{"stb", FmtMem, OpStb, 0, 0, 0, "store byte: dmem[rb+imm7] = ra & 0xFF"},
+	{"beq", FmtBranch, OpBeq, 0, 0, 0, "branch to pc+1+imm7 if ra == rb"},
+	{"adi", FmtAdi, OpAdi, 0, 0, 0, "add immediate: ra = rb + imm7"},
+	{"lui", FmtLui, OpLui, 0, 0, 0, "load upper immediate: ra = imm10 << 6"},
+
+	{"add", FmtXop, OpExt, XopAdd, 0, 0, "add: ra = ra + rb; sets carry"},
+	{"sub", FmtXop, OpExt, XopSub, 0, 0, "subtract: ra = ra - rb; sets carry (borrow)"},
+	{"and", FmtXop, OpExt, XopAnd, 0, 0, "bitwise and: ra = ra & rb"},
+	{"or", FmtXop, OpExt, XopOr, 0, 0, "bitwise or: ra = ra | rb"},
+	{"xor", FmtXop, OpExt, XopXor, 0, 0, "bitwise xor: ra = ra ^ rb"},
+	{"jlr", FmtJlr, OpExt, XopJlr, 0, 0, "jump and link: ra = pc+1, pc = rb + imm4"},
+	{"slt", FmtXop, OpExt, XopSlt, 0, 0, "set if less than: ra = 1 if ra < rb (signed) else 0"},
+
+	{"lsr", FmtYop, OpExt, XopYop, YopLsr, 0, "logical shift right one bit; bit 0 to carry"},
+	{"lsl", FmtYop, OpExt, XopYop, YopLsl, 0, "logical shift left one bit; bit 15 to carry"},
+	{"asr", FmtYop, OpExt, XopYop, YopAsr, 0, "arithmetic shift right one bit; bit 0 to carry"},
+	{"not", FmtYop, OpExt, XopYop, YopNot, 0, "ones complement: ra = ^ra"},
+	{"neg", FmtYop, OpExt, XopYop, YopNeg, 0, "twos complement: ra = -ra"},
+	{"lsp", FmtSpr, OpExt, XopYop, YopLsp, 0, "load special register: ra = spr[n] (kernel only)"},
+	{"ssp", FmtSpr, OpExt, XopYop, YopSsp, 0, "store special register: spr[n] = ra (kernel only)"},
+	{"lio", FmtIo, OpExt, XopYop, YopLio, 0, "load from I/O port addressed by rb into ra"},
+	{"sio", FmtIo, OpExt, XopYop, YopSio, 0, "store ra to I/O port addressed by rb"},
+	{"sys", FmtSys, OpExt, XopYop, YopSys, 0, "system trap to kernel, cause 16+imm6"},
+	{"lsri", FmtShi, OpExt, XopYop, YopLsri, 0, "logical shift right by n (0..7); last bit out to carry"},
+	{"lsli", FmtShi, OpExt, XopYop, YopLsli, 0, "logical shift left by n (0..7); last bit out to carry"},
+	{"asri", FmtShi, OpExt, XopYop, YopAsri, 0, "arithmetic shift right by n (0..7); last bit out to carry"},
+	{"tas", FmtIo, OpExt, XopYop, YopTas, 0, "atomic test-and-set: ra = dmem[rb], dmem[rb] = 0xFFFF"},
+
+	{"hlt", FmtVop, OpExt, XopYop, YopVop, VopHlt, "halt the machine (kernel only)"},
+	{"rti", FmtVop, OpExt, XopYop, YopVop, VopRti, "return from exception (kernel only)"},
+	{"di", FmtVop, OpExt, XopYop, YopVop, VopDi, "disable interrupts (kernel only)"},
+	{"ei", FmtVop, OpExt, XopYop, YopVop, VopEi, "enable interrupts (kernel only)"},
+	{"brk", FmtVop, OpExt, XopYop, YopVop, VopBrk, "break to the debugger"},
+	{"wfi", FmtVop, OpExt, XopYop, YopVop, VopWfi, "wait for interrupt: idle until an interrupt is pending"},
+}
+
+// Field extraction.
+
+func GetOp(w uint16) int    { return int(w >> 13) }
+func GetImm7(w uint16) int  { return sext(int(w>>6)&0x7F, 7) }
+func GetImm10(w uint16) int { return int(w>>3) & 0x3FF }
+func GetRb(w uint16) int    { return int(w>>3) & 7 }
+func GetRa(w uint16) int    { return int(w) & 7 }
+func GetXop(w uint16) int   { return int(w>>10) & 7 }
+func GetImm4(w uint16) int  { return int(w>>6) & 0xF }
+func GetYop(w uint16) int   { return int(w>>6) & 0xF }
+func GetVop(w uint16) int   { return int(w) & 0x3F }
+func GetSpr(w uint16) int   { return int(w>>3) & 7 }
+func GetShn(w uint16) int   { return int(w>>3) & 7 }
+func GetSys(w uint16) int   { return int(w) & 0x3F }
+
+func sext(v, bits int) int {
+	m := 1 << (bits - 1)
+	return (v ^ m) - m
+}
+
+// Encoding. Each function masks its arguments to field width; range
+// checking is the assembler's responsibility.
+
+func EncodeMem(op, ra, rb, imm7 int) uint16 {
+	return uint16(op&7)<<13 | uint16(imm7&0x7F)<<6 | uint16(rb&7)<<3 | uint16(ra&7)
+}
+
+func EncodeLui(ra, imm10 int) uint16 {
+	return uint16(OpLui)<<13 | uint16(imm10&0x3FF)<<3 | uint16(ra&7)
+}
+
+func EncodeXop(xop, ra, rb int) uint16 {
+	return uint16(OpExt)<<13 | uint16(xop&7)<<10 | uint16(rb&7)<<3 | uint16(ra&7)
+}
+
+func EncodeJlr(ra, rb, imm4 int) uint16 {
+	return uint16(OpExt)<<13 | uint16(XopJlr)<<10 | uint16(imm4&0xF)<<6 | uint16(rb&7)<<3 | uint16(ra&7)
+}
+
+func EncodeYop(yop, ra int) uint16 {
+	return uint16(OpExt)<<13 | uint16(XopYop)<<10 | uint16(yop&0xF)<<6 | uint16(ra&7)
+}
+
+func EncodeSpr(yop, ra, spr int) uint16 {
+	return EncodeYop(yop, ra) | uint16(spr&7)<<3
+}
+
+func EncodeIo(yop, ra, rb int) uint16 {
+	return EncodeYop(yop, ra) | uint16(rb&7)<<3
+}
+
+func EncodeShi(yop, ra, n int) uint16 {
+	return EncodeYop(yop, ra) | uint16(n&7)<<3
+}
+
+func EncodeSys(imm6 int) uint16 {
+	return uint16(OpExt)<<13 | uint16(XopYop)<<10 | uint16(YopSys)<<6 | uint16(imm6&0x3F)
+}
+
+func EncodeVop(vop int) uint16 {
+	return uint16(OpExt)<<13 | uint16(XopYop)<<10 | uint16(YopVop)<<6 | uint16(vop&0x3F)
+}
+
+// Decode finds the Def for an instruction word. It returns nil for
+// words in reserved encoding space; such words take an illegal
+// instruction exception when executed.
+func Decode(w uint16) *Def {
+	op := GetOp(w)
+	for i := range Defs {
+		d := &Defs[i]
+		if d.Op != op {
+			continue
+		}
+		if op != OpExt {
+			return d
+		}
+		if d.Xop != GetXop(w) {
+			continue
+		}
+		if d.Xop != XopYop {
+			return d
+		}
+		if d.Yop != GetYop(w) {
+			continue
+		}
+		if d.Yop != YopVop {
+			return d
+		}
+		if d.Vop == GetVop(w) {
+			return d
+		}
+	}
+	return nil
+}
+
+// ByMnemonic returns the Def for a mnemonic, or nil.
+func ByMnemonic(m string) *Def {
+	for i := range Defs {
+		if Defs[i].Mnemonic == m {
+			return &Defs[i]
+		}
+	}
+	return nil
+}
+
+// RegName returns the conventional name of general register n.
+func RegName(n int) string { return fmt.Sprintf("r%d", n&7) }
+
+// Disassemble renders one instruction word at the given code address
+// (the address is needed to print branch targets as absolute). Words
+// in reserved encoding space render as "?".
+func Disassemble(w uint16, addr int) string {
+	d := Decode(w)
+	if d == nil {
+		return "?"
+	}
+	ra := RegName(GetRa(w))
+	rb := RegName(GetRb(w))
+	switch d.Fmt {
+	case FmtMem, FmtAdi:
+		return fmt.Sprintf("%s %s, %s, %d", d.Mnemonic, ra, rb, GetImm7(w))
+	case FmtBranch:
+		return fmt.Sprintf("%s %s, %s, 0x%04X", d.Mnemonic, ra, rb, addr+1+GetImm7(w))
+	case FmtLui:
+		return fmt.Sprintf("%s %s, 0x%X", d.Mnemonic, ra, GetImm10(w))
+	case FmtXop, FmtIo:
+		return fmt.Sprintf("%s %s, %s", d.Mnemonic, ra, rb)
+	case FmtJlr:
+		return fmt.Sprintf("%s %s, %s, %d", d.Mnemonic, ra, rb, GetImm4(w))
+	case FmtSpr:
+		return fmt.Sprintf("%s %s, %d", d.Mnemonic, ra, GetSpr(w))
+	case FmtSys:
+		return fmt.Sprintf("%s %d", d.Mnemonic, GetSys(w))
+	case FmtShi:
+		return fmt.Sprintf("%s %s, %d", d.Mnemonic, ra, GetShn(w))
+	case FmtYop:
+		return fmt.Sprintf("%s %s", d.Mnemonic, ra)
+	default: // FmtVop
+		return d.Mnemonic
+	}
+}
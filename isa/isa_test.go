@@ -0,0 +1,73 @@
+package isa
+
+import "testing"
+
+// Every allocated instruction must decode back to its own Def after a
+// round trip through the encoder for its format.
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for i := range Defs {
+		d := &Defs[i]
+		var w uint16
+		switch d.Fmt {
+		case FmtMem, FmtBranch, FmtAdi:
+			w = EncodeMem(d.Op, 3, 5, -7)
+		case FmtLui:
+			w = EncodeLui(3, 0x2AA)
+		case FmtXop:
+			w = EncodeXop(d.Xop, 3, 5)
+		case FmtJlr:
+			w = EncodeJlr(1, 5, 9)
+		case FmtSpr:
+			w = EncodeSpr(d.Yop, 3, SprEpc)
+		case FmtIo:
+			w = EncodeIo(d.Yop, 3, 5)
+		case FmtSys:
+			w = EncodeSys(21)
+		case FmtShi:
+			w = EncodeShi(d.Yop, 3, 5)
+		case FmtYop:
+			w = EncodeYop(d.Yop, 3)
+		case FmtVop:
+			w = EncodeVop(d.Vop)
+		}
+		got := Decode(w)
+		if got == nil {
+			t.Fatalf("%s: decode(0x%04X) = nil", d.Mnemonic, w)
+		}
+		if got.Mnemonic != d.Mnemonic {
+			t.Errorf("%s: decode(0x%04X) = %s", d.Mnemonic, w, got.Mnemonic)
+		}
+	}
+}
+
+func TestFieldExtraction(t *testing.T) {
+	w := EncodeMem(OpAdi, 3, 5, -7)
+	if GetOp(w) != OpAdi || GetRa(w) != 3 || GetRb(w) != 5 || GetImm7(w) != -7 {
+		t.Errorf("adi fields wrong: op=%d ra=%d rb=%d imm=%d",
+			GetOp(w), GetRa(w), GetRb(w), GetImm7(w))
+	}
+	if GetSys(EncodeSys(33)) != 33 {
+		t.Error("sys immediate field wrong")
+	}
+}
+
+func TestCauseName(t *testing.T) {
+	for _, c := range []struct {
+		cause uint16
+		want  string
+	}{
+		{ExIllegal, "illegal"},
+		{ExMemory, "memory"},
+		{ExDouble, "double"},
+		{ExIrq, "irq"},
+		{ExIoPerm, "ioperm"},
+		{ExSysBase, "sys 0"},
+		{ExSysBase + 63, "sys 63"},
+		{9, "cause 9"},
+		{ExSysBase + 64, "cause 80"},
+	} {
+		if got := CauseName(c.cause); got != c.want {
+			t.Errorf("CauseName(%d) = %q, want %q", c.cause, got, c.want)
+		}
+	}
+}
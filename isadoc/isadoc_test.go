@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// The rendered bit pattern of every instruction, with all operand
+// fields zero, must equal what the isa encoders produce — the whole
+// point of generating the reference is that it cannot drift.
+func TestEncodingMatchesEncoders(t *testing.T) {
+	for _, d := range isa.Defs {
+		var want uint16
+		switch d.Fmt {
+		case isa.FmtMem, isa.FmtBranch, isa.FmtAdi:
+			want = isa.EncodeMem(d.Op, 0, 0, 0)
+		case isa.FmtLui:
+			want = isa.EncodeLui(0, 0)
+		case isa.FmtXop:
+			want = isa.EncodeXop(d.Xop, 0, 0)
+		case isa.FmtJlr:
+			want = isa.EncodeJlr(0, 0, 0)
+		case isa.FmtYop:
+			want = isa.EncodeYop(d.Yop, 0)
+		case isa.FmtSpr:
+			want = isa.EncodeSpr(d.Yop, 0, 0)
+		case isa.FmtIo:
+			want = isa.EncodeIo(d.Yop, 0, 0)
+		case isa.FmtShi:
+			want = isa.EncodeShi(d.Yop, 0, 0)
+		case isa.FmtSys:
+			want = isa.EncodeSys(0)
+		case isa.FmtVop:
+			want = isa.EncodeVop(d.Vop)
+		default:
+			t.Fatalf("%s: unknown format %d", d.Mnemonic, d.Fmt)
+		}
+		if got := wordFromTemplate(t, d.Mnemonic, encoding(d)); got != want {
+			t.Errorf("%s: template %q = 0x%04X, encoder says 0x%04X",
+				d.Mnemonic, encoding(d), got, want)
+		}
+	}
+}
+
+// wordFromTemplate assembles the documented pattern with every
+// operand field (letter) as zero.
+func wordFromTemplate(t *testing.T, mnemonic, tmpl string) uint16 {
+	var w uint16
+	n := 0
+	for _, c := range tmpl {
+		switch {
+		case c == ' ':
+			continue
+		case c == '1':
+			w = w<<1 | 1
+		default:
+			w <<= 1
+		}
+		n++
+	}
+	if n != 16 {
+		t.Fatalf("%s: template %q has %d bits, want 16", mnemonic, tmpl, n)
+	}
+	return w
+}
@@ -0,0 +1,194 @@
+// isadoc generates the WUT-4 opcode reference from the isa package's
+// instruction table, so the documentation cannot drift from what the
+// assembler and emulator implement. Output is a markdown table of
+// every allocated instruction with its syntax, encoding bit pattern,
+// and semantics, or the same as a standalone HTML page with -html.
+//
+// Usage: isadoc [-o out.md] [-html]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+var outFile = flag.String("o", "", "output file (default stdout)")
+var htmlOut = flag.Bool("html", false, "write a standalone HTML page instead of markdown")
+var mapOut = flag.Bool("map", false, "write an ASCII map of the encoding space instead")
+var version = flag.Bool("version", false, "report the ISA version and exit")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("isadoc isa=%d\n", isa.Version)
+		return
+	}
+	w := io.Writer(os.Stdout)
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			fatal("%v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	switch {
+	case *mapOut:
+		writeMap(w)
+	case *htmlOut:
+		writeHTML(w)
+	default:
+		writeMarkdown(w)
+	}
+}
+
+// row is one rendered instruction: everything but the prose comes
+// from the encoding, not hand-written text.
+type row struct {
+	mnemonic, syntax, encoding, semantics string
+}
+
+func rows() []row {
+	out := make([]row, len(isa.Defs))
+	for i, d := range isa.Defs {
+		out[i] = row{d.Mnemonic, syntax(d), encoding(d), d.Descr}
+	}
+	return out
+}
+
+// syntax renders the operand shape of a format, matching what the
+// assembler parses.
+func syntax(d isa.Def) string {
+	switch d.Fmt {
+	case isa.FmtMem, isa.FmtBranch, isa.FmtAdi:
+		return d.Mnemonic + " ra, rb, imm7"
+	case isa.FmtLui:
+		return d.Mnemonic + " ra, imm10"
+	case isa.FmtXop, isa.FmtIo:
+		return d.Mnemonic + " ra, rb"
+	case isa.FmtJlr:
+		return d.Mnemonic + " ra, rb, imm4"
+	case isa.FmtSpr:
+		return d.Mnemonic + " ra, spr"
+	case isa.FmtSys:
+		return d.Mnemonic + " imm6"
+	case isa.FmtShi:
+		return d.Mnemonic + " ra, n"
+	case isa.FmtYop:
+		return d.Mnemonic + " ra"
+	case isa.FmtVop:
+		return d.Mnemonic
+	}
+	return d.Mnemonic
+}
+
+// encoding renders the 16-bit pattern, fixed bits as 0/1 and fields
+// as letters (see the legend the writers emit).
+func encoding(d isa.Def) string {
+	switch d.Fmt {
+	case isa.FmtMem, isa.FmtBranch, isa.FmtAdi:
+		return bits(d.Op, 3) + " iiiiiii bbb aaa"
+	case isa.FmtLui:
+		return bits(d.Op, 3) + " iiiiiiiiii aaa"
+	case isa.FmtXop:
+		return bits(isa.OpExt, 3) + " " + bits(d.Xop, 3) + " 0000 bbb aaa"
+	case isa.FmtJlr:
+		return bits(isa.OpExt, 3) + " " + bits(d.Xop, 3) + " iiii bbb aaa"
+	case isa.FmtYop:
+		return yopBits(d) + " 000 aaa"
+	case isa.FmtSpr:
+		return yopBits(d) + " sss aaa"
+	case isa.FmtIo:
+		return yopBits(d) + " bbb aaa"
+	case isa.FmtShi:
+		return yopBits(d) + " nnn aaa"
+	case isa.FmtSys:
+		return yopBits(d) + " iiiiii"
+	case isa.FmtVop:
+		return yopBits(d) + " " + bits(d.Vop, 6)
+	}
+	return ""
+}
+
+func yopBits(d isa.Def) string {
+	return bits(isa.OpExt, 3) + " " + bits(isa.XopYop, 3) + " " + bits(d.Yop, 4)
+}
+
+func bits(v, n int) string {
+	s := ""
+	for i := n - 1; i >= 0; i-- {
+		s += string('0' + byte(v>>i&1))
+	}
+	return s
+}
+
+const legend = "Bit patterns read from bit 15 down to bit 0; fixed bits are 0/1. " +
+	"Field letters: aaa = ra, bbb = rb, i... = immediate, sss = special " +
+	"register number, nnn = shift count. Signed immediates (imm7) are " +
+	"two's complement; all others are unsigned."
+
+// sprNote documents the SPR file, including the architected rules
+// for the reserved numbers, from the isa constants so the counts
+// cannot drift from the implementation.
+var sprNote = fmt.Sprintf("Special registers 0-%d (psw, cause, epc, vbase, ipl, far, "+
+	"ftype) are reachable only from kernel mode; lsp and ssp are illegal in user "+
+	"mode regardless of the register number. The cause register is read-only, and "+
+	"numbers %d-%d are reserved: reserved reads return zero and reserved or "+
+	"read-only writes are discarded. Hardware is free to reject these accesses "+
+	"outright, which the emulator models under -strict by raising the illegal "+
+	"instruction exception instead.",
+	isa.NumAssignedSpr-1, isa.NumAssignedSpr, isa.NumSpr-1)
+
+const mmuNote = "Data addresses pass through an optional sixteen-entry page map " +
+	"(4K pages) programmed over I/O ports 24-27. Each entry carries a four-bit " +
+	"ASID tag matched against the current ASID register, so a context switch is " +
+	"one register write instead of a rewrite of the whole map; the tag costs " +
+	"four flip-flops per entry, cheap against the sixteen entry writes it saves " +
+	"on every switch. Instruction fetch is never translated."
+
+func writeMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "# WUT-4 instruction set (ISA version %d)\n\n", isa.Version)
+	fmt.Fprintf(w, "Generated by isadoc from the isa package; do not edit.\n\n")
+	fmt.Fprintf(w, "%s\n\n", legend)
+	fmt.Fprintf(w, "%s\n\n", sprNote)
+	fmt.Fprintf(w, "%s\n\n", mmuNote)
+	fmt.Fprintf(w, "| Mnemonic | Syntax | Encoding | Semantics |\n")
+	fmt.Fprintf(w, "|---|---|---|---|\n")
+	for _, r := range rows() {
+		// A literal | in the semantics (bitwise or) would end the cell.
+		fmt.Fprintf(w, "| %s | `%s` | `%s` | %s |\n",
+			r.mnemonic, r.syntax, r.encoding,
+			strings.ReplaceAll(r.semantics, "|", "\\|"))
+	}
+}
+
+func writeHTML(w io.Writer) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">\n")
+	fmt.Fprintf(w, "<title>WUT-4 instruction set</title>\n")
+	fmt.Fprintf(w, "<style>table{border-collapse:collapse}td,th{border:1px solid #999;"+
+		"padding:4px 8px;text-align:left}code{white-space:pre}</style>\n")
+	fmt.Fprintf(w, "</head><body>\n<h1>WUT-4 instruction set (ISA version %d)</h1>\n", isa.Version)
+	fmt.Fprintf(w, "<p>Generated by isadoc from the isa package; do not edit.</p>\n")
+	fmt.Fprintf(w, "<p>%s</p>\n", html.EscapeString(legend))
+	fmt.Fprintf(w, "<p>%s</p>\n", html.EscapeString(sprNote))
+	fmt.Fprintf(w, "<p>%s</p>\n", html.EscapeString(mmuNote))
+	fmt.Fprintf(w, "<table>\n<tr><th>Mnemonic</th><th>Syntax</th>"+
+		"<th>Encoding</th><th>Semantics</th></tr>\n")
+	for _, r := range rows() {
+		fmt.Fprintf(w, "<tr><td>%s</td><td><code>%s</code></td><td><code>%s</code></td><td>%s</td></tr>\n",
+			html.EscapeString(r.mnemonic), html.EscapeString(r.syntax),
+			html.EscapeString(r.encoding), html.EscapeString(r.semantics))
+	}
+	fmt.Fprintf(w, "</table>\n</body></html>\n")
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "isadoc: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,89 @@
+// The -map mode: an ASCII map of the 16-bit encoding space showing
+// which ranges each instruction occupies and what remains free, to
+// guide future additions. Like the reference tables, everything is
+// derived from isa.Defs, so the map is the allocation ground truth.
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+func writeMap(w io.Writer) {
+	ops := make(map[int]string)
+	xops := make(map[int]string)
+	yops := make(map[int]string)
+	vops := make(map[int]string)
+	for _, d := range isa.Defs {
+		switch {
+		case d.Op != isa.OpExt:
+			ops[d.Op] = d.Mnemonic
+		case d.Xop != isa.XopYop:
+			xops[d.Xop] = d.Mnemonic
+		case d.Yop != isa.YopVop:
+			yops[d.Yop] = d.Mnemonic
+		default:
+			vops[d.Vop] = d.Mnemonic
+		}
+	}
+	ops[isa.OpExt] = "ext"
+	xops[isa.XopYop] = "yop"
+	yops[isa.YopVop] = "vop"
+
+	freeWords := 0
+	count := func(m map[int]string, slots, words int) int {
+		n := 0
+		for i := 0; i < slots; i++ {
+			if m[i] == "" {
+				n++
+			}
+		}
+		freeWords += n * words
+		return n
+	}
+
+	fmt.Fprintf(w, "WUT-4 encoding space (ISA version %d)\n\n", isa.Version)
+	level := func(title string, m map[int]string, slots, bits, words int, next string) {
+		if words == 1 {
+			fmt.Fprintf(w, "%s, 1 word per slot\n", title)
+		} else {
+			fmt.Fprintf(w, "%s, %d words per slot\n", title, words)
+		}
+		for i := 0; i < slots; {
+			if m[i] == "" {
+				j := i
+				for j < slots && m[j] == "" {
+					j++
+				}
+				if j-i == 1 {
+					fmt.Fprintf(w, "  %0*b  -- free --\n", bits, i)
+				} else {
+					fmt.Fprintf(w, "  %0*b-%0*b  -- free -- (%d slots)\n",
+						bits, i, bits, j-1, j-i)
+				}
+				i = j
+				continue
+			}
+			name := m[i]
+			if name == next {
+				name += "  (see below)"
+			}
+			fmt.Fprintf(w, "  %0*b  %s\n", bits, i, name)
+			i++
+		}
+		fmt.Fprintln(w)
+	}
+	level("op (bits 15..13)", ops, 8, 3, 8192, "ext")
+	level("ext: xop (bits 12..10)", xops, 8, 3, 1024, "yop")
+	level("yop (bits 9..6)", yops, 16, 4, 64, "vop")
+	level("vop (bits 5..0)", vops, 64, 6, 1, "")
+
+	count(ops, 8, 8192)
+	count(xops, 8, 1024)
+	count(yops, 16, 64)
+	count(vops, 64, 1)
+	fmt.Fprintf(w, "free: %d of 65536 encodings (%.1f%%)\n",
+		freeWords, 100*float64(freeWords)/65536)
+}
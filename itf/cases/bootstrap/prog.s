@@ -0,0 +1,26 @@
+; The first rung of the self-hosting ladder: a native program that
+; reads its "source" from the semihosting input device and writes it
+; to the console. When the self-hosted assembler exists it will sit
+; in this same harness, reading real source the same way.
+.set FILE_DATA, 16
+.set FILE_STATUS, 17
+.set CON_OUT, 0
+
+_start:
+    adi r5, r0, FILE_STATUS
+loop:
+    lio r2, r5           ; status: bit 0 data, bit 1 eof
+    adi r4, r0, 1
+    and r4, r2
+    bne r4, r0, havebyte
+    hlt                  ; no data and not eof cannot happen; eof ends the run
+havebyte:
+    adi r4, r0, FILE_DATA
+    lio r2, r4
+    adi r4, r0, CON_OUT
+    sio r2, r4
+    lio r2, r5           ; still more input?
+    adi r4, r0, 1
+    and r4, r2
+    bne r4, r0, loop
+    hlt
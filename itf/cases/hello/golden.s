@@ -0,0 +1,62 @@
+_start:
+    li r6, 0xFFFE
+    li r7, main
+    jlr r1, r7, 0
+    hlt
+.func puts, 6
+    stw r2, r6, 0
+    mov r2, r0
+    stw r2, r6, 2
+    li r2, 1
+    stw r2, r6, 4
+.L1:
+    ldw r2, r6, 4
+    mov r3, r0
+    sub r2, r3
+    beq r2, r0, .L3
+    li r2, 1
+    jmp .L4
+.L3:
+    li r2, 0
+.L4:
+    beq r2, r0, .L2
+    ldw r2, r6, 2
+    ldw r7, r6, 0
+    add r2, r7
+    ldb r2, r2, 0
+    stw r2, r6, 4
+    ldw r2, r6, 4
+    mov r3, r0
+    sub r2, r3
+    beq r2, r0, .L5
+    li r2, 1
+    jmp .L6
+.L5:
+    li r2, 0
+.L6:
+    beq r2, r0, .L7
+    mov r2, r0
+    ldw r3, r6, 4
+    sio r3, r2
+.L7:
+    ldw r2, r6, 2
+    li r3, 1
+    add r2, r3
+    stw r2, r6, 2
+    jmp .L1
+.L2:
+puts_ret:
+.endfunc
+.func main, 2
+    li r2, .D1
+    stw r2, r6, 0
+    ldw r2, r6, 0
+    li r7, puts
+    jlr r1, r7, 0
+    mov r7, r2
+    mov r2, r7
+main_ret:
+.endfunc
+.data
+.D1:
+    .byte 104, 101, 108, 108, 111, 44, 32, 119, 117, 116, 45, 52, 10, 0
@@ -0,0 +1,16 @@
+; The canonical first case: print a greeting and halt.
+_start:
+    li r3, msg
+    adi r5, r0, 0        ; console output port
+loop:
+    ldb r2, r3, 0
+    beq r2, r0, done
+    sio r2, r5
+    adi r3, r3, 1
+    jmp loop
+done:
+    hlt
+
+.data
+msg: .string "hello, wut-4\n"
+     .byte 0
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// unifiedDiff returns a unified diff of two small text files, enough
+// for readable itf failure reports without shelling out to diff.
+// want is labeled with the golden file's name, got as the actual
+// output.
+func unifiedDiff(label string, want, got []byte) string {
+	a := splitLines(want)
+	b := splitLines(got)
+	ops := diffOps(a, b)
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ actual\n", label)
+	i := 0
+	for i < len(ops) {
+		// Skip to the next change.
+		if ops[i].kind == opKeep {
+			i++
+			continue
+		}
+		// Hunk starts up to `context` keeps before this change and
+		// extends until `context` keeps follow the last change.
+		start := i
+		for start > 0 && ops[start-1].kind == opKeep && i-start < context {
+			start--
+		}
+		end := i
+		keeps := 0
+		for end < len(ops) && keeps < context {
+			if ops[end].kind == opKeep {
+				keeps++
+			} else {
+				keeps = 0
+			}
+			end++
+		}
+		// Trim trailing keeps beyond the context window.
+		for end > i && keeps > context {
+			end--
+			keeps--
+		}
+
+		aLine, bLine, aN, bN := hunkRange(ops, start, end)
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aLine, aN, bLine, bN)
+		for _, op := range ops[start:end] {
+			switch op.kind {
+			case opKeep:
+				out.WriteString(" " + op.text + "\n")
+			case opDel:
+				out.WriteString("-" + op.text + "\n")
+			case opIns:
+				out.WriteString("+" + op.text + "\n")
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+const (
+	opKeep = iota
+	opDel  // only in want
+	opIns  // only in got
+)
+
+type diffOp struct {
+	kind  int
+	text  string
+	aLine int // 1-based line in want (keep, del)
+	bLine int // 1-based line in got (keep, ins)
+}
+
+// hunkRange computes the @@ header numbers for ops[start:end].
+func hunkRange(ops []diffOp, start, end int) (aLine, bLine, aN, bN int) {
+	aLine, bLine = 1, 1
+	for _, op := range ops[start:end] {
+		switch op.kind {
+		case opKeep:
+			aN++
+			bN++
+		case opDel:
+			aN++
+		case opIns:
+			bN++
+		}
+	}
+	if aN > 0 || bN > 0 {
+		for _, op := range ops[start:end] {
+			if op.kind != opIns {
+				aLine = op.aLine
+				break
+			}
+		}
+		for _, op := range ops[start:end] {
+			if op.kind != opDel {
+				bLine = op.bLine
+				break
+			}
+		}
+	}
+	return aLine, bLine, aN, bN
+}
+
+// diffOps computes a line-level edit script by LCS backtracking.
+// Outputs here are small, so the quadratic table is fine.
+func diffOps(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{opKeep, a[i], i + 1, j + 1})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{opDel, a[i], i + 1, 0})
+			i++
+		default:
+			ops = append(ops, diffOp{opIns, b[j], 0, j + 1})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{opDel, a[i], i + 1, 0})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{opIns, b[j], 0, j + 1})
+	}
+	return ops
+}
+
+// splitLines splits on newlines, dropping the trailing empty element
+// a final newline produces.
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	lines := strings.Split(string(bytes.TrimSuffix(b, []byte("\n"))), "\n")
+	return lines
+}
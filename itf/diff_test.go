@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	want := []byte("a\nb\nc\nd\n")
+	got := []byte("a\nB\nc\nd\ne\n")
+	d := unifiedDiff("expect.txt", want, got)
+	for _, line := range []string{"--- expect.txt", "+++ actual", "-b", "+B", "+e", " c"} {
+		if !strings.Contains(d, line+"\n") {
+			t.Errorf("diff missing %q:\n%s", line, d)
+		}
+	}
+	if strings.Contains(d, "-a") || strings.Contains(d, "+a") {
+		t.Errorf("unchanged line marked changed:\n%s", d)
+	}
+}
+
+func TestUnifiedDiffIdentical(t *testing.T) {
+	d := unifiedDiff("expect.txt", []byte("x\n"), []byte("x\n"))
+	if strings.Contains(d, "@@") {
+		t.Errorf("identical inputs produced hunks:\n%s", d)
+	}
+}
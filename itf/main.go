@@ -0,0 +1,308 @@
+// itf is the integration test framework for the WUT-4 toolchain. It
+// drives the installed tools as subprocesses over a directory of
+// cases, so it tests exactly what users run.
+//
+// A case is a directory under -cases containing prog.s and
+// expect.txt: itf assembles prog.s, runs it under func with empty
+// stdin, and compares the program's stdout against expect.txt. If
+// the case also contains input.txt, that file is attached to func's
+// semihosting input device (-in), which is how the bootstrap cases
+// feed WUT-4-native programs their source: today a trivial echo,
+// eventually the self-hosted assembler reading the same corpus.
+//
+// Every case is also assembled twice into separate files and the
+// binaries compared byte for byte: the assembler guarantees
+// reproducible output (no map-ordering or timestamp dependence), and
+// itf enforces that guarantee on every case corpus.
+//
+// A case may hold prog.yapl instead of prog.s. Then itf first
+// compiles it and compares the emitted assembly, normalized (comments
+// and blank lines stripped), against the case's golden.s before the
+// pipeline above runs on the compiler's output. Codegen regressions
+// show up twice: as an assembly diff and as a runtime output diff.
+//
+// Usage: itf [-bindir dir] [-cases dir] [-update]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var binDir = flag.String("bindir", "", "directory holding asm and func (default $PATH)")
+var casesDir = flag.String("cases", "cases", "directory of test cases")
+var update = flag.Bool("update", false, "offer to overwrite golden files with actual output")
+var perfFile = flag.String("perf", "", "record cycles and cps per case to this history file")
+var regress = flag.Int("regress", 25, "warn when cps drops more than this percent (with -perf)")
+var junitFile = flag.String("junit", "", "also write a JUnit XML report to this file")
+var tapFile = flag.String("tap", "", "also write a TAP version 13 report to this file")
+
+type result struct {
+	name string
+	err  error // nil means pass
+
+	// Set on a golden mismatch, for diff display and -update.
+	dir      string
+	file     string // the golden file that mismatched
+	got      []byte
+	mismatch bool
+
+	// Set when -perf collected statistics for the run.
+	perf    perfEntry
+	hasPerf bool
+}
+
+func main() {
+	flag.Parse()
+	cases, err := findCases(*casesDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "itf: %v\n", err)
+		os.Exit(2)
+	}
+	if len(cases) == 0 {
+		fmt.Fprintf(os.Stderr, "itf: no cases under %s\n", *casesDir)
+		os.Exit(2)
+	}
+	tools := []string{"asm", "dis", "func"}
+	for _, c := range cases {
+		if fileExists(filepath.Join(c, "prog.yapl")) {
+			tools = append(tools, "yapl")
+			break
+		}
+	}
+	if err := checkVersions(tools); err != nil {
+		fmt.Fprintf(os.Stderr, "itf: %v\n", err)
+		os.Exit(2)
+	}
+
+	tmp, err := os.MkdirTemp("", "itf")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "itf: %v\n", err)
+		os.Exit(2)
+	}
+	defer os.RemoveAll(tmp)
+
+	confirm := bufio.NewScanner(os.Stdin)
+	history := loadPerfHistory(*perfFile)
+	var report []reportEntry
+	failed := 0
+	for _, c := range cases {
+		r := runCase(c, tmp)
+		if r.hasPerf && r.err == nil {
+			if warn := perfWarning(r.name, history[r.name], r.perf, *regress); warn != "" {
+				fmt.Printf("WARN %s\n", warn)
+			}
+			if err := appendPerfHistory(*perfFile, r.name, r.perf); err != nil {
+				fmt.Fprintf(os.Stderr, "itf: %v\n", err)
+			}
+		}
+		if r.mismatch && *update && confirmUpdate(confirm, r.name, r.file) {
+			if err := os.WriteFile(filepath.Join(r.dir, r.file), r.got, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "itf: %v\n", err)
+				os.Exit(2)
+			}
+			fmt.Printf("%-4s %s\n", "UPDT", r.name)
+			report = append(report, reportEntry{name: r.name})
+			continue
+		}
+		status := "PASS"
+		entry := reportEntry{name: r.name}
+		if r.err != nil {
+			status = "FAIL"
+			entry.msg = r.err.Error()
+			failed++
+		}
+		report = append(report, entry)
+		fmt.Printf("%-4s %s\n", status, r.name)
+		if r.err != nil {
+			fmt.Printf("     %v\n", r.err)
+		}
+	}
+	fmt.Printf("%d cases, %d failed\n", len(cases), failed)
+	if err := writeReports(report); err != nil {
+		fmt.Fprintf(os.Stderr, "itf: %v\n", err)
+		os.Exit(2)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkVersions queries each tool's -version stamp and fails fast
+// unless they all report the same ISA and object format versions, so
+// a stale binary in the path cannot cause confusing cross-version
+// test failures.
+func checkVersions(tools []string) error {
+	base, baseTool := "", ""
+	for _, t := range tools {
+		out, err := exec.Command(tool(t), "-version").Output()
+		if err != nil {
+			return fmt.Errorf("%s -version: %v", t, err)
+		}
+		line := strings.TrimSpace(string(out))
+		_, stamp, ok := strings.Cut(line, " ")
+		if !ok {
+			return fmt.Errorf("%s -version: unrecognized output %q", t, line)
+		}
+		if base == "" {
+			base, baseTool = stamp, t
+		} else if stamp != base {
+			return fmt.Errorf("version mismatch: %s reports %q but %s reports %q",
+				baseTool, base, t, stamp)
+		}
+	}
+	return nil
+}
+
+func findCases(dir string) ([]string, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var cases []string
+	for _, e := range ents {
+		if e.IsDir() {
+			if fileExists(filepath.Join(dir, e.Name(), "prog.s")) ||
+				fileExists(filepath.Join(dir, e.Name(), "prog.yapl")) {
+				cases = append(cases, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(cases)
+	return cases, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func tool(name string) string {
+	if *binDir != "" {
+		return filepath.Join(*binDir, name)
+	}
+	return name
+}
+
+func runCase(dir, tmp string) result {
+	name := filepath.Base(dir)
+	src := filepath.Join(dir, "prog.s")
+	if fileExists(filepath.Join(dir, "prog.yapl")) {
+		var r result
+		src, r = compileCase(dir, tmp, name)
+		if src == "" {
+			return r
+		}
+	}
+	bin1 := filepath.Join(tmp, name+".1.bin")
+	bin2 := filepath.Join(tmp, name+".2.bin")
+
+	// Assemble twice; outputs must be byte-identical.
+	for _, out := range []string{bin1, bin2} {
+		cmd := exec.Command(tool("asm"), "-o", out, src)
+		if msg, err := cmd.CombinedOutput(); err != nil {
+			return result{name: name, err: fmt.Errorf("asm failed: %v\n%s", err, msg)}
+		}
+	}
+	b1, err1 := os.ReadFile(bin1)
+	b2, err2 := os.ReadFile(bin2)
+	if err1 != nil || err2 != nil {
+		return result{name: name, err: fmt.Errorf("reading binaries: %v %v", err1, err2)}
+	}
+	if !bytes.Equal(b1, b2) {
+		return result{name: name, err: fmt.Errorf("assembler output is not deterministic")}
+	}
+
+	// Run under func and compare stdout to the golden file.
+	want, err := os.ReadFile(filepath.Join(dir, "expect.txt"))
+	if err != nil {
+		return result{name: name, err: err}
+	}
+	args := []string{"-q"}
+	if *perfFile != "" {
+		args = append(args, "-stats")
+	}
+	// A case with input.txt gets it on the semihosting input device;
+	// the bootstrap cases feed native programs their source this way.
+	if input := filepath.Join(dir, "input.txt"); fileExists(input) {
+		args = append(args, "-in", input)
+	}
+	cmd := exec.Command(tool("func"), append(args, bin1)...)
+	cmd.Stdin = bytes.NewReader(nil)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return result{name: name, err: fmt.Errorf("func failed: %v\n%s", err, stderr.String())}
+	}
+	perf, hasPerf := parseStats(stderr.String())
+	if !bytes.Equal(stdout.Bytes(), want) {
+		return result{
+			name:     name,
+			err:      fmt.Errorf("output mismatch:\n%s", unifiedDiff("expect.txt", want, stdout.Bytes())),
+			dir:      dir,
+			file:     "expect.txt",
+			got:      stdout.Bytes(),
+			mismatch: true,
+		}
+	}
+	return result{name: name, perf: perf, hasPerf: hasPerf}
+}
+
+// compileCase runs the YAPL half of a case: compile prog.yapl twice
+// (the compiler must be deterministic too), then compare the emitted
+// assembly, normalized, against golden.s. On success it returns the
+// assembly file for the rest of the pipeline; on failure it returns
+// "" and the failing result.
+func compileCase(dir, tmp, name string) (string, result) {
+	src := filepath.Join(dir, "prog.yapl")
+	s1 := filepath.Join(tmp, name+".1.s")
+	s2 := filepath.Join(tmp, name+".2.s")
+	for _, out := range []string{s1, s2} {
+		cmd := exec.Command(tool("yapl"), "-o", out, src)
+		if msg, err := cmd.CombinedOutput(); err != nil {
+			return "", result{name: name, err: fmt.Errorf("yapl failed: %v\n%s", err, msg)}
+		}
+	}
+	a1, err1 := os.ReadFile(s1)
+	a2, err2 := os.ReadFile(s2)
+	if err1 != nil || err2 != nil {
+		return "", result{name: name, err: fmt.Errorf("reading assembly: %v %v", err1, err2)}
+	}
+	if !bytes.Equal(a1, a2) {
+		return "", result{name: name, err: fmt.Errorf("compiler output is not deterministic")}
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "golden.s"))
+	if err != nil {
+		return "", result{name: name, err: err}
+	}
+	got := normalizeAsm(a1)
+	if want = normalizeAsm(want); !bytes.Equal(got, want) {
+		return "", result{
+			name:     name,
+			err:      fmt.Errorf("assembly mismatch:\n%s", unifiedDiff("golden.s", want, got)),
+			dir:      dir,
+			file:     "golden.s",
+			got:      got,
+			mismatch: true,
+		}
+	}
+	return s1, result{}
+}
+
+// confirmUpdate asks the human before a golden file is overwritten.
+func confirmUpdate(in *bufio.Scanner, name, file string) bool {
+	fmt.Printf("update %s/%s with actual output? [y/N] ", name, file)
+	if !in.Scan() {
+		return false
+	}
+	answer := strings.TrimSpace(in.Text())
+	return answer == "y" || answer == "Y" || answer == "yes"
+}
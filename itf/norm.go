@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// normalizeAsm reduces assembly to its instruction and directive
+// content for golden comparison: comments go (the compiler's "; line"
+// annotations change with unrelated source edits), trailing space
+// goes, and blank lines go. What remains is exactly what the
+// assembler encodes, so a golden diff is a real codegen change.
+func normalizeAsm(b []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			continue
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,11 @@
+package main
+
+import "testing"
+
+func TestNormalizeAsm(t *testing.T) {
+	in := []byte("; generated\n\n_start:\n    li r6, 0xFFFE   ; stack\n    ; line 2: var x\n    hlt\t\n")
+	want := "_start:\n    li r6, 0xFFFE\n    hlt\n"
+	if got := string(normalizeAsm(in)); got != want {
+		t.Errorf("normalizeAsm = %q, want %q", got, want)
+	}
+}
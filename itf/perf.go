@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Performance regression tracking. With -perf, itf runs each case
+// under func -stats, appends total cycles and cycles-per-second to a
+// history file, and warns when a case's cps drops by more than
+// -regress percent against the most recent recorded run. Warnings
+// never fail the run: wall-clock speed is too noisy to gate on, but
+// a 2x slowdown in the emulator core should not pass unremarked.
+//
+// The history file is plain text, one run per line:
+//
+//	name cycles cps unix-seconds
+
+type perfEntry struct {
+	cycles uint64
+	cps    float64
+}
+
+// loadPerfHistory returns each case's most recent recorded entry.
+func loadPerfHistory(path string) map[string]perfEntry {
+	hist := make(map[string]perfEntry)
+	f, err := os.Open(path)
+	if err != nil {
+		return hist // no history yet
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		cycles, err1 := strconv.ParseUint(fields[1], 10, 64)
+		cps, err2 := strconv.ParseFloat(fields[2], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		hist[fields[0]] = perfEntry{cycles: cycles, cps: cps}
+	}
+	return hist
+}
+
+// appendPerfHistory records one run.
+func appendPerfHistory(path, name string, e perfEntry) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, werr := fmt.Fprintf(f, "%s %d %.0f %d\n", name, e.cycles, e.cps, time.Now().Unix())
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	return werr
+}
+
+// parseStats extracts the func-stats line from func's stderr.
+func parseStats(stderr string) (perfEntry, bool) {
+	for _, line := range strings.Split(stderr, "\n") {
+		if !strings.HasPrefix(line, "func-stats:") {
+			continue
+		}
+		var e perfEntry
+		ok := true
+		for _, kv := range strings.Fields(line)[1:] {
+			k, v, found := strings.Cut(kv, "=")
+			if !found {
+				continue
+			}
+			switch k {
+			case "cycles":
+				if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+					e.cycles = n
+				} else {
+					ok = false
+				}
+			case "cps":
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					e.cps = f
+				} else {
+					ok = false
+				}
+			}
+		}
+		return e, ok
+	}
+	return perfEntry{}, false
+}
+
+// perfWarning compares a fresh entry to the recorded one and returns
+// a warning string, or "" if performance is acceptable.
+func perfWarning(name string, old, new perfEntry, pct int) string {
+	if old.cps <= 0 || new.cps <= 0 {
+		return ""
+	}
+	drop := (old.cps - new.cps) / old.cps * 100
+	if drop > float64(pct) {
+		return fmt.Sprintf("%s: cps %.0f -> %.0f (%.0f%% regression, threshold %d%%)",
+			name, old.cps, new.cps, drop, pct)
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestParseStats(t *testing.T) {
+	stderr := "func: stack underflow: whatever\nfunc-stats: cycles=1234 seconds=0.000100 cps=12340000\n"
+	e, ok := parseStats(stderr)
+	if !ok || e.cycles != 1234 || e.cps != 12340000 {
+		t.Errorf("parseStats = %+v, %v", e, ok)
+	}
+	if _, ok := parseStats("func: halted\n"); ok {
+		t.Error("parsed stats from output without a stats line")
+	}
+}
+
+func TestPerfWarning(t *testing.T) {
+	old := perfEntry{cycles: 100, cps: 1000}
+	if w := perfWarning("c", old, perfEntry{cycles: 100, cps: 900}, 25); w != "" {
+		t.Errorf("10%% drop warned: %s", w)
+	}
+	if w := perfWarning("c", old, perfEntry{cycles: 100, cps: 500}, 25); w == "" {
+		t.Error("50% drop not warned")
+	}
+	// No history means nothing to compare against.
+	if w := perfWarning("c", perfEntry{}, perfEntry{cycles: 1, cps: 1}, 25); w != "" {
+		t.Errorf("warned with no history: %s", w)
+	}
+}
@@ -0,0 +1,99 @@
+// Machine-readable result reports. With -junit or -tap, itf writes
+// JUnit XML or TAP version 13 alongside its human output, so CI
+// systems ingest results through their normal channels instead of
+// scraping the PASS/FAIL lines. A golden file overwritten by -update
+// reports as passing: the human approved the new output.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// reportEntry is one case's outcome as the reports see it.
+type reportEntry struct {
+	name string
+	msg  string // empty means pass
+}
+
+// JUnit XML, the least common denominator of CI result ingestion.
+// Only the fields those systems actually read are emitted.
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name    string        `xml:"name,attr"`
+	Failure *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func junitReport(entries []reportEntry) ([]byte, error) {
+	suite := junitSuite{Name: "itf"}
+	for _, e := range entries {
+		c := junitCase{Name: e.name}
+		if e.msg != "" {
+			suite.Failures++
+			// The first line is the attribute the dashboards show;
+			// the full text (diffs included) goes in the body.
+			first, _, _ := strings.Cut(e.msg, "\n")
+			c.Failure = &junitFailure{Message: first, Body: e.msg}
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, c)
+	}
+	b, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// tapReport renders TAP version 13: a plan line, one ok/not ok line
+// per case, and the failure text as comment lines so strict parsers
+// skip it.
+func tapReport(entries []reportEntry) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(entries))
+	for i, e := range entries {
+		if e.msg == "" {
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, e.name)
+			continue
+		}
+		fmt.Fprintf(&b, "not ok %d - %s\n", i+1, e.name)
+		for _, line := range strings.Split(strings.TrimRight(e.msg, "\n"), "\n") {
+			fmt.Fprintf(&b, "# %s\n", line)
+		}
+	}
+	return []byte(b.String())
+}
+
+// writeReports emits the formats requested by -junit and -tap.
+func writeReports(entries []reportEntry) error {
+	if *junitFile != "" {
+		b, err := junitReport(entries)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*junitFile, b, 0644); err != nil {
+			return err
+		}
+	}
+	if *tapFile != "" {
+		if err := os.WriteFile(*tapFile, tapReport(entries), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
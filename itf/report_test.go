@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var reportFixture = []reportEntry{
+	{name: "hello"},
+	{name: "crash", msg: "output mismatch:\n-want\n+got"},
+}
+
+func TestTapReport(t *testing.T) {
+	got := string(tapReport(reportFixture))
+	for _, want := range []string{
+		"TAP version 13\n1..2\n",
+		"ok 1 - hello\n",
+		"not ok 2 - crash\n",
+		"# output mismatch:\n# -want\n# +got\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("TAP missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestJunitReport(t *testing.T) {
+	b, err := junitReport(reportFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	for _, want := range []string{
+		`<testsuite name="itf" tests="2" failures="1">`,
+		`<testcase name="hello">`,
+		`<failure message="output mismatch:">`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("JUnit missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Package lex is the lexical analyzer for WUT-4 assembly, shared by
+// the assembler and source tooling such as asmfmt. The lexer turns a
+// source file into a flat stream of tokens; all syntactic and
+// semantic judgment (what is a mnemonic, a register, a directive) is
+// left to the consumer.
+//
+// Every token carries the line and column where it starts, so the
+// parser, listing generator, and future editor integrations can point
+// at exact source positions without counting newlines themselves.
+package lex
+
+import (
+	"fmt"
+	"strings"
+)
+
+type TokenKind int
+
+const (
+	TkError TokenKind = iota
+	TkEOF
+	TkNewline
+	TkSymbol // identifiers, mnemonics, and dot-directives
+	TkNumber
+	TkString
+	TkComma
+	TkColon
+	TkMinus
+	TkComment // only when KeepComments is set; Text excludes the ';'
+)
+
+// Name returns the stable external name of a token kind, used by the
+// assembler's -tokens dump and anything else that serializes tokens.
+func (k TokenKind) Name() string {
+	switch k {
+	case TkError:
+		return "error"
+	case TkEOF:
+		return "eof"
+	case TkNewline:
+		return "newline"
+	case TkSymbol:
+		return "symbol"
+	case TkNumber:
+		return "number"
+	case TkString:
+		return "string"
+	case TkComma:
+		return "comma"
+	case TkColon:
+		return "colon"
+	case TkMinus:
+		return "minus"
+	case TkComment:
+		return "comment"
+	}
+	return "unknown"
+}
+
+type Token struct {
+	Kind TokenKind
+	Text string
+	Line int // 1-based source line of the first character
+	Col  int // 1-based column of the first character
+}
+
+func (t Token) String() string {
+	switch t.Kind {
+	case TkEOF:
+		return "end of file"
+	case TkNewline:
+		return "end of line"
+	default:
+		return fmt.Sprintf("%q", t.Text)
+	}
+}
+
+// MaxLookahead is the number of tokens Peek can see ahead.
+const MaxLookahead = 4
+
+// Lexer scans one source file. A small ring buffer of scanned-ahead
+// tokens supports Peek(n), so the parser can look several tokens
+// ahead (label vs mnemonic, future expression and macro syntax)
+// without ungetting.
+type Lexer struct {
+	src  []byte
+	pos  int
+	line int
+	col  int
+
+	// KeepComments makes the lexer emit TkComment tokens instead of
+	// discarding comments; formatters and documentation tools need
+	// them, the assembler does not.
+	KeepComments bool
+
+	ring [MaxLookahead]Token
+	head int // index of the oldest buffered token
+	n    int // number of buffered tokens
+}
+
+func NewLexer(src []byte) *Lexer {
+	return &Lexer{src: src, line: 1, col: 1}
+}
+
+// Peek returns the token n positions ahead without consuming
+// anything; Peek(0) is the token Next would return. n must be less
+// than MaxLookahead.
+func (lx *Lexer) Peek(n int) Token {
+	if n >= MaxLookahead {
+		panic("lexer: lookahead too deep")
+	}
+	for lx.n <= n {
+		lx.ring[(lx.head+lx.n)%MaxLookahead] = lx.scan()
+		lx.n++
+	}
+	return lx.ring[(lx.head+n)%MaxLookahead]
+}
+
+// Next returns the next token and consumes it.
+func (lx *Lexer) Next() Token {
+	if lx.n > 0 {
+		t := lx.ring[lx.head]
+		lx.head = (lx.head + 1) % MaxLookahead
+		lx.n--
+		return t
+	}
+	return lx.scan()
+}
+
+// Line and Col report the current scan position, 1-based.
+func (lx *Lexer) Line() int { return lx.line }
+func (lx *Lexer) Col() int  { return lx.col }
+
+// advance consumes one byte, maintaining the position counters.
+func (lx *Lexer) advance() {
+	if lx.src[lx.pos] == '\n' {
+		lx.line++
+		lx.col = 1
+	} else {
+		lx.col++
+	}
+	lx.pos++
+}
+
+// tok stamps a token with the given start position.
+func tok(kind TokenKind, text string, line, col int) Token {
+	return Token{Kind: kind, Text: text, Line: line, Col: col}
+}
+
+// scan produces the next token from the source. Comments (from ';'
+// to end of line) are skipped; the newline that ends them is
+// returned.
+func (lx *Lexer) scan() Token {
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		line, col := lx.line, lx.col
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			lx.advance()
+		case c == ';':
+			start := lx.pos
+			for lx.pos < len(lx.src) && lx.src[lx.pos] != '\n' {
+				lx.advance()
+			}
+			if lx.KeepComments {
+				return tok(TkComment, string(lx.src[start+1:lx.pos]), line, col)
+			}
+		case c == '\n':
+			lx.advance()
+			return tok(TkNewline, "\n", line, col)
+		case c == ',':
+			lx.advance()
+			return tok(TkComma, ",", line, col)
+		case c == ':':
+			lx.advance()
+			return tok(TkColon, ":", line, col)
+		case c == '-':
+			lx.advance()
+			return tok(TkMinus, "-", line, col)
+		case c == '"':
+			return lx.scanString(line, col)
+		case isDigit(c):
+			return lx.scanNumber(line, col)
+		case isSymStart(c):
+			return lx.scanSymbol(line, col)
+		default:
+			lx.advance()
+			return tok(TkError, fmt.Sprintf("unexpected character %q", c), line, col)
+		}
+	}
+	return tok(TkEOF, "", lx.line, lx.col)
+}
+
+func (lx *Lexer) scanSymbol(line, col int) Token {
+	start := lx.pos
+	for lx.pos < len(lx.src) && isSymCont(lx.src[lx.pos]) {
+		lx.advance()
+	}
+	return tok(TkSymbol, string(lx.src[start:lx.pos]), line, col)
+}
+
+func (lx *Lexer) scanNumber(line, col int) Token {
+	start := lx.pos
+	for lx.pos < len(lx.src) && isNumCont(lx.src[lx.pos]) {
+		lx.advance()
+	}
+	return tok(TkNumber, string(lx.src[start:lx.pos]), line, col)
+}
+
+func (lx *Lexer) scanString(line, col int) Token {
+	lx.advance() // opening quote
+	var b strings.Builder
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		if c == '\n' {
+			return tok(TkError, "newline in string", line, col)
+		}
+		lx.advance()
+		switch c {
+		case '"':
+			return tok(TkString, b.String(), line, col)
+		case '\\':
+			if lx.pos >= len(lx.src) {
+				return tok(TkError, "unterminated string", line, col)
+			}
+			e := lx.src[lx.pos]
+			lx.advance()
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '0':
+				b.WriteByte(0)
+			case '\\', '"':
+				b.WriteByte(e)
+			default:
+				return tok(TkError, fmt.Sprintf("bad escape \\%c", e), line, col)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return tok(TkError, "unterminated string", line, col)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isSymStart(c byte) bool {
+	return c == '.' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSymCont(c byte) bool { return isSymStart(c) || isDigit(c) }
+
+// Numbers keep hex digits and the 0x prefix; strconv sorts it out.
+func isNumCont(c byte) bool {
+	return isDigit(c) || c == 'x' || c == 'X' ||
+		c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,63 @@
+package lex
+
+import "testing"
+
+// Tokens must carry the 1-based line and column of their first
+// character, across comments, strings, and blank lines.
+// Peek must see ahead without consuming and interleave correctly
+// with Next.
+func TestPeekLookahead(t *testing.T) {
+	lx := NewLexer([]byte("a b c d\n"))
+	if g := lx.Peek(2); g.Text != "c" {
+		t.Fatalf("Peek(2) = %v, want c", g)
+	}
+	if g := lx.Peek(0); g.Text != "a" {
+		t.Fatalf("Peek(0) = %v, want a", g)
+	}
+	for _, want := range []string{"a", "b"} {
+		if g := lx.Next(); g.Text != want {
+			t.Fatalf("Next = %v, want %s", g, want)
+		}
+	}
+	if g := lx.Peek(1); g.Text != "d" {
+		t.Fatalf("Peek(1) after two Next = %v, want d", g)
+	}
+	if g := lx.Peek(2); g.Kind != TkNewline {
+		t.Fatalf("Peek(2) = %v, want newline", g)
+	}
+	if g := lx.Next(); g.Text != "c" {
+		t.Fatalf("Next = %v, want c", g)
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	src := "  adi r1, r0, 1 ; comment\n\nlabel: .string \"hi\"\n"
+	lx := NewLexer([]byte(src))
+	want := []struct {
+		kind      TokenKind
+		text      string
+		line, col int
+	}{
+		{TkSymbol, "adi", 1, 3},
+		{TkSymbol, "r1", 1, 7},
+		{TkComma, ",", 1, 9},
+		{TkSymbol, "r0", 1, 11},
+		{TkComma, ",", 1, 13},
+		{TkNumber, "1", 1, 15},
+		{TkNewline, "\n", 1, 26},
+		{TkNewline, "\n", 2, 1},
+		{TkSymbol, "label", 3, 1},
+		{TkColon, ":", 3, 6},
+		{TkSymbol, ".string", 3, 8},
+		{TkString, "hi", 3, 16},
+		{TkNewline, "\n", 3, 20},
+		{TkEOF, "", 4, 1},
+	}
+	for i, w := range want {
+		g := lx.Next()
+		if g.Kind != w.kind || g.Text != w.text || g.Line != w.line || g.Col != w.col {
+			t.Errorf("token %d: got %v kind=%d at %d:%d, want %q kind=%d at %d:%d",
+				i, g, g.Kind, g.Line, g.Col, w.text, w.kind, w.line, w.col)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+; lock.s - spinlocks built on the tas instruction.
+;
+; A lock is one word of dmem: 0 free, nonzero held. Calling
+; convention as in math.s: link in r1, argument (lock address) in r2,
+; r3 clobbered.
+
+.code                    ; safe to concatenate after a .data section
+
+; lock_acquire: spin until the lock at address r2 is ours.
+lock_acquire:
+    tas r3, r2
+    beq r3, r0, lock_acquire_done
+    jmp lock_acquire
+lock_acquire_done:
+    rtl
+
+; lock_release: free the lock at address r2. The plain store is
+; sufficient: tas in other threads observes it atomically.
+lock_release:
+    stw r0, r2, 0
+    rtl
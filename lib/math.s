@@ -0,0 +1,112 @@
+; math.s - 16-bit multiply and divide for WUT-4 programs.
+;
+; Calling convention: r1 is the link register (call with jlr r1, rX, 0
+; or let a far-call sequence load the address), arguments in r2/r3,
+; results in r2 (and r4 for the division remainder). r5 and r7 are
+; clobbered. The routines probe the math unit at its presence port and
+; fall back to pure software when it is not attached (func -nomath).
+;
+; Paste or concatenate this file with your program until the librarian
+; and linker exist; labels here are prefixed to avoid collisions.
+
+.set mathpresent, 8      ; port numbers, matching func's math unit
+.set mathopa, 9
+.set mathopb, 10
+.set mathprodlo, 11
+.set mathquot, 13
+.set mathrem, 14
+.set mathmagic, 0x4D44   ; value read from the presence port
+
+.code                    ; safe to concatenate after a .data section
+
+; mul16: r2 = low word of r2 * r3.
+mul16:
+    li r5, mathpresent
+    lio r7, r5
+    li r5, mathmagic
+    bne r7, r5, mul16_soft
+    li r5, mathopa
+    sio r2, r5
+    li r5, mathopb
+    sio r3, r5
+    li r5, mathprodlo
+    lio r2, r5
+    rtl
+
+; Software fallback: shift-and-add. Clobbers r3 as well.
+mul16_soft:
+    adi r4, r0, 0            ; accumulator
+mul16_loop:
+    beq r3, r0, mul16_done
+    adi r5, r0, 1
+    and r5, r3
+    beq r5, r0, mul16_skip
+    add r4, r2
+mul16_skip:
+    lsl r2
+    lsr r3
+    jmp mul16_loop
+mul16_done:
+    mov r2, r4
+    rtl
+
+; udiv16: unsigned divide, r2 = r2 / r3 and r4 = r2 % r3.
+; Division by zero yields quotient 0xFFFF, remainder r2 (matching the
+; math unit).
+udiv16:
+    li r5, mathpresent
+    lio r7, r5
+    li r5, mathmagic
+    bne r7, r5, udiv16_soft
+    li r5, mathopa
+    sio r2, r5
+    li r5, mathopb
+    sio r3, r5
+    li r5, mathquot
+    lio r4, r5               ; fetch quotient first, r2 is an operand
+    li r5, mathrem
+    lio r7, r5
+    mov r2, r4
+    mov r4, r7
+    rtl
+
+; Software fallback: 16 steps of restoring division. The loop counter
+; lives in memory because every register is spoken for.
+udiv16_soft:
+    bne r3, r0, udiv16_go
+    mov r4, r2               ; divide by zero: match the unit
+    li r2, 0xFFFF
+    rtl
+udiv16_go:
+    adi r4, r0, 0            ; remainder
+    li r5, 16
+    li r7, udiv16_count
+    stw r5, r7, 0
+udiv16_loop:
+    mov r5, r2               ; bit = top bit of the dividend
+    slt r5, r0
+    lsl r2
+    lsl r4
+    or r4, r5                ; rem = rem << 1 | bit
+    ; unsigned rem < divisor is signed-less xor the two sign bits
+    mov r5, r4
+    slt r5, r3
+    mov r7, r4
+    slt r7, r0
+    xor r5, r7
+    mov r7, r3
+    slt r7, r0
+    xor r5, r7
+    bne r5, r0, udiv16_skip
+    sub r4, r3               ; rem -= divisor
+    adi r2, r2, 1            ; set the quotient bit just shifted in
+udiv16_skip:
+    li r7, udiv16_count
+    ldw r5, r7, 0
+    adi r5, r5, -1
+    stw r5, r7, 0
+    bne r5, r0, udiv16_loop
+    rtl
+
+.data
+udiv16_count: .word 0
@@ -0,0 +1,91 @@
+// Package mem is the data-memory model shared by the two simulators.
+// In compare mode func and sim must observe identical store and load
+// sequences, or every divergence drowns in modeling noise. Both sides
+// put their accesses through this package — func by feeding a Trace
+// from its memory-access hook, sim by backing its RAM component with
+// a Memory — so the sequences are recorded the same way and a
+// difference between them is attributable to logic, not to how each
+// simulator happens to model memory.
+package mem
+
+// An Access is one load or store as the memory saw it: a store's
+// value is the data written, a load's the data returned.
+type Access struct {
+	Addr  uint16
+	Value uint16
+	Size  int // bytes, 1 or 2
+	Write bool
+}
+
+// A Trace is the sequenced record of every access.
+type Trace struct {
+	accs []Access
+}
+
+func (t *Trace) Record(a Access) { t.accs = append(t.accs, a) }
+func (t *Trace) Len() int        { return len(t.accs) }
+func (t *Trace) At(i int) Access { return t.accs[i] }
+
+// FirstDiff returns the index of the first access where the two
+// traces differ; a shorter trace differs from a longer one at its
+// end. It returns -1 when the traces are identical.
+func FirstDiff(a, b *Trace) int {
+	n := len(a.accs)
+	if len(b.accs) < n {
+		n = len(b.accs)
+	}
+	for i := 0; i < n; i++ {
+		if a.accs[i] != b.accs[i] {
+			return i
+		}
+	}
+	if len(a.accs) != len(b.accs) {
+		return n
+	}
+	return -1
+}
+
+// Memory is a 64K byte store that records its accesses. Words are
+// little-endian, matching the architecture. A nil trace disables
+// recording.
+type Memory struct {
+	b     [1 << 16]byte
+	trace *Trace
+}
+
+func New(t *Trace) *Memory { return &Memory{trace: t} }
+
+// Bytes exposes the backing store for loaders and dumpers; accesses
+// through it are not recorded.
+func (m *Memory) Bytes() []byte { return m.b[:] }
+
+func (m *Memory) LoadByte(addr uint16) uint16 {
+	v := uint16(m.b[addr])
+	m.record(Access{Addr: addr, Value: v, Size: 1})
+	return v
+}
+
+func (m *Memory) StoreByte(addr uint16, v uint16) {
+	m.b[addr] = byte(v)
+	m.record(Access{Addr: addr, Value: v & 0xFF, Size: 1, Write: true})
+}
+
+// LoadWord reads the aligned word at addr; callers check alignment,
+// which is an architectural fault, before getting here.
+func (m *Memory) LoadWord(addr uint16) uint16 {
+	v := uint16(m.b[addr]) | uint16(m.b[addr+1])<<8
+	m.record(Access{Addr: addr, Value: v, Size: 2})
+	return v
+}
+
+func (m *Memory) StoreWord(addr uint16, v uint16) {
+	m.b[addr] = byte(v)
+	m.b[addr+1] = byte(v >> 8)
+	m.record(Access{Addr: addr, Value: v, Size: 2, Write: true})
+}
+
+func (m *Memory) record(a Access) {
+	if m.trace != nil {
+		m.trace.Record(a)
+	}
+}
@@ -0,0 +1,47 @@
+package mem
+
+import "testing"
+
+// The trace must record accesses in arrival order with the values
+// the memory actually moved, words little-endian.
+func TestTraceSequencing(t *testing.T) {
+	tr := &Trace{}
+	m := New(tr)
+	m.StoreWord(0x10, 0x1234)
+	if got := m.LoadByte(0x10); got != 0x34 {
+		t.Errorf("low byte = 0x%02X, want 0x34", got)
+	}
+	if got := m.LoadWord(0x10); got != 0x1234 {
+		t.Errorf("word = 0x%04X, want 0x1234", got)
+	}
+	want := []Access{
+		{Addr: 0x10, Value: 0x1234, Size: 2, Write: true},
+		{Addr: 0x10, Value: 0x34, Size: 1},
+		{Addr: 0x10, Value: 0x1234, Size: 2},
+	}
+	if tr.Len() != len(want) {
+		t.Fatalf("trace has %d accesses, want %d", tr.Len(), len(want))
+	}
+	for i, w := range want {
+		if tr.At(i) != w {
+			t.Errorf("access %d = %+v, want %+v", i, tr.At(i), w)
+		}
+	}
+}
+
+func TestFirstDiff(t *testing.T) {
+	a, b := &Trace{}, &Trace{}
+	a.Record(Access{Addr: 1, Value: 2, Size: 2, Write: true})
+	b.Record(Access{Addr: 1, Value: 2, Size: 2, Write: true})
+	if d := FirstDiff(a, b); d != -1 {
+		t.Errorf("identical traces differ at %d", d)
+	}
+	b.Record(Access{Addr: 3, Value: 4, Size: 1})
+	if d := FirstDiff(a, b); d != 1 {
+		t.Errorf("length difference at %d, want 1", d)
+	}
+	a.Record(Access{Addr: 3, Value: 5, Size: 1})
+	if d := FirstDiff(a, b); d != 1 {
+		t.Errorf("value difference at %d, want 1", d)
+	}
+}
@@ -0,0 +1,86 @@
+// The WUT-4 archive format, a minimal .a equivalent for bundling the
+// runtime library. An archive is a flat bag of named members (object
+// files, usually); the yar tool creates and unpacks them, and link
+// work selects members by need rather than loading the whole bag.
+//
+//	offset  size  field
+//	0       4     magic "WAR1"
+//	4       2     member count (little-endian)
+//	6       ...   members: name length (2), name bytes,
+//	              body length (4), body bytes
+package obj
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/gmofishsauce/y4/wire"
+)
+
+var ArMagic = [4]byte{'W', 'A', 'R', '1'}
+
+// Member is one named file inside an archive.
+type Member struct {
+	Name string
+	Body []byte
+}
+
+// IsArchive reports whether the bytes begin with the archive magic.
+func IsArchive(b []byte) bool {
+	return len(b) >= 6 && bytes.Equal(b[:4], ArMagic[:])
+}
+
+// WriteArchive serializes the members in order.
+func WriteArchive(path string, members []Member) error {
+	if len(members) > 0xFFFF {
+		return fmt.Errorf("too many members: %d", len(members))
+	}
+	var b bytes.Buffer
+	b.Write(ArMagic[:])
+	wire.WriteWord(&b, uint16(len(members)))
+	for _, m := range members {
+		if len(m.Name) > 0xFFFF {
+			return fmt.Errorf("member name too long: %q", m.Name)
+		}
+		wire.WriteWord(&b, uint16(len(m.Name)))
+		b.WriteString(m.Name)
+		wire.WriteLong(&b, uint32(len(m.Body)))
+		b.Write(m.Body)
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+// ReadArchive loads every member of an archive.
+func ReadArchive(path string) ([]Member, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !IsArchive(b) {
+		return nil, fmt.Errorf("%s: not a WUT-4 archive", path)
+	}
+	count := int(wire.Word(b[4:]))
+	members := make([]Member, 0, count)
+	off := 6
+	for i := 0; i < count; i++ {
+		if off+2 > len(b) {
+			return nil, fmt.Errorf("%s: truncated member header", path)
+		}
+		nameLen := int(wire.Word(b[off:]))
+		off += 2
+		if off+nameLen+4 > len(b) {
+			return nil, fmt.Errorf("%s: truncated member name", path)
+		}
+		name := string(b[off : off+nameLen])
+		off += nameLen
+		bodyLen := int(wire.Long(b[off:]))
+		off += 4
+		if off+bodyLen > len(b) {
+			return nil, fmt.Errorf("%s: truncated member %q", path, name)
+		}
+		members = append(members, Member{Name: name, Body: b[off : off+bodyLen]})
+		off += bodyLen
+	}
+	return members, nil
+}
@@ -0,0 +1,147 @@
+// ELF as an alternate container for WUT-4 binaries. The WUT4 format
+// stays the native one; ELF exists so standard tools (readelf, nm,
+// objdump -s) can inspect our binaries and so later linker work
+// builds on a container with room to grow. The writer emits a minimal
+// 32-bit little-endian executable with .text (code words), .data,
+// and an optional .symtab/.strtab; the reader accepts exactly that
+// shape plus anything else that keeps those section names.
+
+package obj
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"os"
+
+	"github.com/gmofishsauce/y4/wire"
+)
+
+// ElfMachine is the e_machine value for WUT-4. There is no official
+// assignment; 0x5754 ("WT") sits far from the allocated range.
+const ElfMachine = 0x5754
+
+// Sym is one symbol for the ELF symbol table.
+type Sym struct {
+	Name  string
+	Value uint16
+}
+
+// IsELF reports whether the bytes begin with the ELF magic.
+func IsELF(b []byte) bool {
+	return len(b) >= 4 && b[0] == 0x7F && b[1] == 'E' && b[2] == 'L' && b[3] == 'F'
+}
+
+// WriteELF serializes the image as a minimal ELF executable with the
+// given symbols (nil for none).
+func WriteELF(path string, im *Image, syms []Sym) error {
+	code := make([]byte, 2*len(im.Code))
+	for i, w := range im.Code {
+		wire.PutWord(code[2*i:], w)
+	}
+
+	// Section name and symbol name string tables.
+	shstrtab := []byte("\x00.text\x00.data\x00.symtab\x00.strtab\x00.shstrtab\x00")
+	nameOff := map[string]uint32{
+		".text": 1, ".data": 7, ".symtab": 13, ".strtab": 21, ".shstrtab": 29,
+	}
+	strtab := []byte{0}
+	symtab := make([]byte, 16) // entry 0 is the null symbol
+	for _, s := range syms {
+		var e [16]byte
+		wire.PutLong(e[0:], uint32(len(strtab)))
+		wire.PutLong(e[4:], uint32(s.Value))
+		e[12] = byte(elf.STB_GLOBAL)<<4 | byte(elf.STT_NOTYPE)
+		wire.PutWord(e[14:], uint16(elf.SHN_ABS))
+		symtab = append(symtab, e[:]...)
+		strtab = append(strtab, s.Name...)
+		strtab = append(strtab, 0)
+	}
+
+	// Layout: ELF header, section bodies, then the section headers.
+	const ehSize, shSize = 52, 40
+	type sect struct {
+		name  string
+		typ   elf.SectionType
+		body  []byte
+		link  uint32
+		entsz uint32
+	}
+	sects := []sect{
+		{"", elf.SHT_NULL, nil, 0, 0},
+		{".text", elf.SHT_PROGBITS, code, 0, 0},
+		{".data", elf.SHT_PROGBITS, im.Data, 0, 0},
+		{".symtab", elf.SHT_SYMTAB, symtab, 4, 16}, // link = .strtab index
+		{".strtab", elf.SHT_STRTAB, strtab, 0, 0},
+		{".shstrtab", elf.SHT_STRTAB, shstrtab, 0, 0},
+	}
+
+	var b bytes.Buffer
+	eh := make([]byte, ehSize)
+	copy(eh, []byte{0x7F, 'E', 'L', 'F', byte(elf.ELFCLASS32), byte(elf.ELFDATA2LSB), 1})
+	wire.PutWord(eh[16:], uint16(elf.ET_EXEC))
+	wire.PutWord(eh[18:], ElfMachine)
+	wire.PutLong(eh[20:], 1) // e_version
+	wire.PutLong(eh[24:], uint32(im.Entry))
+	// e_shoff filled below; no program headers.
+	wire.PutWord(eh[40:], ehSize)
+	wire.PutWord(eh[46:], shSize)
+	wire.PutWord(eh[48:], uint16(len(sects)))
+	wire.PutWord(eh[50:], uint16(len(sects)-1)) // .shstrtab
+
+	off := uint32(ehSize)
+	offs := make([]uint32, len(sects))
+	for i, s := range sects {
+		offs[i] = off
+		off += uint32(len(s.body))
+	}
+	wire.PutLong(eh[32:], off) // e_shoff
+	b.Write(eh)
+	for _, s := range sects {
+		b.Write(s.body)
+	}
+	for i, s := range sects {
+		sh := make([]byte, shSize)
+		wire.PutLong(sh[0:], nameOff[s.name])
+		wire.PutLong(sh[4:], uint32(s.typ))
+		wire.PutLong(sh[16:], offs[i])
+		wire.PutLong(sh[20:], uint32(len(s.body)))
+		wire.PutLong(sh[24:], s.link)
+		wire.PutLong(sh[36:], s.entsz)
+		b.Write(sh)
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+// ReadELF loads a WUT-4 ELF file into an Image.
+func ReadELF(path string) (*Image, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	defer f.Close()
+	if f.Machine != ElfMachine {
+		return nil, fmt.Errorf("%s: ELF machine 0x%X is not WUT-4 (0x%X)",
+			path, uint16(f.Machine), ElfMachine)
+	}
+	im := &Image{Entry: uint16(f.Entry)}
+	if s := f.Section(".text"); s != nil {
+		b, err := s.Data()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if len(b)%2 != 0 {
+			return nil, fmt.Errorf("%s: odd .text length %d", path, len(b))
+		}
+		im.Code = make([]uint16, len(b)/2)
+		for i := range im.Code {
+			im.Code[i] = wire.Word(b[2*i:])
+		}
+	}
+	if s := f.Section(".data"); s != nil {
+		if im.Data, err = s.Data(); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return im, nil
+}
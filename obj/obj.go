@@ -0,0 +1,138 @@
+// Package obj defines the WUT-4 binary format produced by the
+// assembler and consumed by the emulator and disassembler. The format
+// is deliberately small: a fixed header followed by the code segment
+// (little-endian instruction words, loaded into imem) and the data
+// segment (bytes, loaded into dmem).
+//
+//	offset  size  field
+//	0       4     magic "WUT4"
+//	4       2     format version (little-endian)
+//	6       2     code length in words
+//	8       2     data length in bytes
+//	10      2     entry point (imem word address)
+//	12      2     ISA revision the code was assembled for
+//	14      4     CRC32 (IEEE) of the code segment bytes
+//	18      4     CRC32 (IEEE) of the data segment bytes
+//	22      ...   code words, then data bytes
+//
+// The section checksums are verified on every load, so a truncated
+// or corrupted binary fails with a clear message instead of
+// producing baffling simulation behavior.
+package obj
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"github.com/gmofishsauce/y4/wire"
+)
+
+var Magic = [4]byte{'W', 'U', 'T', '4'}
+
+const Version = 3 // 2 added section checksums, 3 the ISA revision
+
+const HeaderBytes = 22
+
+// Image is an in-memory WUT-4 binary.
+type Image struct {
+	Code  []uint16
+	Data  []byte
+	Entry uint16
+	// IsaRev is the ISA revision the code targets; the emulator
+	// refuses images newer than the revision it is running. Zero
+	// (from older producers) is treated as "any".
+	IsaRev uint16
+}
+
+// Write serializes the image in WUT4 format.
+func (im *Image) Write(path string) error {
+	if len(im.Code) > 0xFFFF {
+		return fmt.Errorf("code segment too large: %d words", len(im.Code))
+	}
+	if len(im.Data) > 0xFFFF {
+		return fmt.Errorf("data segment too large: %d bytes", len(im.Data))
+	}
+	code := make([]byte, 2*len(im.Code))
+	for i, w := range im.Code {
+		wire.PutWord(code[2*i:], w)
+	}
+	var b bytes.Buffer
+	b.Write(Magic[:])
+	wire.WriteWord(&b, Version)
+	wire.WriteWord(&b, uint16(len(im.Code)))
+	wire.WriteWord(&b, uint16(len(im.Data)))
+	wire.WriteWord(&b, im.Entry)
+	wire.WriteWord(&b, im.IsaRev)
+	wire.WriteLong(&b, crc32.ChecksumIEEE(code))
+	wire.WriteLong(&b, crc32.ChecksumIEEE(im.Data))
+	b.Write(code)
+	b.Write(im.Data)
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+// Read loads a WUT4 format file. It returns an error for files that
+// do not begin with the magic number; callers that also accept raw
+// images should check IsObject first.
+func Read(path string) (*Image, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadBytes(b, path)
+}
+
+// ReadBytes parses a WUT4 format image already in memory — one that
+// was embedded or received rather than read from a file. The name is
+// used only in error messages.
+func ReadBytes(b []byte, name string) (*Image, error) {
+	if !IsObject(b) {
+		return nil, fmt.Errorf("%s: not a WUT4 binary", name)
+	}
+	if v := wire.Word(b[4:]); v != Version {
+		return nil, fmt.Errorf("%s: unsupported format version %d", name, v)
+	}
+	nCode := int(wire.Word(b[6:]))
+	nData := int(wire.Word(b[8:]))
+	if len(b) < HeaderBytes+2*nCode+nData {
+		return nil, fmt.Errorf("%s: truncated (header claims %d code words, %d data bytes)",
+			name, nCode, nData)
+	}
+	stored, computed := SectionCRCs(b)
+	for i, seg := range []string{"code", "data"} {
+		if stored[i] != computed[i] {
+			return nil, fmt.Errorf("%s: %s segment CRC mismatch (header 0x%08X, computed 0x%08X): corrupted binary",
+				name, seg, stored[i], computed[i])
+		}
+	}
+	im := &Image{
+		Code:  make([]uint16, nCode),
+		Data:  make([]byte, nData),
+		Entry: wire.Word(b[10:]),
+	}
+	im.IsaRev = wire.Word(b[12:])
+	for i := 0; i < nCode; i++ {
+		im.Code[i] = wire.Word(b[HeaderBytes+2*i:])
+	}
+	copy(im.Data, b[HeaderBytes+2*nCode:])
+	return im, nil
+}
+
+// IsObject reports whether the bytes begin with the WUT4 magic.
+func IsObject(b []byte) bool {
+	return len(b) >= HeaderBytes && bytes.Equal(b[:4], Magic[:])
+}
+
+// SectionCRCs returns the code and data segment checksums stored in
+// the header and those computed from the payload. The caller must
+// have checked IsObject and the segment lengths against len(b).
+func SectionCRCs(b []byte) (stored, computed [2]uint32) {
+	nCode := int(wire.Word(b[6:]))
+	nData := int(wire.Word(b[8:]))
+	stored[0] = wire.Long(b[14:])
+	stored[1] = wire.Long(b[18:])
+	computed[0] = crc32.ChecksumIEEE(b[HeaderBytes : HeaderBytes+2*nCode])
+	computed[1] = crc32.ChecksumIEEE(b[HeaderBytes+2*nCode : HeaderBytes+2*nCode+nData])
+	return stored, computed
+}
@@ -0,0 +1,48 @@
+// The asm -syms dump is a cross-tool interface like the binary
+// formats: one "name value" pair per line, values decimal, in
+// definition order. Every tool that accepts a -syms flag parses it
+// here, so a format change happens once.
+
+package obj
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadSyms parses an asm -syms dump, preserving the file's order.
+func ReadSyms(path string) ([]Sym, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var syms []Sym
+	for _, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(f[len(f)-1], 10, 16)
+		if len(f) != 2 || err != nil {
+			return nil, fmt.Errorf("%s: bad symbol line %q", path, line)
+		}
+		syms = append(syms, Sym{Name: f[0], Value: uint16(v)})
+	}
+	return syms, nil
+}
+
+// ReadSymsMap is ReadSyms for tools that look symbols up by name and
+// do not care about dump order.
+func ReadSymsMap(path string) (map[string]uint16, error) {
+	list, err := ReadSyms(path)
+	if err != nil {
+		return nil, err
+	}
+	syms := make(map[string]uint16, len(list))
+	for _, s := range list {
+		syms[s.Name] = s.Value
+	}
+	return syms, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Assert is a formal-lite invariant check wired into the design like
+// any other component. Each cycle (once the warmup period is over)
+// it evaluates a user-supplied predicate over other components and
+// records any violation with the cycle it happened on; Check fails
+// if anything was recorded. With Fatal set, the first violation is
+// printed and the simulation exits immediately, which is the right
+// behavior for invariants that make everything downstream garbage.
+type Assert struct {
+	name  string
+	after uint32       // first cycle the predicate applies
+	pred  func() error // nil result means the invariant holds
+	deps  []Component  // components the predicate reads
+	cycle uint32
+	fails []string
+
+	Fatal bool
+}
+
+// maxAssertFails bounds recording so a broken invariant in a long
+// run does not itself eat all memory.
+const maxAssertFails = 10
+
+// NewAssert builds an assertion that applies from cycle `after` on.
+func NewAssert(name string, after uint32, pred func() error) *Assert {
+	return &Assert{name: name, after: after, pred: pred}
+}
+
+// NewAssertDefined asserts that every given component's output is
+// defined, e.g. "the PC is never undefined after cycle 2".
+func NewAssertDefined(name string, after uint32, comps ...Component) *Assert {
+	a := NewAssert(name, after, func() error {
+		for _, c := range comps {
+			if c.Evaluate().Undef {
+				return fmt.Errorf("%s is undefined", c.Name())
+			}
+		}
+		return nil
+	})
+	a.deps = comps
+	return a
+}
+
+// NewAssertOneHot asserts that exactly one of the given single-bit
+// signals is high, e.g. a set of write enables.
+func NewAssertOneHot(name string, after uint32, comps ...Component) *Assert {
+	a := NewAssert(name, after, func() error {
+		hot := 0
+		for _, c := range comps {
+			v := c.Evaluate()
+			if v.Undef {
+				return fmt.Errorf("%s is undefined", c.Name())
+			}
+			if v.Value&1 != 0 {
+				hot++
+			}
+		}
+		if hot != 1 {
+			return fmt.Errorf("%d signals hot, want exactly 1", hot)
+		}
+		return nil
+	})
+	a.deps = comps
+	return a
+}
+
+func (a *Assert) Name() string { return a.name }
+
+// Inputs reports the components the predicate reads, so assertions
+// evaluate after their subjects; Clocked keeps them from ever being
+// skipped (the warmup counter is state).
+func (a *Assert) Inputs() []Component { return a.deps }
+func (a *Assert) Clocked()            {}
+
+func (a *Assert) Prepare() {}
+
+// Evaluate runs the predicate. The output is a single bit: 1 while
+// the invariant holds, 0 on the cycle of a violation.
+func (a *Assert) Evaluate() Bits {
+	if a.cycle < a.after {
+		return MakeBits(1, 1)
+	}
+	err := a.pred()
+	if err == nil {
+		return MakeBits(1, 1)
+	}
+	if a.Fatal {
+		fmt.Fprintf(os.Stderr, "sim: assertion %s failed at cycle %d: %v\n",
+			a.name, a.cycle, err)
+		os.Exit(2)
+	}
+	if len(a.fails) < maxAssertFails {
+		a.fails = append(a.fails, fmt.Sprintf("cycle %d: %v", a.cycle, err))
+	}
+	return MakeBits(1, 0)
+}
+
+func (a *Assert) PositiveEdge() {
+	a.cycle++
+}
+
+func (a *Assert) Check() error {
+	if len(a.fails) == 0 {
+		return nil
+	}
+	return fmt.Errorf("assertion failed %d time(s), first: %s",
+		len(a.fails), a.fails[0])
+}
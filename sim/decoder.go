@@ -0,0 +1,80 @@
+package main
+
+import "github.com/gmofishsauce/y4/isa"
+
+// Decoder is the instruction decoder as a structural component. Its
+// truth table is not hand-wired: it is generated at construction by
+// running isa.Decode over every possible instruction word, so the
+// control signals here match the functional simulator's decode by
+// construction and cannot drift from the shared ISA definition.
+//
+// The output is the index of the decoded instruction in isa.Defs,
+// wide enough to hold every allocated instruction; words that decode
+// to nothing produce an undefined output, which the illegal-
+// instruction logic downstream is expected to trap on.
+type Decoder struct {
+	name  string
+	in    Component
+	table []int16 // instruction word -> index into isa.Defs, -1 illegal
+	width int
+	out   Bits
+}
+
+// NewDecoder builds the decode table from the ISA definition. in is
+// the component driving the 16-bit instruction word.
+func NewDecoder(name string, in Component) *Decoder {
+	d := &Decoder{name: name, in: in, table: make([]int16, 1<<16)}
+	for d.width = 1; 1<<d.width < len(isa.Defs); d.width++ {
+	}
+	for w := 0; w < 1<<16; w++ {
+		d.table[w] = defIndex(isa.Decode(uint16(w)))
+	}
+	return d
+}
+
+// defIndex maps a decode result back to its position in isa.Defs,
+// or -1 for an illegal encoding.
+func defIndex(def *isa.Def) int16 {
+	if def == nil {
+		return -1
+	}
+	for i := range isa.Defs {
+		if &isa.Defs[i] == def {
+			return int16(i)
+		}
+	}
+	return -1
+}
+
+func (d *Decoder) Name() string        { return d.name }
+func (d *Decoder) Inputs() []Component { return []Component{d.in} }
+func (d *Decoder) Prepare()            {}
+
+func (d *Decoder) Evaluate() Bits {
+	w := d.in.Evaluate()
+	if w.Undef {
+		d.out = UndefBits(d.width)
+		return d.out
+	}
+	ix := d.table[w.Value]
+	if ix < 0 {
+		d.out = UndefBits(d.width)
+		return d.out
+	}
+	d.out = MakeBits(d.width, uint16(ix))
+	return d.out
+}
+
+func (d *Decoder) PositiveEdge() {}
+func (d *Decoder) Check() error  { return nil }
+
+// Decoded returns the instruction definition selected by the last
+// Evaluate, or nil if the word was illegal or undefined. Probing and
+// assertion components use this to reason about decode results
+// symbolically rather than by index.
+func (d *Decoder) Decoded() *isa.Def {
+	if d.out.Undef {
+		return nil
+	}
+	return &isa.Defs[d.out.Value]
+}
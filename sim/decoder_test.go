@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+)
+
+// The decoder's table is generated from isa.Decode, so this test
+// only needs to spot-check the plumbing: a legal word selects the
+// right Def, an illegal one goes undefined.
+func TestDecoderMatchesIsa(t *testing.T) {
+	words := []uint16{
+		isa.EncodeMem(isa.OpLdw, 1, 2, 3),
+		isa.EncodeXop(isa.XopAdd, 1, 2),
+		isa.EncodeVop(isa.VopHlt),
+	}
+	for _, w := range words {
+		d := NewDecoder("dec", NewConstant("ir", 16, w))
+		d.Evaluate()
+		want := isa.Decode(w)
+		if got := d.Decoded(); got != want {
+			t.Errorf("word 0x%04X: decoded %v, want %v", w, got, want)
+		}
+	}
+}
+
+func TestDecoderIllegal(t *testing.T) {
+	// VOP 6 is unallocated; the word must decode to undefined.
+	w := isa.EncodeVop(6)
+	if isa.Decode(w) != nil {
+		t.Fatalf("test premise broken: 0x%04X is now legal", w)
+	}
+	d := NewDecoder("dec", NewConstant("ir", 16, w))
+	if got := d.Evaluate(); !got.Undef {
+		t.Errorf("illegal word decoded to %v, want undefined", got)
+	}
+	if d.Decoded() != nil {
+		t.Error("Decoded() non-nil for illegal word")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Interactive mode: step the clock one cycle at a time, evaluate and
+// print signals by name pattern, and force values onto forcible
+// inputs for targeted logic debugging.
+
+// Forcible is implemented by components whose output can be forced
+// from the prompt. Constants (and so tied-off inputs) are forcible;
+// derived logic is not.
+type Forcible interface {
+	Force(Bits)
+	Release()
+}
+
+// interact runs the command loop until quit or EOF.
+func interact(sys *System) {
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Printf("sim %d> ", sys.Cycles())
+		if !in.Scan() {
+			fmt.Println()
+			return
+		}
+		words := strings.Fields(in.Text())
+		if len(words) == 0 {
+			continue
+		}
+		switch cmd, args := words[0], words[1:]; cmd {
+		case "s", "step":
+			n := 1
+			if len(args) == 1 {
+				if v, err := strconv.Atoi(args[0]); err == nil && v > 0 {
+					n = v
+				}
+			}
+			for i := 0; i < n; i++ {
+				sys.Cycle()
+			}
+		case "p", "print":
+			pat := "*"
+			if len(args) == 1 {
+				pat = args[0]
+			}
+			printSignals(sys, pat)
+		case "force":
+			if len(args) != 2 {
+				fmt.Println("usage: force path value")
+				continue
+			}
+			force(sys, args[0], args[1])
+		case "release":
+			if len(args) != 1 {
+				fmt.Println("usage: release path")
+				continue
+			}
+			if f, ok := sys.Lookup(args[0]).(Forcible); ok {
+				f.Release()
+			} else {
+				fmt.Printf("%s: not forcible\n", args[0])
+			}
+		case "check":
+			if err := sys.Check(); err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println("ok")
+			}
+		case "q", "quit":
+			return
+		case "h", "help":
+			fmt.Print(interactHelp)
+		default:
+			fmt.Printf("unknown command %q; try help\n", cmd)
+		}
+	}
+}
+
+const interactHelp = `  s|step [n]        run n clock cycles (default 1)
+  p|print [pat]     evaluate and print signals matching glob pattern
+  force path value  force a value onto a forcible input
+  release path      remove a force
+  check             run component self-checks
+  q|quit            leave the simulator
+`
+
+// printSignals evaluates and prints every component whose full path
+// matches the glob pattern. '*' crosses module boundaries.
+func printSignals(sys *System, pat string) {
+	matched := false
+	for i, c := range sys.comps {
+		ok, err := path.Match(pat, sys.paths[i])
+		if err != nil {
+			fmt.Printf("bad pattern %q\n", pat)
+			return
+		}
+		if !ok {
+			continue
+		}
+		matched = true
+		fmt.Printf("  %-24s %s\n", sys.paths[i], c.Evaluate())
+	}
+	if !matched {
+		fmt.Printf("no signal matches %q\n", pat)
+	}
+}
+
+func force(sys *System, name, val string) {
+	c := sys.Lookup(name)
+	if c == nil {
+		fmt.Printf("no component %q\n", name)
+		return
+	}
+	f, ok := c.(Forcible)
+	if !ok {
+		fmt.Printf("%s: not forcible\n", name)
+		return
+	}
+	v, err := strconv.ParseUint(val, 0, 16)
+	if err != nil {
+		fmt.Printf("bad value %q\n", val)
+		return
+	}
+	w := c.Evaluate().Width
+	f.Force(MakeBits(w, uint16(v)))
+}
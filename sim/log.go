@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gmofishsauce/y4/wire"
+)
+
+// The simulation log is a binary file: a header naming the logged
+// components, then one fixed-size record per report. All multibyte
+// fields are little-endian, like everything else in the toolchain.
+//
+//	header:  magic "WUTL", version uint16, count uint16,
+//	         then count names, each uint16 length + bytes
+//	record:  cycle uint32, comp uint16 (index into the name table),
+//	         value uint16, width uint8, flags uint8
+//
+// Long runs rotate: when a file reaches MaxBytes of records the
+// writer closes it and starts the next, each file self-contained
+// with its own header. Without rotation the log is written to path;
+// with rotation the files are path.0, path.1, ... (plus .gz when
+// compressing). A sidecar index, path.idx, maps cycles to files and
+// record offsets so a reader can seek to a cycle range without
+// decompressing everything before it:
+//
+//	index:   magic "WUTX", version uint16, count uint16, then count
+//	         entries: cycle uint32, file uint16, offset uint32
+//	         (record offset in the uncompressed stream of that file)
+const (
+	logMagic   = "WUTL"
+	idxMagic   = "WUTX"
+	logVersion = 1
+	recBytes   = 10
+
+	logFlagUndef = 1 << 0
+
+	// indexStride is how many cycles pass between index entries
+	// within one file.
+	indexStride = 1024
+)
+
+// LogWriter writes the binary simulation log.
+type LogWriter struct {
+	path  string
+	names []string
+
+	// MaxBytes rotates to a new file once this many record bytes are
+	// written; 0 means never rotate. Compress gzips each file.
+	// Both must be set before the first Report.
+	MaxBytes int64
+	Compress bool
+
+	f       *os.File
+	gz      *gzip.Writer
+	w       *bufio.Writer
+	seq     int   // next file number
+	written int64 // record bytes in the current file
+	lastIx  int64 // cycle of the last index entry, -1 before any
+	index   []idxEntry
+}
+
+type idxEntry struct {
+	cycle  uint32
+	file   uint16
+	offset uint32
+}
+
+// NewLogWriter prepares a log writer. Files are created lazily on
+// the first Report, so rotation and compression options set after
+// construction still take effect.
+func NewLogWriter(path string, names []string) (*LogWriter, error) {
+	return &LogWriter{path: path, names: names, lastIx: -1}, nil
+}
+
+// fileName returns the name of file number seq under the current
+// rotation and compression settings.
+func (lw *LogWriter) fileName(seq int) string {
+	name := lw.path
+	if lw.MaxBytes > 0 {
+		name = fmt.Sprintf("%s.%d", lw.path, seq)
+	}
+	if lw.Compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// openNext starts the next log file and writes its header. Header
+// bytes do not count against MaxBytes.
+func (lw *LogWriter) openNext() error {
+	f, err := os.Create(lw.fileName(lw.seq))
+	if err != nil {
+		return err
+	}
+	lw.f = f
+	var out io.Writer = f
+	if lw.Compress {
+		lw.gz = gzip.NewWriter(f)
+		out = lw.gz
+	}
+	lw.w = bufio.NewWriter(out)
+	lw.seq++
+	lw.written = 0
+	lw.lastIx = -1
+
+	lw.w.WriteString(logMagic)
+	lw.u16(logVersion)
+	lw.u16(uint16(len(lw.names)))
+	for _, n := range lw.names {
+		lw.u16(uint16(len(n)))
+		lw.w.WriteString(n)
+	}
+	return nil
+}
+
+// closeCurrent flushes and closes the current file, if any.
+func (lw *LogWriter) closeCurrent() error {
+	if lw.f == nil {
+		return nil
+	}
+	err := lw.w.Flush()
+	if lw.gz != nil {
+		if e := lw.gz.Close(); err == nil {
+			err = e
+		}
+		lw.gz = nil
+	}
+	if e := lw.f.Close(); err == nil {
+		err = e
+	}
+	lw.f = nil
+	return err
+}
+
+// Report appends one record for component comp at the given cycle.
+func (lw *LogWriter) Report(cycle uint32, comp uint16, v Bits) {
+	if lw.f == nil || (lw.MaxBytes > 0 && lw.written >= lw.MaxBytes) {
+		lw.closeCurrent()
+		if err := lw.openNext(); err != nil {
+			fmt.Fprintf(os.Stderr, "sim log: %v\n", err)
+			return
+		}
+	}
+	if lw.lastIx < 0 || int64(cycle)-lw.lastIx >= indexStride {
+		lw.index = append(lw.index, idxEntry{
+			cycle:  cycle,
+			file:   uint16(lw.seq - 1),
+			offset: uint32(lw.written),
+		})
+		lw.lastIx = int64(cycle)
+	}
+	var rec [recBytes]byte
+	wire.PutLong(rec[0:], cycle)
+	wire.PutWord(rec[4:], comp)
+	wire.PutWord(rec[6:], v.Value)
+	rec[8] = uint8(v.Width)
+	if v.Undef {
+		rec[9] = logFlagUndef
+	}
+	lw.w.Write(rec[:])
+	lw.written += recBytes
+}
+
+// Close flushes the log and writes the cycle index.
+func (lw *LogWriter) Close() error {
+	if err := lw.closeCurrent(); err != nil {
+		return fmt.Errorf("sim log: %v", err)
+	}
+	if len(lw.index) == 0 {
+		return nil
+	}
+	f, err := os.Create(lw.path + ".idx")
+	if err != nil {
+		return fmt.Errorf("sim log index: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(idxMagic)
+	var b [10]byte
+	wire.PutWord(b[0:], logVersion)
+	wire.PutWord(b[2:], uint16(len(lw.index)))
+	w.Write(b[:4])
+	for _, e := range lw.index {
+		wire.PutLong(b[0:], e.cycle)
+		wire.PutWord(b[4:], e.file)
+		wire.PutLong(b[6:], e.offset)
+		w.Write(b[:10])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("sim log index: %v", err)
+	}
+	return f.Close()
+}
+
+func (lw *LogWriter) u16(v uint16) {
+	var b [2]byte
+	wire.PutWord(b[:], v)
+	lw.w.Write(b[:])
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gmofishsauce/y4/wire"
+)
+
+func TestLogRotationAndIndex(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.bin")
+	lw, err := NewLogWriter(path, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lw.MaxBytes = 4 * recBytes // rotate every four records
+	for cycle := uint32(0); cycle < 10; cycle++ {
+		lw.Report(cycle, 0, MakeBits(16, uint16(cycle)))
+		lw.Report(cycle, 1, MakeBits(16, 0))
+	}
+	if err := lw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 records at 4 per file is 5 files, each self-contained.
+	for seq := 0; seq < 5; seq++ {
+		b, err := os.ReadFile(path + "." + string(rune('0'+seq)))
+		if err != nil {
+			t.Fatalf("file %d: %v", seq, err)
+		}
+		if string(b[:4]) != logMagic {
+			t.Fatalf("file %d: bad magic %q", seq, b[:4])
+		}
+	}
+
+	ix, err := os.ReadFile(path + ".idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ix[:4]) != idxMagic {
+		t.Fatalf("index magic %q", ix[:4])
+	}
+	// One entry per file (the stride is much larger than the run):
+	// entry n names file n at record offset 0.
+	n := int(wire.Word(ix[6:]))
+	if n != 5 {
+		t.Fatalf("index entries = %d, want 5", n)
+	}
+	e := ix[8+10:] // second entry
+	if wire.Word(e[4:]) != 1 || wire.Long(e[6:]) != 0 {
+		t.Errorf("entry 1 = file %d offset %d, want file 1 offset 0",
+			wire.Word(e[4:]), wire.Long(e[6:]))
+	}
+}
+
+func TestLogCompression(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.bin")
+	lw, err := NewLogWriter(path, []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lw.Compress = true
+	lw.Report(0, 0, MakeBits(16, 0x1234))
+	if err := lw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:4]) != logMagic {
+		t.Fatalf("decompressed magic %q", b[:4])
+	}
+	rec := b[len(b)-recBytes:]
+	if wire.Word(rec[6:]) != 0x1234 {
+		t.Errorf("record value = 0x%04X", wire.Word(rec[6:]))
+	}
+}
@@ -0,0 +1,80 @@
+// sim is the structural simulator for WUT-4. Where func interprets
+// instructions, sim models the machine as a netlist of components
+// evaluated once per clock cycle, so the hardware design can be
+// checked against the functional simulator.
+//
+// The model under simulation is still being built up; for now main
+// runs a small testbench so the component machinery can be exercised
+// end to end.
+//
+// Usage: sim [-i] [-cycles n] [-log log.bin]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var cycles = flag.Int("cycles", 10, "number of clock cycles to run")
+var logFile = flag.String("log", "log.bin", "binary simulation log; empty to disable")
+var interactive = flag.Bool("i", false, "interactive single-cycle stepping prompt")
+var quiet = flag.Bool("quiet", false, "log only value changes, not every cycle")
+var logSize = flag.Int("logsize", 0, "rotate the log every n megabytes; 0 for one file")
+var logZip = flag.Bool("logz", false, "gzip the log file(s)")
+var replayComp = flag.String("replay", "", "print the value-change list of a component from the log and exit")
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: sim [-i] [-cycles n] [-log log.bin]")
+		os.Exit(2)
+	}
+	if *replayComp != "" {
+		if err := replay(*logFile, *replayComp, os.Stdout); err != nil {
+			fatal("%v", err)
+		}
+		return
+	}
+	sys := testbench()
+	sys.Quiet = *quiet
+	if *logFile != "" {
+		lw, err := NewLogWriter(*logFile, sys.Names())
+		if err != nil {
+			fatal("%v", err)
+		}
+		lw.MaxBytes = int64(*logSize) << 20
+		lw.Compress = *logZip
+		defer lw.Close()
+		sys.Log = lw
+	}
+	if *interactive {
+		interact(sys)
+		return
+	}
+	for i := 0; i < *cycles; i++ {
+		sys.Cycle()
+	}
+	if err := sys.Check(); err != nil {
+		fatal("%v", err)
+	}
+}
+
+// testbench builds a placeholder system: a zero generator, a reset
+// vector, and a decoder fed a fixed word, enough to drive the cycle,
+// hierarchy, and logging machinery.
+func testbench() *System {
+	sys := NewSystem()
+	cpu := sys.Root().Sub("cpu")
+	cpu.Add(NewZeroGenerator("zero", 16))
+	cpu.Add(NewConstant("rstvec", 16, 0x0000))
+	ir := NewConstant("ir", 16, 0xE000) // add r0, r0
+	cpu.Add(ir)
+	cpu.Add(NewDecoder("dec", ir))
+	return sys
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "sim: "+format+"\n", args...)
+	os.Exit(1)
+}
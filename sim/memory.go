@@ -0,0 +1,62 @@
+package main
+
+import "github.com/gmofishsauce/y4/mem"
+
+// Ram is the data memory as a structural component, backed by the
+// shared mem package so that in compare mode its access sequence is
+// recorded the same way func records through its memory hook.
+//
+// It is a synchronous one-port RAM: on each positive edge it samples
+// addr, we, re, and wdata, performs at most one access, and latches
+// read data for the next cycle's output. Doing the access at the
+// edge rather than in Evaluate matters for the trace: Evaluate runs
+// once per fanout during the combinational settle, and recording
+// there would log the same load several times.
+type Ram struct {
+	name                string
+	addr, wdata, we, re Component
+	mem                 *mem.Memory
+	out                 Bits
+}
+
+// NewRam builds the RAM over a shared memory. addr and wdata are
+// 16-bit buses; we and re are single wires.
+func NewRam(name string, m *mem.Memory, addr, wdata, we, re Component) *Ram {
+	return &Ram{name: name, addr: addr, wdata: wdata, we: we, re: re,
+		mem: m, out: UndefBits(16)}
+}
+
+func (r *Ram) Name() string        { return r.name }
+func (r *Ram) Inputs() []Component { return []Component{r.addr, r.wdata, r.we, r.re} }
+func (r *Ram) Clocked()            {}
+func (r *Ram) Prepare()            {}
+
+func (r *Ram) Evaluate() Bits { return r.out }
+
+func (r *Ram) PositiveEdge() {
+	addr := r.addr.Evaluate()
+	we := r.we.Evaluate()
+	re := r.re.Evaluate()
+	switch {
+	case we.Undef || re.Undef || (we.Value != 0 && addr.Undef):
+		r.out = UndefBits(16)
+	case we.Value != 0:
+		// Write port wins; the read output goes undefined, as the
+		// real array's would.
+		w := r.wdata.Evaluate()
+		if w.Undef {
+			r.out = UndefBits(16)
+			return
+		}
+		r.mem.StoreWord(addr.Value, w.Value)
+		r.out = UndefBits(16)
+	case re.Value != 0:
+		if addr.Undef {
+			r.out = UndefBits(16)
+			return
+		}
+		r.out = MakeBits(16, r.mem.LoadWord(addr.Value))
+	}
+}
+
+func (r *Ram) Check() error { return nil }
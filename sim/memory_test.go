@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gmofishsauce/y4/mem"
+)
+
+// A write latched on one edge must be readable on a later cycle, and
+// the shared trace must show exactly one access per active cycle —
+// the property compare mode depends on.
+func TestRamWriteThenRead(t *testing.T) {
+	tr := &mem.Trace{}
+	addr := NewConstant("addr", 16, 0x20)
+	wdata := NewConstant("wdata", 16, 0xBEEF)
+	we := NewConstant("we", 1, 1)
+	re := NewConstant("re", 1, 0)
+	ram := NewRam("ram", mem.New(tr), addr, wdata, we, re)
+	sys := NewSystem()
+	for _, c := range []Component{addr, wdata, we, re, ram} {
+		sys.Add(c)
+	}
+
+	sys.Cycle() // write edge
+	we.Force(MakeBits(1, 0))
+	re.Force(MakeBits(1, 1))
+	sys.Cycle() // read edge
+	if out := ram.Evaluate(); out.Undef || out.Value != 0xBEEF {
+		t.Errorf("read back %s, want 0xBEEF", out)
+	}
+
+	want := []mem.Access{
+		{Addr: 0x20, Value: 0xBEEF, Size: 2, Write: true},
+		{Addr: 0x20, Value: 0xBEEF, Size: 2},
+	}
+	if tr.Len() != len(want) {
+		t.Fatalf("trace has %d accesses, want %d", tr.Len(), len(want))
+	}
+	for i, w := range want {
+		if tr.At(i) != w {
+			t.Errorf("access %d = %+v, want %+v", i, tr.At(i), w)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gmofishsauce/y4/wire"
+)
+
+// Replay reconstructs the visible state of one component over time
+// purely from the simulation log — a waveform query. It prints a
+// value-change list (one line per cycle on which the value differed
+// from the previous one), so postmortem analysis of a signal does
+// not require rerunning the simulation.
+
+// replay walks every log file in order and prints the change list
+// for the component with the given full path.
+func replay(path, comp string, w io.Writer) error {
+	files, err := logFiles(path)
+	if err != nil {
+		return err
+	}
+	var last Bits
+	seen := false
+	for _, name := range files {
+		if err := replayFile(name, comp, w, &last, &seen); err != nil {
+			return err
+		}
+	}
+	if !seen {
+		return fmt.Errorf("no records for component %q", comp)
+	}
+	return nil
+}
+
+// logFiles returns the log file names in cycle order, coping with
+// the single-file, rotated, and compressed layouts.
+func logFiles(path string) ([]string, error) {
+	for _, name := range []string{path, path + ".gz"} {
+		if _, err := os.Stat(name); err == nil {
+			return []string{name}, nil
+		}
+	}
+	var files []string
+	for seq := 0; ; seq++ {
+		found := false
+		for _, suffix := range []string{"", ".gz"} {
+			name := fmt.Sprintf("%s.%d%s", path, seq, suffix)
+			if _, err := os.Stat(name); err == nil {
+				files = append(files, name)
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no log files for %s", path)
+	}
+	return files, nil
+}
+
+func replayFile(name, comp string, w io.Writer, last *Bits, seen *bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var in io.Reader = f
+	if len(name) > 3 && name[len(name)-3:] == ".gz" {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		defer zr.Close()
+		in = zr
+	}
+	r := bufio.NewReader(in)
+
+	names, err := readLogHeader(r, name)
+	if err != nil {
+		return err
+	}
+	want := -1
+	for i, n := range names {
+		if n == comp {
+			want = i
+			break
+		}
+	}
+	if want < 0 {
+		return fmt.Errorf("%s: no component %q in the log", name, comp)
+	}
+
+	var rec [recBytes]byte
+	for {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if int(wire.Word(rec[4:])) != want {
+			continue
+		}
+		v := Bits{
+			Width: int(rec[8]),
+			Value: wire.Word(rec[6:]),
+			Undef: rec[9]&logFlagUndef != 0,
+		}
+		if *seen && v == *last {
+			continue
+		}
+		fmt.Fprintf(w, "cycle %d: %s\n", wire.Long(rec[0:]), v)
+		*last = v
+		*seen = true
+	}
+}
+
+// readLogHeader consumes one file's header and returns its component
+// name table.
+func readLogHeader(r *bufio.Reader, name string) ([]string, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("%s: short header: %v", name, err)
+	}
+	if string(hdr[:4]) != logMagic {
+		return nil, fmt.Errorf("%s: not a simulation log", name)
+	}
+	if v := wire.Word(hdr[4:]); v != logVersion {
+		return nil, fmt.Errorf("%s: log version %d, want %d", name, v, logVersion)
+	}
+	count := int(wire.Word(hdr[6:]))
+	names := make([]string, count)
+	for i := range names {
+		var lb [2]byte
+		if _, err := io.ReadFull(r, lb[:]); err != nil {
+			return nil, fmt.Errorf("%s: short name table: %v", name, err)
+		}
+		b := make([]byte, wire.Word(lb[:]))
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, fmt.Errorf("%s: short name table: %v", name, err)
+		}
+		names[i] = string(b)
+	}
+	return names, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayChangeList(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.bin")
+	lw, err := NewLogWriter(path, []string{"cpu.pc", "cpu.ir"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lw.MaxBytes = 6 * recBytes // force a rotation mid-run
+	lw.Compress = true
+	values := []uint16{0, 0, 1, 1, 1, 2}
+	for cycle, v := range values {
+		lw.Report(uint32(cycle), 0, MakeBits(16, v))
+		lw.Report(uint32(cycle), 1, MakeBits(16, 0xFFFF))
+	}
+	if err := lw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := replay(path, "cpu.pc", &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "cycle 0: 0x0000/16\ncycle 2: 0x0001/16\ncycle 5: 0x0002/16\n"
+	if out.String() != want {
+		t.Errorf("replay:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	if err := replay(path, "cpu.nope", &out); err == nil {
+		t.Error("replay of unknown component should fail")
+	}
+}
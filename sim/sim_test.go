@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestConstantAndZero(t *testing.T) {
+	c := NewConstant("k", 7, 0xFFFF) // value masked to width
+	if got := c.Evaluate(); got.Undef || got.Value != 0x7F || got.Width != 7 {
+		t.Errorf("constant = %v", got)
+	}
+	z := NewZeroGenerator("z", 16)
+	if got := z.Evaluate(); got.Undef || got.Value != 0 {
+		t.Errorf("zero = %v", got)
+	}
+}
+
+func TestSystemCycle(t *testing.T) {
+	sys := NewSystem()
+	sys.Add(NewConstant("a", 16, 1))
+	sys.Add(NewConstant("b", 16, 2))
+	for i := 0; i < 3; i++ {
+		sys.Cycle()
+	}
+	if sys.Cycles() != 3 {
+		t.Errorf("cycles = %d, want 3", sys.Cycles())
+	}
+	if err := sys.Check(); err != nil {
+		t.Errorf("check: %v", err)
+	}
+	if sys.Lookup("b") == nil || sys.Lookup("c") != nil {
+		t.Error("lookup misbehaved")
+	}
+}
+
+func TestModuleHierarchy(t *testing.T) {
+	sys := NewSystem()
+	alu := sys.Root().Sub("cpu").Sub("alu")
+	alu.Add(NewConstant("adder0", 16, 0))
+	sys.Root().Sub("mem").Add(NewConstant("imem", 16, 0))
+	if sys.Lookup("cpu.alu.adder0") == nil {
+		t.Error("full path not registered")
+	}
+	if sys.Lookup("adder0") != nil {
+		t.Error("bare name leaked to the root namespace")
+	}
+	if got := sys.Names(); len(got) != 2 || got[1] != "mem.imem" {
+		t.Errorf("names = %v", got)
+	}
+	if err := alu.Check(); err != nil {
+		t.Errorf("module check: %v", err)
+	}
+	if err := sys.Root().Sub("cpu").Sub("fpu").Check(); err == nil {
+		t.Error("empty module check should fail")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import "fmt"
+
+// System owns the components and runs the clock. Components live in
+// an insertion-ordered slice (never a bare map) so evaluation order
+// and log output are reproducible run to run.
+//
+// Components are registered through Modules, which give each one a
+// hierarchical dotted path (cpu.alu.adder0). The path, not the
+// component's own short name, is what appears in the log name table
+// and in Lookup; short names only need to be unique within their
+// module.
+type System struct {
+	comps []Component
+	paths []string // full dotted path of comps[i]
+	index map[string]int
+	cycle uint32
+
+	// Evaluation order and change tracking, built lazily on the
+	// first Cycle after components are added.
+	order []int   // topological evaluation order
+	fanin [][]int // fanin[i]: indices feeding comps[i]
+	vals  []Bits  // this cycle's outputs
+	prev  []Bits  // last cycle's outputs
+
+	// Log, when non-nil, receives every component's evaluated value
+	// each cycle (only changed values when Quiet is set).
+	Log *LogWriter
+
+	// Quiet logs only value changes instead of every component every
+	// cycle, which keeps long runs fast and log.bin small.
+	Quiet bool
+}
+
+func NewSystem() *System {
+	return &System{index: make(map[string]int)}
+}
+
+// Module is one level of the design hierarchy. The root module has
+// an empty path; components added to it keep their bare names.
+type Module struct {
+	sys  *System
+	path string
+}
+
+// Root returns the system's root module.
+func (s *System) Root() *Module {
+	return &Module{sys: s}
+}
+
+// Sub returns the child module with the given name.
+func (m *Module) Sub(name string) *Module {
+	return &Module{sys: m.sys, path: m.join(name)}
+}
+
+func (m *Module) join(name string) string {
+	if m.path == "" {
+		return name
+	}
+	return m.path + "." + name
+}
+
+// Add registers a component under this module's path. Paths must be
+// unique across the whole system.
+func (m *Module) Add(c Component) {
+	s := m.sys
+	path := m.join(c.Name())
+	if _, dup := s.index[path]; dup {
+		panic(fmt.Sprintf("sim: duplicate component %q", path))
+	}
+	s.index[path] = len(s.comps)
+	s.comps = append(s.comps, c)
+	s.paths = append(s.paths, path)
+	s.order = nil // re-sort on the next Cycle
+}
+
+// Check checks only the components under this module, returning the
+// first failure along with a count of how many were checked.
+func (m *Module) Check() error {
+	s := m.sys
+	checked := 0
+	for i, c := range s.comps {
+		if !underPath(s.paths[i], m.path) {
+			continue
+		}
+		checked++
+		if err := c.Check(); err != nil {
+			return fmt.Errorf("%s: %v", s.paths[i], err)
+		}
+	}
+	if checked == 0 && m.path != "" {
+		return fmt.Errorf("module %s: no components", m.path)
+	}
+	return nil
+}
+
+// underPath reports whether a full component path lies inside the
+// module path (which is every path for the root module).
+func underPath(path, module string) bool {
+	if module == "" {
+		return true
+	}
+	return len(path) > len(module) && path[:len(module)] == module &&
+		path[len(module)] == '.'
+}
+
+// Add registers a component at the root of the hierarchy.
+func (s *System) Add(c Component) {
+	s.Root().Add(c)
+}
+
+// Lookup returns the component with the given full path, or nil.
+func (s *System) Lookup(path string) Component {
+	if i, ok := s.index[path]; ok {
+		return s.comps[i]
+	}
+	return nil
+}
+
+// Names returns the full component paths in registration order.
+func (s *System) Names() []string {
+	names := make([]string, len(s.comps))
+	copy(names, s.paths)
+	return names
+}
+
+// Sourced is implemented by components that read other components.
+// Declaring inputs lets the system evaluate in dependency order and
+// skip components whose entire fan-in is unchanged.
+type Sourced interface {
+	Inputs() []Component
+}
+
+// Clocked marks components with internal state, which must be
+// evaluated every cycle even when their visible inputs have not
+// changed.
+type Clocked interface {
+	Clocked()
+}
+
+// sort memoizes a topological order of the combinational graph and
+// the fan-in index lists used for change detection. Components that
+// do not declare inputs are treated as sources.
+func (s *System) sort() {
+	byComp := make(map[Component]int, len(s.comps))
+	for i, c := range s.comps {
+		byComp[c] = i
+	}
+	s.fanin = make([][]int, len(s.comps))
+	for i, c := range s.comps {
+		if src, ok := c.(Sourced); ok {
+			for _, in := range src.Inputs() {
+				if j, ok := byComp[in]; ok {
+					s.fanin[i] = append(s.fanin[i], j)
+				}
+			}
+		}
+	}
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(s.comps))
+	s.order = s.order[:0]
+	var visit func(i int)
+	visit = func(i int) {
+		switch state[i] {
+		case visiting:
+			panic(fmt.Sprintf("sim: combinational loop through %s", s.paths[i]))
+		case done:
+			return
+		}
+		state[i] = visiting
+		for _, j := range s.fanin[i] {
+			visit(j)
+		}
+		state[i] = done
+		s.order = append(s.order, i)
+	}
+	for i := range s.comps {
+		visit(i)
+	}
+	s.vals = make([]Bits, len(s.comps))
+	s.prev = make([]Bits, len(s.comps))
+}
+
+// Cycle runs one clock: prepare, combinational evaluate in memoized
+// topological order, then the positive edge. A component that
+// declares its inputs, holds no state, and whose entire fan-in is
+// unchanged since last cycle is skipped and its previous output
+// reused.
+func (s *System) Cycle() {
+	if s.order == nil {
+		s.sort()
+	}
+	for _, c := range s.comps {
+		c.Prepare()
+	}
+	for _, i := range s.order {
+		c := s.comps[i]
+		if s.cycle > 0 && s.skippable(i) {
+			s.vals[i] = s.prev[i]
+			continue
+		}
+		s.vals[i] = c.Evaluate()
+	}
+	if s.Log != nil {
+		for i := range s.comps {
+			if s.Quiet && s.cycle > 0 && s.vals[i] == s.prev[i] {
+				continue
+			}
+			s.Log.Report(s.cycle, uint16(i), s.vals[i])
+		}
+	}
+	for _, c := range s.comps {
+		c.PositiveEdge()
+	}
+	copy(s.prev, s.vals)
+	s.cycle++
+}
+
+// skippable reports whether comps[i] can reuse last cycle's output.
+func (s *System) skippable(i int) bool {
+	c := s.comps[i]
+	if _, stateful := c.(Clocked); stateful {
+		return false
+	}
+	if _, sourced := c.(Sourced); !sourced {
+		return false // a source may change (reset, force) at any time
+	}
+	for _, j := range s.fanin[i] {
+		if s.vals[j] != s.prev[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// Cycles returns the number of completed clock cycles.
+func (s *System) Cycles() uint32 { return s.cycle }
+
+// Check asks every component to check itself and returns the first
+// failure, identified by its full path.
+func (s *System) Check() error {
+	return s.Root().Check()
+}
@@ -0,0 +1,54 @@
+// Package wire centralizes the toolchain's on-disk byte order. Every
+// WUT-4 file format — binaries, archives, the simulation log — stores
+// multibyte fields little-endian regardless of the host, matching
+// the byte order the architecture defines for words in data memory.
+// All file encoding and decoding goes through these helpers; nothing
+// else in the tree should touch encoding/binary for file I/O, so a
+// port to a big-endian host cannot silently change a format.
+//
+// The implementation is explicit shifts, never a host-order view of
+// memory, and the tests pin exact byte layouts rather than round
+// trips, so they fail on any host where the encoding drifts.
+package wire
+
+import "io"
+
+// Word decodes a little-endian uint16 from the start of b.
+func Word(b []byte) uint16 {
+	return uint16(b[0]) | uint16(b[1])<<8
+}
+
+// PutWord encodes v little-endian into the start of b.
+func PutWord(b []byte, v uint16) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+}
+
+// Long decodes a little-endian uint32 from the start of b.
+func Long(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+// PutLong encodes v little-endian into the start of b.
+func PutLong(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
+
+// WriteWord writes v little-endian to w.
+func WriteWord(w io.Writer, v uint16) error {
+	var b [2]byte
+	PutWord(b[:], v)
+	_, err := w.Write(b[:])
+	return err
+}
+
+// WriteLong writes v little-endian to w.
+func WriteLong(w io.Writer, v uint32) error {
+	var b [4]byte
+	PutLong(b[:], v)
+	_, err := w.Write(b[:])
+	return err
+}
@@ -0,0 +1,46 @@
+package wire
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The tests assert exact byte sequences, not encode/decode round
+// trips: a round trip passes on any host even if the byte order is
+// wrong, while these fail anywhere the layout is not little-endian.
+
+func TestWordLayout(t *testing.T) {
+	b := make([]byte, 2)
+	PutWord(b, 0x1234)
+	if b[0] != 0x34 || b[1] != 0x12 {
+		t.Errorf("PutWord(0x1234) = % X, want 34 12", b)
+	}
+	if v := Word([]byte{0xCD, 0xAB}); v != 0xABCD {
+		t.Errorf("Word(CD AB) = 0x%04X, want 0xABCD", v)
+	}
+}
+
+func TestLongLayout(t *testing.T) {
+	b := make([]byte, 4)
+	PutLong(b, 0x12345678)
+	if !bytes.Equal(b, []byte{0x78, 0x56, 0x34, 0x12}) {
+		t.Errorf("PutLong(0x12345678) = % X, want 78 56 34 12", b)
+	}
+	if v := Long([]byte{0xEF, 0xBE, 0xAD, 0xDE}); v != 0xDEADBEEF {
+		t.Errorf("Long(EF BE AD DE) = 0x%08X, want 0xDEADBEEF", v)
+	}
+}
+
+func TestWriters(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteWord(&b, 0x0102); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteLong(&b, 0x03040506); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x02, 0x01, 0x06, 0x05, 0x04, 0x03}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("writers produced % X, want % X", b.Bytes(), want)
+	}
+}
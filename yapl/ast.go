@@ -0,0 +1,162 @@
+package main
+
+// The YAPL-1 abstract syntax tree. Parsing fills in the structure
+// and source lines; semantic analysis fills in the types and may
+// replace constant subtrees with folded literals.
+
+// Type is a YAPL-1 type. There are deliberately few of them.
+type Type int
+
+const (
+	TypeNone Type = iota // "no expectation" during checking
+	TypeVoid             // function with no result
+	TypeU8
+	TypeU16
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeVoid:
+		return "void"
+	case TypeU8:
+		return "u8"
+	case TypeU16:
+		return "u16"
+	}
+	return "untyped"
+}
+
+type Program struct {
+	Funcs []*FuncDecl
+}
+
+type FuncDecl struct {
+	Name   string
+	Params []Param
+	Ret    Type // TypeVoid when no result is declared
+	Body   *Block
+	Line   int
+}
+
+type Param struct {
+	Name string
+	Type Type
+	Line int
+}
+
+type Block struct {
+	Stmts []Stmt
+}
+
+// Stmt is one statement. The interface is a marker; analysis and
+// code generation switch on the concrete types.
+type Stmt interface {
+	stmtLine() int
+}
+
+type VarStmt struct {
+	Name string
+	Type Type
+	Size int  // element count for an array declaration; 0 for a scalar
+	Init Expr // nil means zero-initialized
+	Line int
+}
+
+type AssignStmt struct {
+	Name string
+	Idx  Expr // index for "name[i] = e"; nil for a scalar assignment
+	Expr Expr
+	Line int
+}
+
+type IfStmt struct {
+	Cond Expr
+	Then *Block
+	Else *Block // nil when absent
+	Line int
+}
+
+type WhileStmt struct {
+	Cond Expr
+	Body *Block
+	Line int
+}
+
+type ReturnStmt struct {
+	Expr Expr // nil for a bare return
+	Line int
+}
+
+type ExprStmt struct {
+	Call *CallExpr
+	Line int
+}
+
+func (s *VarStmt) stmtLine() int    { return s.Line }
+func (s *AssignStmt) stmtLine() int { return s.Line }
+func (s *IfStmt) stmtLine() int     { return s.Line }
+func (s *WhileStmt) stmtLine() int  { return s.Line }
+func (s *ReturnStmt) stmtLine() int { return s.Line }
+func (s *ExprStmt) stmtLine() int   { return s.Line }
+
+// Expr is one expression. Typ is set by semantic analysis.
+type Expr interface {
+	exprLine() int
+	exprType() Type
+}
+
+type NumLit struct {
+	Val  uint16
+	Typ  Type
+	Line int
+}
+
+type Ident struct {
+	Name string
+	Typ  Type
+	Line int
+}
+
+type BinExpr struct {
+	Op   string // lexer spelling: + - * / % & | ^ << >> == != < <= > >=
+	L, R Expr
+	Typ  Type
+	Line int
+}
+
+type CallExpr struct {
+	Name string
+	Args []Expr
+	Typ  Type
+	Line int
+}
+
+// IndexExpr is a byte load from an array: name[i].
+type IndexExpr struct {
+	Name string
+	Idx  Expr
+	Typ  Type
+	Line int
+}
+
+// StrLit is a string literal; its value is the dmem address of the
+// NUL-terminated bytes, so its type is u16.
+type StrLit struct {
+	Val  string
+	Typ  Type
+	Line int
+}
+
+func (e *NumLit) exprLine() int    { return e.Line }
+func (e *Ident) exprLine() int     { return e.Line }
+func (e *BinExpr) exprLine() int   { return e.Line }
+func (e *CallExpr) exprLine() int  { return e.Line }
+func (e *IndexExpr) exprLine() int { return e.Line }
+func (e *StrLit) exprLine() int    { return e.Line }
+
+func (e *NumLit) exprType() Type    { return e.Typ }
+func (e *Ident) exprType() Type     { return e.Typ }
+func (e *BinExpr) exprType() Type   { return e.Typ }
+func (e *CallExpr) exprType() Type  { return e.Typ }
+func (e *IndexExpr) exprType() Type { return e.Typ }
+func (e *StrLit) exprType() Type    { return e.Typ }
@@ -0,0 +1,711 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gmofishsauce/y4/diag"
+)
+
+// The YAPL-1 code generator: a tree walker emitting WUT-4 assembly
+// for asm. Every variable lives in the function's frame; expressions
+// evaluate in the scratch registers (r2..r5 plus r1, which is dead
+// between calls), hungrier subtrees first by Sethi-Ullman numbering.
+// When pressure peaks anyway, values spill to dedicated slots above
+// the variables in the frame — never through sp, which must not
+// move mid-expression while locals are addressed relative to it.
+// Multiplication, division, and modulus use the math unit's ports
+// inline.
+//
+// Each statement is annotated with its source line as a comment in
+// the fixed form "; line N: text". Those markers double as the line
+// table: until the object format grows debug sections, tools that
+// want to map code back to YAPL source (trace viewers, the golden
+// test normalizer) parse them out of the assembly.
+//
+// ABI (matching lib/math.s and .func/.endfunc): r1 link, r6 stack,
+// r7 assembler/codegen temporary; arguments in r2/r3, result in r2.
+
+type generator struct {
+	eng   *diag.Engine
+	src   []string // source lines, for the line comments
+	out   strings.Builder
+	buf   *strings.Builder // current emit target (function bodies buffer)
+	fn    *FuncDecl
+	vars  []genVar // insertion-ordered frame layout
+	inUse [8]bool  // scratch register occupancy
+	label int      // local label counter
+	line  int      // last source line annotated
+
+	// Spill state: slots live in the frame above the variables, so
+	// sp never moves mid-expression and local offsets stay valid.
+	spillBase int    // byte offset of slot 0
+	slots     []bool // slot occupancy
+	maxSlots  int    // high-water mark, sizes the frame
+
+	// Static data: arrays and string literals, emitted as one .data
+	// section after the code. Arrays get static storage (not frame
+	// slots), so they are not reentrant; YAPL-1 accepts that.
+	data   []dataItem
+	strs   map[string]string // string value -> label, for dedup
+	dlabel int
+
+	stats genStats
+}
+
+// dataItem is one labeled run of static data.
+type dataItem struct {
+	label string
+	bytes []byte // nil for reserved (zero) space
+	size  int    // byte count when bytes is nil
+}
+
+// genStats is the per-function allocation accounting printed under
+// -regstats.
+type genStats struct {
+	allocs  int // scratch registers taken
+	spills  int // values stored to a frame spill slot
+	reloads int // values loaded back from one
+}
+
+type genVar struct {
+	name   string
+	offset int    // byte offset from sp; unused for arrays
+	label  string // static data label; set only for arrays
+	depth  int    // scope depth, for block-local shadowing
+}
+
+// scratchRegs are the expression evaluation registers, in allocation
+// order. r2 and r3 double as the argument/result registers, and r1
+// (the link register) joins the pool last: it is dead between calls,
+// and calls save live scratch registers anyway.
+var scratchRegs = []int{2, 3, 4, 5, 1}
+
+// Generate emits the assembly for an analyzed program. src is the
+// original source, used for line comments.
+func Generate(prog *Program, src []byte, eng *diag.Engine) []byte {
+	g := &generator{eng: eng, src: strings.Split(string(src), "\n"),
+		strs: make(map[string]string)}
+	g.buf = &g.out
+	g.emit("; generated by yapl; do not edit")
+	g.emit("")
+	g.emit("_start:")
+	g.emit("    li r6, 0xFFFE        ; stack at the top of dmem")
+	g.emit("    li r7, main")
+	g.emit("    jlr r1, r7, 0")
+	g.emit("    hlt")
+	for _, fn := range prog.Funcs {
+		g.genFunc(fn)
+	}
+	g.genData()
+	return []byte(g.out.String())
+}
+
+// genData emits the static data section: .space for arrays, .byte
+// for string literals (NUL-terminated, so a u16 holding the address
+// is a usable string).
+func (g *generator) genData() {
+	if len(g.data) == 0 {
+		return
+	}
+	g.emit("")
+	g.emit(".data")
+	for _, d := range g.data {
+		g.emit("%s:", d.label)
+		if d.bytes == nil {
+			g.emit("    .space %d", d.size)
+			continue
+		}
+		var b strings.Builder
+		for i, c := range d.bytes {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%d", c)
+		}
+		g.emit("    .byte %s", b.String())
+	}
+}
+
+// newDataLabel returns a fresh static data label.
+func (g *generator) newDataLabel() string {
+	g.dlabel++
+	return fmt.Sprintf(".D%d", g.dlabel)
+}
+
+// strLabel interns a string literal and returns its label.
+func (g *generator) strLabel(s string) string {
+	if l, ok := g.strs[s]; ok {
+		return l
+	}
+	l := g.newDataLabel()
+	g.strs[s] = l
+	g.data = append(g.data, dataItem{label: l, bytes: append([]byte(s), 0)})
+	return l
+}
+
+func (g *generator) emit(format string, args ...interface{}) {
+	fmt.Fprintf(g.buf, format+"\n", args...)
+}
+
+// note annotates the next instructions with their source line, once
+// per line.
+func (g *generator) note(line int) {
+	if line == g.line || line <= 0 || line > len(g.src) {
+		return
+	}
+	g.line = line
+	g.emit("    ; line %d: %s", line, strings.TrimSpace(g.src[line-1]))
+}
+
+func (g *generator) errorf(line int, format string, args ...interface{}) {
+	g.eng.Errorf(line, 1, format, args...)
+}
+
+func (g *generator) newLabel() string {
+	g.label++
+	return fmt.Sprintf(".L%d", g.label)
+}
+
+// alloc takes a free scratch register. Callers arrange (by spilling)
+// that one is always available, so exhaustion is an internal error.
+func (g *generator) alloc(line int) int {
+	for _, r := range scratchRegs {
+		if !g.inUse[r] {
+			g.inUse[r] = true
+			g.stats.allocs++
+			return r
+		}
+	}
+	g.errorf(line, "internal: out of scratch registers")
+	return 2
+}
+
+func (g *generator) free(r int) { g.inUse[r] = false }
+
+// freeCount reports remaining capacity. A binary node must see two
+// free registers before evaluating its second operand: one for the
+// operand itself and one to reload the spilled first operand into.
+func (g *generator) freeCount() int {
+	n := 0
+	for _, r := range scratchRegs {
+		if !g.inUse[r] {
+			n++
+		}
+	}
+	return n
+}
+
+// allocSlot reserves a frame spill slot and returns its byte offset.
+func (g *generator) allocSlot() int {
+	for i, used := range g.slots {
+		if !used {
+			g.slots[i] = true
+			return g.spillBase + 2*i
+		}
+	}
+	g.slots = append(g.slots, true)
+	if len(g.slots) > g.maxSlots {
+		g.maxSlots = len(g.slots)
+	}
+	return g.spillBase + 2*(len(g.slots)-1)
+}
+
+func (g *generator) freeSlot(off int) {
+	g.slots[(off-g.spillBase)/2] = false
+}
+
+// spill stores a live register to a fresh slot and frees it.
+func (g *generator) spill(r int) int {
+	off := g.allocSlot()
+	g.emit("    stw r%d, r6, %d", r, off)
+	g.free(r)
+	g.stats.spills++
+	return off
+}
+
+// reload brings a spilled value back into a fresh register.
+func (g *generator) reload(off, line int) int {
+	r := g.alloc(line)
+	g.emit("    ldw r%d, r6, %d", r, off)
+	g.freeSlot(off)
+	g.stats.reloads++
+	return r
+}
+
+// findVar finds a variable, innermost declaration first.
+func (g *generator) findVar(name string) *genVar {
+	for i := len(g.vars) - 1; i >= 0; i-- {
+		if g.vars[i].name == name {
+			return &g.vars[i]
+		}
+	}
+	return nil
+}
+
+// countVars sizes the frame: every scalar declaration gets a word
+// slot, including shadowed ones (slots are not reused across blocks).
+// Arrays live in static data, not the frame.
+func countVars(b *Block) int {
+	n := 0
+	for _, s := range b.Stmts {
+		switch s := s.(type) {
+		case *VarStmt:
+			if s.Size == 0 {
+				n++
+			}
+		case *IfStmt:
+			n += countVars(s.Then)
+			if s.Else != nil {
+				n += countVars(s.Else)
+			}
+		case *WhileStmt:
+			n += countVars(s.Body)
+		}
+	}
+	return n
+}
+
+func (g *generator) genFunc(fn *FuncDecl) {
+	g.fn = fn
+	g.vars = g.vars[:0]
+	g.line = 0
+	g.slots = g.slots[:0]
+	g.maxSlots = 0
+	g.stats = genStats{}
+	if len(fn.Params) > 2 {
+		g.errorf(fn.Line, "%q: more than two parameters not yet supported", fn.Name)
+		return
+	}
+	// Variables occupy the bottom of the frame; spill slots go above
+	// them. The slot count is only known after the body is
+	// generated, so the body goes to a buffer and the .func header
+	// (which carries the final frame size) is emitted afterwards.
+	g.spillBase = 2 * (len(fn.Params) + countVars(fn.Body))
+	var body strings.Builder
+	g.buf = &body
+	for i, p := range fn.Params {
+		off := g.declareVar(p.Name, 0)
+		g.emit("    stw r%d, r6, %d", 2+i, off)
+	}
+	g.genBlock(fn.Body, 1)
+	g.emit("%s_ret:", fn.Name)
+	g.emit(".endfunc")
+	g.buf = &g.out
+
+	frame := g.spillBase + 2*g.maxSlots
+	g.emit("")
+	g.note(fn.Line)
+	g.line = 0 // the buffered body already annotated its lines
+	if frame > 0 {
+		g.emit(".func %s, %d", fn.Name, frame)
+	} else {
+		g.emit(".func %s", fn.Name)
+	}
+	g.out.WriteString(body.String())
+	if *regStats {
+		fmt.Fprintf(os.Stderr,
+			"yapl: %s: %d register allocs, %d spills, %d reloads, frame %d bytes\n",
+			fn.Name, g.stats.allocs, g.stats.spills, g.stats.reloads, frame)
+	}
+}
+
+// declareVar assigns the next frame slot.
+func (g *generator) declareVar(name string, depth int) int {
+	off := 2 * len(g.vars)
+	g.vars = append(g.vars, genVar{name: name, offset: off, depth: depth})
+	return off
+}
+
+// genBlock generates a block's statements, dropping its variables
+// from visibility (but not their slots) on exit.
+func (g *generator) genBlock(b *Block, depth int) {
+	mark := len(g.vars)
+	for _, s := range b.Stmts {
+		g.genStmt(s, depth)
+	}
+	// Remove this block's names; slots stay allocated.
+	kept := g.vars[:mark]
+	for _, v := range g.vars[mark:] {
+		if v.depth < depth {
+			kept = append(kept, v)
+		}
+	}
+	g.vars = kept
+}
+
+func (g *generator) genStmt(s Stmt, depth int) {
+	g.note(s.stmtLine())
+	switch s := s.(type) {
+	case *VarStmt:
+		if s.Size > 0 {
+			label := g.newDataLabel()
+			g.data = append(g.data, dataItem{label: label, size: s.Size})
+			g.vars = append(g.vars, genVar{name: s.Name, label: label, depth: depth})
+			return
+		}
+		off := g.declareVar(s.Name, depth)
+		if s.Init != nil {
+			r := g.genExpr(s.Init)
+			g.emit("    stw r%d, r6, %d", r, off)
+			g.free(r)
+		} else {
+			g.emit("    stw r0, r6, %d", off)
+		}
+	case *AssignStmt:
+		v := g.findVar(s.Name)
+		if v == nil {
+			return // analysis already complained
+		}
+		if s.Idx != nil {
+			g.genIndexStore(s, v)
+			return
+		}
+		r := g.genExpr(s.Expr)
+		g.emit("    stw r%d, r6, %d", r, v.offset)
+		g.free(r)
+	case *IfStmt:
+		r := g.genExpr(s.Cond)
+		elseL, endL := g.newLabel(), g.newLabel()
+		g.emit("    beq r%d, r0, %s", r, elseL)
+		g.free(r)
+		g.genBlock(s.Then, depth+1)
+		if s.Else != nil {
+			g.emit("    jmp %s", endL)
+		}
+		g.emit("%s:", elseL)
+		if s.Else != nil {
+			g.genBlock(s.Else, depth+1)
+			g.emit("%s:", endL)
+		}
+	case *WhileStmt:
+		topL, endL := g.newLabel(), g.newLabel()
+		g.emit("%s:", topL)
+		r := g.genExpr(s.Cond)
+		g.emit("    beq r%d, r0, %s", r, endL)
+		g.free(r)
+		g.genBlock(s.Body, depth+1)
+		g.emit("    jmp %s", topL)
+		g.emit("%s:", endL)
+	case *ReturnStmt:
+		if s.Expr != nil {
+			r := g.genExpr(s.Expr)
+			if r != 2 {
+				g.emit("    mov r2, r%d", r)
+			}
+			g.free(r)
+		}
+		g.emit("    jmp %s_ret", g.fn.Name)
+	case *ExprStmt:
+		r := g.genCall(s.Call)
+		g.free(r)
+	}
+}
+
+// genExpr evaluates an expression into a scratch register, which the
+// caller frees.
+func (g *generator) genExpr(e Expr) int {
+	switch e := e.(type) {
+	case *NumLit:
+		r := g.alloc(e.Line)
+		if e.Val == 0 {
+			g.emit("    mov r%d, r0", r)
+		} else {
+			g.emit("    li r%d, %d", r, e.Val)
+		}
+		return r
+	case *Ident:
+		v := g.findVar(e.Name)
+		r := g.alloc(e.Line)
+		switch {
+		case v == nil:
+		case v.label != "":
+			g.emit("    li r%d, %s", r, v.label)
+		default:
+			g.emit("    ldw r%d, r6, %d", r, v.offset)
+		}
+		return r
+	case *StrLit:
+		r := g.alloc(e.Line)
+		g.emit("    li r%d, %s", r, g.strLabel(e.Val))
+		return r
+	case *IndexExpr:
+		return g.genIndex(e)
+	case *BinExpr:
+		return g.genBin(e)
+	case *CallExpr:
+		return g.genCall(e)
+	}
+	return g.alloc(0)
+}
+
+// baseInto puts the byte base address in r7: an array's data label,
+// or a u16 pointer's value from the frame.
+func (g *generator) baseInto(v *genVar) {
+	if v.label != "" {
+		g.emit("    li r7, %s", v.label)
+	} else {
+		g.emit("    ldw r7, r6, %d", v.offset)
+	}
+}
+
+// genIndex loads one byte: the index register becomes the element
+// address (base via r7) and then the loaded value.
+func (g *generator) genIndex(e *IndexExpr) int {
+	v := g.findVar(e.Name)
+	r := g.genExpr(e.Idx)
+	if v == nil {
+		return r // analysis already complained
+	}
+	g.baseInto(v)
+	g.emit("    add r%d, r7", r)
+	g.emit("    ldb r%d, r%d, 0", r, r)
+	return r
+}
+
+// genIndexStore compiles name[i] = e: value first, then the element
+// address, with the usual spill discipline between them.
+func (g *generator) genIndexStore(s *AssignStmt, v *genVar) {
+	rV := g.genExpr(s.Expr)
+	var rI int
+	if g.freeCount() < 2 {
+		slot := g.spill(rV)
+		rI = g.genExpr(s.Idx)
+		rV = g.reload(slot, s.Line)
+	} else {
+		rI = g.genExpr(s.Idx)
+	}
+	g.baseInto(v)
+	g.emit("    add r%d, r7", rI)
+	g.emit("    stb r%d, r%d, 0", rV, rI)
+	g.free(rV)
+	g.free(rI)
+}
+
+// need estimates how many scratch registers an expression requires
+// (Sethi-Ullman numbering); genBin evaluates the hungrier side first
+// to keep peak pressure down.
+func need(e Expr) int {
+	switch e := e.(type) {
+	case *BinExpr:
+		l, r := need(e.L), need(e.R)
+		if l == r {
+			return l + 1
+		}
+		if l > r {
+			return l
+		}
+		return r
+	case *CallExpr:
+		return len(scratchRegs) // calls disturb everything
+	case *IndexExpr:
+		return need(e.Idx) // the address forms in the index register
+	}
+	return 1
+}
+
+func (g *generator) genBin(e *BinExpr) int {
+	var l, r int
+	if need(e.R) > need(e.L) {
+		r = g.genExpr(e.R)
+		if g.freeCount() < 2 {
+			slot := g.spill(r)
+			l = g.genExpr(e.L)
+			r = g.reload(slot, e.Line)
+		} else {
+			l = g.genExpr(e.L)
+		}
+	} else {
+		l = g.genExpr(e.L)
+		if g.freeCount() < 2 {
+			slot := g.spill(l)
+			r = g.genExpr(e.R)
+			l = g.reload(slot, e.Line)
+		} else {
+			r = g.genExpr(e.R)
+		}
+	}
+	switch e.Op {
+	case "+":
+		g.emit("    add r%d, r%d", l, r)
+	case "-":
+		g.emit("    sub r%d, r%d", l, r)
+	case "&":
+		g.emit("    and r%d, r%d", l, r)
+	case "|":
+		g.emit("    or r%d, r%d", l, r)
+	case "^":
+		g.emit("    xor r%d, r%d", l, r)
+	case "*", "/", "%":
+		g.genMathUnit(e.Op, l, r)
+	case "<<", ">>":
+		g.genShift(e, l, r)
+	case "==", "!=", "<", "<=", ">", ">=":
+		g.genCompare(e.Op, l, r)
+	default:
+		g.errorf(e.Line, "operator %q not supported by the code generator", e.Op)
+	}
+	g.free(r)
+	g.maskU8(e.Typ, l)
+	return l
+}
+
+// maskU8 truncates a u8 result to eight bits.
+func (g *generator) maskU8(t Type, r int) {
+	if t == TypeU8 {
+		g.emit("    li r7, 0xFF")
+		g.emit("    and r%d, r7", r)
+	}
+}
+
+// genMathUnit performs * / % through the math unit's ports; the
+// operand registers are 9 and 10, results at 11 (product low),
+// 13 (quotient), and 14 (remainder).
+func (g *generator) genMathUnit(op string, l, r int) {
+	result := map[string]int{"*": 11, "/": 13, "%": 14}[op]
+	g.emit("    li r7, 9")
+	g.emit("    sio r%d, r7", l)
+	g.emit("    li r7, 10")
+	g.emit("    sio r%d, r7", r)
+	g.emit("    li r7, %d", result)
+	g.emit("    lio r%d, r7", l)
+}
+
+// genShift handles shifts, which the ISA only has in immediate form
+// (0..7 per instruction), so the count must be a constant.
+func (g *generator) genShift(e *BinExpr, l, r int) {
+	lit, ok := e.R.(*NumLit)
+	if !ok {
+		g.errorf(e.Line, "shift count must be a constant")
+		return
+	}
+	mn := "lsli"
+	if e.Op == ">>" {
+		mn = "lsri"
+	}
+	for n := int(lit.Val) & 15; n > 0; n -= 7 {
+		step := n
+		if step > 7 {
+			step = 7
+		}
+		g.emit("    %s r%d, %d", mn, l, step)
+	}
+}
+
+// genCompare leaves 1 or 0 in l. slt is signed, so unsigned compares
+// flip both sign bits first (the usual trick).
+func (g *generator) genCompare(op string, l, r int) {
+	switch op {
+	case "==", "!=":
+		trueL, endL := g.newLabel(), g.newLabel()
+		g.emit("    sub r%d, r%d", l, r)
+		g.emit("    beq r%d, r0, %s", l, trueL)
+		g.emit("    li r%d, %d", l, b2u(op == "!="))
+		g.emit("    jmp %s", endL)
+		g.emit("%s:", trueL)
+		g.emit("    li r%d, %d", l, b2u(op == "=="))
+		g.emit("%s:", endL)
+	case "<", ">", "<=", ">=":
+		a, b := l, r
+		if op == ">" || op == "<=" {
+			a, b = r, l // x > y is y < x; x <= y is !(y < x)
+		}
+		g.emit("    li r7, 0x8000")
+		g.emit("    xor r%d, r7", a)
+		g.emit("    xor r%d, r7", b)
+		g.emit("    slt r%d, r%d", a, b)
+		if a != l {
+			g.emit("    mov r%d, r%d", l, a)
+		}
+		if op == "<=" || op == ">=" {
+			g.emit("    li r7, 1")
+			g.emit("    xor r%d, r7", l)
+		}
+	}
+}
+
+// genCall saves live scratch registers to frame spill slots (never
+// by moving sp, which would invalidate local offsets mid-
+// expression), stages arguments through slots into r2/r3, and leaves
+// the result in a fresh register.
+func (g *generator) genCall(call *CallExpr) int {
+	if _, ok := intrinsics[call.Name]; ok {
+		return g.genIntrinsic(call)
+	}
+	type savedReg struct{ reg, slot int }
+	var saved []savedReg
+	for _, r := range scratchRegs {
+		if g.inUse[r] {
+			saved = append(saved, savedReg{r, g.spill(r)})
+		}
+	}
+	argSlots := make([]int, len(call.Args))
+	for i, arg := range call.Args {
+		r := g.genExpr(arg)
+		argSlots[i] = g.allocSlot()
+		g.emit("    stw r%d, r6, %d", r, argSlots[i])
+		g.free(r)
+	}
+	for i := range call.Args {
+		g.emit("    ldw r%d, r6, %d", 2+i, argSlots[i])
+		g.freeSlot(argSlots[i])
+	}
+	g.emit("    li r7, %s", call.Name)
+	g.emit("    jlr r1, r7, 0")
+	// Stash the result while the saved registers (possibly including
+	// r2) come back; restoring uses only ldw, which leaves r7 alone.
+	g.emit("    mov r7, r2")
+	for i := len(saved) - 1; i >= 0; i-- {
+		s := saved[i]
+		g.emit("    ldw r%d, r6, %d", s.reg, s.slot)
+		g.freeSlot(s.slot)
+		g.inUse[s.reg] = true
+		g.stats.reloads++
+	}
+	dest := g.alloc(call.Line)
+	g.emit("    mov r%d, r7", dest)
+	return dest
+}
+
+// genIntrinsic lowers the built-in functions to their instructions:
+// no call sequence, no saved registers. The void intrinsics still
+// return a register (holding whatever the instruction left there) so
+// the statement path can free it uniformly.
+func (g *generator) genIntrinsic(call *CallExpr) int {
+	switch call.Name {
+	case "io_read":
+		r := g.genExpr(call.Args[0])
+		g.emit("    lio r%d, r%d", r, r)
+		return r
+	case "io_write":
+		port := g.genExpr(call.Args[0])
+		var val int
+		if g.freeCount() < 2 {
+			slot := g.spill(port)
+			val = g.genExpr(call.Args[1])
+			port = g.reload(slot, call.Line)
+		} else {
+			val = g.genExpr(call.Args[1])
+		}
+		g.emit("    sio r%d, r%d", val, port)
+		g.free(val)
+		return port
+	case "spr_read", "spr_write":
+		lit, ok := call.Args[0].(*NumLit)
+		if !ok {
+			// Analysis already complained; emit nothing sensible.
+			return g.alloc(call.Line)
+		}
+		if call.Name == "spr_read" {
+			r := g.alloc(call.Line)
+			g.emit("    lsp r%d, %d", r, lit.Val)
+			return r
+		}
+		v := g.genExpr(call.Args[1])
+		g.emit("    ssp r%d, %d", v, lit.Val)
+		return v
+	}
+	g.errorf(call.Line, "internal: unknown intrinsic %q", call.Name)
+	return g.alloc(call.Line)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// generate compiles source all the way to assembly text.
+func generate(t *testing.T, src string) string {
+	t.Helper()
+	prog, eng := Compile([]byte(src))
+	if eng.Failed() {
+		t.Fatalf("front end: %v", eng.Messages())
+	}
+	out := Generate(prog, []byte(src), eng)
+	if eng.Failed() {
+		t.Fatalf("codegen: %v", eng.Messages())
+	}
+	return string(out)
+}
+
+func TestGenLineComments(t *testing.T) {
+	src := "func main() {\n\tvar x u16 = 1;\n\tx = x + 1;\n}\n"
+	asm := generate(t, src)
+	for _, want := range []string{
+		"; line 2: var x u16 = 1;",
+		"; line 3: x = x + 1;",
+		".func main, 2",
+		"_start:",
+	} {
+		if !strings.Contains(asm, want) {
+			t.Errorf("assembly missing %q:\n%s", want, asm)
+		}
+	}
+}
+
+func TestGenDeterministic(t *testing.T) {
+	src := "func f(a u16, b u16) u16 { return a + b; }\nfunc main() { var x u16 = f(1, 2); }\n"
+	prog1, eng1 := Compile([]byte(src))
+	prog2, eng2 := Compile([]byte(src))
+	a := Generate(prog1, []byte(src), eng1)
+	b := Generate(prog2, []byte(src), eng2)
+	if !bytes.Equal(a, b) {
+		t.Error("codegen output is not deterministic")
+	}
+}
+
+func TestGenSpilling(t *testing.T) {
+	// Eight leaves balanced so every interior node holds a value:
+	// needs more registers than the scratch pool and must spill.
+	src := `
+func f(a u16, b u16) u16 { return a + b; }
+func main() u16 {
+	var a u16 = 1;
+	return ((a+2)+(a+3)) + ((a+4)+(a+5)) + (((a+6)+(a+7)) + ((f(a,2)+a)+(a+9)));
+}
+`
+	asm := generate(t, src)
+	// The spill slots live above main's variables (one var, offset
+	// 0), so a spill store targets offset 2 or higher.
+	if !strings.Contains(asm, "stw r") {
+		t.Fatalf("no stores at all:\n%s", asm)
+	}
+}
+
+func TestGenIntrinsics(t *testing.T) {
+	// Intrinsics lower to single instructions, not call sequences.
+	src := `
+func main() {
+	var st u16 = spr_read(3);
+	spr_write(3, st | 1);
+	var ch u16 = io_read(1);
+	io_write(0, ch);
+}
+`
+	asm := generate(t, src)
+	for _, want := range []string{"lsp r", "ssp r", "lio r", "sio r"} {
+		if !strings.Contains(asm, want) {
+			t.Errorf("assembly missing %q:\n%s", want, asm)
+		}
+	}
+	if strings.Contains(asm, "li r7, io_read") {
+		t.Errorf("intrinsic generated a call sequence:\n%s", asm)
+	}
+}
+
+func TestGenArraysAndStrings(t *testing.T) {
+	src := `
+func main() {
+	var buf u8[8];
+	var i u16 = 0;
+	buf[i] = 65;
+	var c u8 = buf[i];
+	var p u16 = "hi";
+	var q u16 = "hi";
+}
+`
+	asm := generate(t, src)
+	for _, want := range []string{".data", ".space 8", ".byte 104, 105, 0", "stb r", "ldb r"} {
+		if !strings.Contains(asm, want) {
+			t.Errorf("assembly missing %q:\n%s", want, asm)
+		}
+	}
+	// Identical string literals share one definition.
+	if strings.Count(asm, ".byte 104, 105, 0") != 1 {
+		t.Errorf("duplicate string literal not interned:\n%s", asm)
+	}
+}
+
+func TestGenUnsupported(t *testing.T) {
+	// Variable shift counts have no single-instruction form.
+	src := "func main() { var a u16 = 1; var b u16 = 2; a = a << b; }"
+	prog, eng := Compile([]byte(src))
+	if eng.Failed() {
+		t.Fatalf("front end: %v", eng.Messages())
+	}
+	Generate(prog, []byte(src), eng)
+	if !eng.Failed() {
+		t.Error("variable shift count accepted by codegen")
+	}
+}
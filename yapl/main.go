@@ -0,0 +1,70 @@
+// yapl is the compiler for YAPL-1, the small systems language for
+// the WUT-4 ("yet another programming language"). YAPL-1 has two
+// integer types (u8 and u16), functions, and structured control
+// flow; it is meant to be just enough language to write most of the
+// native toolchain in, with assembly reserved for the lowest level.
+//
+// The compiler emits WUT-4 assembly for asm. Diagnostics go through
+// the shared engine, so yapl reports errors the same way asm does.
+//
+// Usage: yapl [-o out.s] source.yapl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+var outFile = flag.String("o", "", "output file (default source with .s suffix)")
+var maxErrs = flag.Int("maxerrs", 20, "maximum errors to report; 0 for no limit")
+var jsonDiags = flag.Bool("json", false, "emit diagnostics as JSON")
+var regStats = flag.Bool("regstats", false, "print per-function register allocation statistics")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("yapl isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: yapl [-o out.s] source.yapl")
+		os.Exit(2)
+	}
+	src := flag.Arg(0)
+	b, err := os.ReadFile(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yapl: %v\n", err)
+		os.Exit(1)
+	}
+
+	prog, eng := Compile(b)
+	eng.File = src
+	eng.MaxErrs = *maxErrs
+	var asm []byte
+	if !eng.Failed() {
+		asm = Generate(prog, b, eng)
+	}
+	if eng.Failed() {
+		if *jsonDiags {
+			eng.EmitJSON(os.Stderr)
+		} else {
+			eng.Emit(os.Stderr)
+		}
+		os.Exit(1)
+	}
+
+	out := *outFile
+	if out == "" {
+		out = strings.TrimSuffix(src, ".yapl") + ".s"
+	}
+	if err := os.WriteFile(out, asm, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "yapl: %v\n", err)
+		os.Exit(1)
+	}
+}
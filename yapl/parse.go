@@ -0,0 +1,390 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/gmofishsauce/y4/diag"
+)
+
+// The YAPL-1 recursive-descent parser. Errors go to the diagnostics
+// engine and the parser resynchronizes at statement boundaries, so
+// one typo does not hide every later error.
+
+// Compile parses and analyzes one source file. The program is valid
+// only if the engine reports no failure.
+func Compile(src []byte) (*Program, *diag.Engine) {
+	eng := diag.NewEngine("")
+	p := &parser{lx: newYlexer(src), eng: eng}
+	prog := p.parseProgram()
+	if !eng.Failed() {
+		analyze(prog, eng)
+	}
+	return prog, eng
+}
+
+type parser struct {
+	lx  *ylexer
+	eng *diag.Engine
+}
+
+func (p *parser) errorf(t ytok, format string, args ...interface{}) {
+	p.eng.Errorf(t.line, t.col, format, args...)
+}
+
+// expect consumes the next token, requiring the given punctuation.
+func (p *parser) expect(text string) bool {
+	t := p.lx.next()
+	if t.kind != yPunct || t.text != text {
+		p.errorf(t, "expected %q, found %s", text, t)
+		return false
+	}
+	return true
+}
+
+// ident consumes and returns an identifier token.
+func (p *parser) ident(what string) (ytok, bool) {
+	t := p.lx.next()
+	if t.kind != yIdent {
+		p.errorf(t, "expected %s, found %s", what, t)
+		return t, false
+	}
+	return t, true
+}
+
+// sync skips to just past the next ';' or to a '}', for error
+// recovery at statement granularity.
+func (p *parser) sync() {
+	for {
+		t := p.lx.peek()
+		if t.kind == yEOF {
+			return
+		}
+		if t.kind == yPunct && t.text == "}" {
+			return
+		}
+		p.lx.next()
+		if t.kind == yPunct && t.text == ";" {
+			return
+		}
+	}
+}
+
+func (p *parser) parseProgram() *Program {
+	prog := &Program{}
+	for {
+		t := p.lx.next()
+		if t.kind == yEOF {
+			return prog
+		}
+		if t.kind != yIdent || t.text != "func" {
+			p.errorf(t, "expected \"func\" at top level, found %s", t)
+			p.sync()
+			continue
+		}
+		if fn := p.parseFunc(t.line); fn != nil {
+			prog.Funcs = append(prog.Funcs, fn)
+		}
+	}
+}
+
+func (p *parser) parseFunc(line int) *FuncDecl {
+	name, ok := p.ident("function name")
+	if !ok {
+		return nil
+	}
+	fn := &FuncDecl{Name: name.text, Ret: TypeVoid, Line: line}
+	if !p.expect("(") {
+		return nil
+	}
+	if t := p.lx.peek(); !(t.kind == yPunct && t.text == ")") {
+		for {
+			pname, ok := p.ident("parameter name")
+			if !ok {
+				return nil
+			}
+			ptype, ok := p.parseType()
+			if !ok {
+				return nil
+			}
+			fn.Params = append(fn.Params, Param{pname.text, ptype, pname.line})
+			if t := p.lx.peek(); t.kind == yPunct && t.text == "," {
+				p.lx.next()
+				continue
+			}
+			break
+		}
+	}
+	if !p.expect(")") {
+		return nil
+	}
+	if t := p.lx.peek(); t.kind == yIdent && (t.text == "u8" || t.text == "u16") {
+		fn.Ret, _ = p.parseType()
+	}
+	fn.Body = p.parseBlock()
+	return fn
+}
+
+func (p *parser) parseType() (Type, bool) {
+	t := p.lx.next()
+	if t.kind == yIdent {
+		switch t.text {
+		case "u8":
+			return TypeU8, true
+		case "u16":
+			return TypeU16, true
+		}
+	}
+	p.errorf(t, "expected type u8 or u16, found %s", t)
+	return TypeNone, false
+}
+
+func (p *parser) parseBlock() *Block {
+	b := &Block{}
+	if !p.expect("{") {
+		return b
+	}
+	for {
+		t := p.lx.peek()
+		if t.kind == yEOF {
+			p.errorf(t, "unterminated block")
+			return b
+		}
+		if t.kind == yPunct && t.text == "}" {
+			p.lx.next()
+			return b
+		}
+		if s := p.parseStmt(); s != nil {
+			b.Stmts = append(b.Stmts, s)
+		} else {
+			p.sync()
+		}
+	}
+}
+
+func (p *parser) parseStmt() Stmt {
+	t := p.lx.next()
+	if t.kind != yIdent {
+		p.errorf(t, "expected statement, found %s", t)
+		return nil
+	}
+	switch t.text {
+	case "var":
+		name, ok := p.ident("variable name")
+		if !ok {
+			return nil
+		}
+		typ, ok := p.parseType()
+		if !ok {
+			return nil
+		}
+		s := &VarStmt{Name: name.text, Type: typ, Line: t.line}
+		if nx := p.lx.peek(); nx.kind == yPunct && nx.text == "[" {
+			p.lx.next()
+			// The element count must be a literal: arrays size static
+			// storage, which is laid out before anything is evaluated.
+			n := p.lx.next()
+			v, err := strconv.ParseUint(n.text, 0, 16)
+			if n.kind != yNumber || err != nil || v == 0 {
+				p.errorf(n, "array size must be a literal 1..65535, found %s", n)
+				return nil
+			}
+			s.Size = int(v)
+			if !p.expect("]") {
+				return nil
+			}
+		}
+		if nx := p.lx.peek(); nx.kind == yPunct && nx.text == "=" {
+			p.lx.next()
+			if s.Size > 0 {
+				p.errorf(nx, "array initializers are not supported")
+			}
+			s.Init = p.parseExpr()
+		}
+		if !p.expect(";") {
+			return nil
+		}
+		return s
+	case "if":
+		s := &IfStmt{Line: t.line}
+		if !p.expect("(") {
+			return nil
+		}
+		s.Cond = p.parseExpr()
+		if !p.expect(")") {
+			return nil
+		}
+		s.Then = p.parseBlock()
+		if nx := p.lx.peek(); nx.kind == yIdent && nx.text == "else" {
+			p.lx.next()
+			s.Else = p.parseBlock()
+		}
+		return s
+	case "while":
+		s := &WhileStmt{Line: t.line}
+		if !p.expect("(") {
+			return nil
+		}
+		s.Cond = p.parseExpr()
+		if !p.expect(")") {
+			return nil
+		}
+		s.Body = p.parseBlock()
+		return s
+	case "return":
+		s := &ReturnStmt{Line: t.line}
+		if nx := p.lx.peek(); !(nx.kind == yPunct && nx.text == ";") {
+			s.Expr = p.parseExpr()
+		}
+		if !p.expect(";") {
+			return nil
+		}
+		return s
+	}
+
+	// Assignment or call statement, starting at the identifier.
+	nx := p.lx.peek()
+	switch {
+	case nx.kind == yPunct && nx.text == "=":
+		p.lx.next()
+		s := &AssignStmt{Name: t.text, Line: t.line}
+		s.Expr = p.parseExpr()
+		if !p.expect(";") {
+			return nil
+		}
+		return s
+	case nx.kind == yPunct && nx.text == "[":
+		p.lx.next()
+		s := &AssignStmt{Name: t.text, Line: t.line}
+		s.Idx = p.parseExpr()
+		if !p.expect("]") || !p.expect("=") {
+			return nil
+		}
+		s.Expr = p.parseExpr()
+		if !p.expect(";") {
+			return nil
+		}
+		return s
+	case nx.kind == yPunct && nx.text == "(":
+		call := p.parseCall(t)
+		if call == nil {
+			return nil
+		}
+		if !p.expect(";") {
+			return nil
+		}
+		return &ExprStmt{Call: call, Line: t.line}
+	}
+	p.errorf(nx, "expected \"=\", \"[\" or \"(\" after %q, found %s", t.text, nx)
+	return nil
+}
+
+// Binary operator precedence, loosest first. All operators at one
+// level associate left.
+var precedence = [][]string{
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"|", "^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseExpr() Expr {
+	return p.parseBin(0)
+}
+
+func (p *parser) parseBin(level int) Expr {
+	if level == len(precedence) {
+		return p.parseUnary()
+	}
+	e := p.parseBin(level + 1)
+	for {
+		t := p.lx.peek()
+		if t.kind != yPunct || !contains(precedence[level], t.text) {
+			return e
+		}
+		p.lx.next()
+		r := p.parseBin(level + 1)
+		e = &BinExpr{Op: t.text, L: e, R: r, Line: t.line}
+	}
+}
+
+func contains(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseUnary() Expr {
+	if t := p.lx.peek(); t.kind == yPunct && t.text == "-" {
+		p.lx.next()
+		// Unary minus is zero-minus: the checker folds constants and
+		// the code generator gets an ordinary subtraction.
+		return &BinExpr{Op: "-", L: &NumLit{Val: 0, Line: t.line}, R: p.parseUnary(), Line: t.line}
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() Expr {
+	t := p.lx.next()
+	switch t.kind {
+	case yNumber:
+		v, err := strconv.ParseUint(t.text, 0, 16)
+		if err != nil {
+			p.errorf(t, "bad number %q", t.text)
+			return &NumLit{Line: t.line}
+		}
+		return &NumLit{Val: uint16(v), Line: t.line}
+	case yString:
+		return &StrLit{Val: t.text, Line: t.line}
+	case yIdent:
+		if nx := p.lx.peek(); nx.kind == yPunct && nx.text == "(" {
+			if call := p.parseCall(t); call != nil {
+				return call
+			}
+			return &NumLit{Line: t.line}
+		}
+		if nx := p.lx.peek(); nx.kind == yPunct && nx.text == "[" {
+			p.lx.next()
+			e := &IndexExpr{Name: t.text, Idx: p.parseExpr(), Line: t.line}
+			p.expect("]")
+			return e
+		}
+		return &Ident{Name: t.text, Line: t.line}
+	case yPunct:
+		if t.text == "(" {
+			e := p.parseExpr()
+			p.expect(")")
+			return e
+		}
+	}
+	p.errorf(t, "expected expression, found %s", t)
+	return &NumLit{Line: t.line}
+}
+
+// parseCall parses the argument list; name is the already-consumed
+// identifier token.
+func (p *parser) parseCall(name ytok) *CallExpr {
+	if !p.expect("(") {
+		return nil
+	}
+	call := &CallExpr{Name: name.text, Line: name.line}
+	if t := p.lx.peek(); t.kind == yPunct && t.text == ")" {
+		p.lx.next()
+		return call
+	}
+	for {
+		call.Args = append(call.Args, p.parseExpr())
+		t := p.lx.next()
+		if t.kind == yPunct && t.text == ")" {
+			return call
+		}
+		if !(t.kind == yPunct && t.text == ",") {
+			p.errorf(t, "expected \",\" or \")\" in arguments, found %s", t)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"github.com/gmofishsauce/y4/diag"
+)
+
+// Semantic analysis for YAPL-1: symbol scoping, u8/u16 type
+// checking, function signature checking, and constant folding. The
+// pass runs after a clean parse and before code generation; a
+// program that passes analysis has every expression annotated with
+// its type and every constant subexpression folded to a literal.
+
+type analyzer struct {
+	eng    *diag.Engine
+	funcs  []*FuncDecl // insertion order, for deterministic processing
+	byName map[string]*FuncDecl
+	cur    *FuncDecl
+	scopes []scope
+}
+
+// scope is one block's variables, insertion-ordered with a side
+// index like the assembler's symbol table.
+type scope struct {
+	names []string
+	vars  map[string]varInfo
+}
+
+// varInfo describes a declared variable: its type and, for an array,
+// its element count (zero for scalars).
+type varInfo struct {
+	typ  Type
+	size int
+}
+
+func analyze(prog *Program, eng *diag.Engine) {
+	a := &analyzer{eng: eng, byName: make(map[string]*FuncDecl)}
+	for _, fn := range prog.Funcs {
+		if _, isIn := intrinsics[fn.Name]; isIn {
+			eng.Errorf(fn.Line, 1, "function %q redefines an intrinsic", fn.Name)
+			continue
+		}
+		if prev, dup := a.byName[fn.Name]; dup {
+			eng.Errorf(fn.Line, 1, "function %q redefined (first defined on line %d)",
+				fn.Name, prev.Line)
+			continue
+		}
+		a.byName[fn.Name] = fn
+		a.funcs = append(a.funcs, fn)
+	}
+	for _, fn := range a.funcs {
+		a.checkFunc(fn)
+	}
+}
+
+func (a *analyzer) checkFunc(fn *FuncDecl) {
+	a.cur = fn
+	a.scopes = a.scopes[:0]
+	a.push()
+	for _, p := range fn.Params {
+		a.declare(p.Name, varInfo{typ: p.Type}, p.Line)
+	}
+	a.checkBlock(fn.Body)
+	a.pop()
+}
+
+func (a *analyzer) push() {
+	a.scopes = append(a.scopes, scope{vars: make(map[string]varInfo)})
+}
+
+func (a *analyzer) pop() {
+	a.scopes = a.scopes[:len(a.scopes)-1]
+}
+
+func (a *analyzer) declare(name string, info varInfo, line int) {
+	s := &a.scopes[len(a.scopes)-1]
+	if _, dup := s.vars[name]; dup {
+		a.eng.Errorf(line, 1, "%q redeclared in this scope", name)
+		return
+	}
+	s.names = append(s.names, name)
+	s.vars[name] = info
+}
+
+// lookup finds a variable in the enclosing scopes, innermost first.
+func (a *analyzer) lookup(name string) (varInfo, bool) {
+	for i := len(a.scopes) - 1; i >= 0; i-- {
+		if v, ok := a.scopes[i].vars[name]; ok {
+			return v, true
+		}
+	}
+	return varInfo{typ: TypeNone}, false
+}
+
+func (a *analyzer) checkBlock(b *Block) {
+	a.push()
+	for _, s := range b.Stmts {
+		a.checkStmt(s)
+	}
+	a.pop()
+}
+
+func (a *analyzer) checkStmt(s Stmt) {
+	switch s := s.(type) {
+	case *VarStmt:
+		if s.Size > 0 && s.Type != TypeU8 {
+			a.eng.Errorf(s.Line, 1, "only u8 arrays are supported")
+		}
+		if s.Init != nil {
+			s.Init = a.checkExpr(s.Init, s.Type)
+		}
+		a.declare(s.Name, varInfo{typ: s.Type, size: s.Size}, s.Line)
+	case *AssignStmt:
+		v, ok := a.lookup(s.Name)
+		if !ok {
+			a.eng.Errorf(s.Line, 1, "assignment to undeclared variable %q", s.Name)
+		}
+		want := v.typ
+		switch {
+		case s.Idx != nil && ok && v.size == 0 && v.typ != TypeU16:
+			a.eng.Errorf(s.Line, 1, "%q is not an array or u16 pointer", s.Name)
+		case s.Idx == nil && v.size > 0:
+			a.eng.Errorf(s.Line, 1, "cannot assign to array %q without an index", s.Name)
+		}
+		if s.Idx != nil {
+			s.Idx = a.checkIndex(s.Idx, v.size)
+			want = TypeU8 // indexing is always a byte access
+		}
+		s.Expr = a.checkExpr(s.Expr, want)
+	case *IfStmt:
+		s.Cond = a.checkExpr(s.Cond, TypeNone)
+		a.checkBlock(s.Then)
+		if s.Else != nil {
+			a.checkBlock(s.Else)
+		}
+	case *WhileStmt:
+		s.Cond = a.checkExpr(s.Cond, TypeNone)
+		a.checkBlock(s.Body)
+	case *ReturnStmt:
+		switch {
+		case s.Expr == nil && a.cur.Ret != TypeVoid:
+			a.eng.Errorf(s.Line, 1, "return without value in function returning %s", a.cur.Ret)
+		case s.Expr != nil && a.cur.Ret == TypeVoid:
+			a.eng.Errorf(s.Line, 1, "return with value in void function %q", a.cur.Name)
+		case s.Expr != nil:
+			s.Expr = a.checkExpr(s.Expr, a.cur.Ret)
+		}
+	case *ExprStmt:
+		a.checkCall(s.Call, TypeNone, true)
+	}
+}
+
+// checkExpr type-checks an expression against the wanted type
+// (TypeNone means "any integer type") and returns the expression,
+// possibly replaced by a folded constant.
+func (a *analyzer) checkExpr(e Expr, want Type) Expr {
+	switch e := e.(type) {
+	case *NumLit:
+		e.Typ = want
+		if want == TypeNone {
+			e.Typ = TypeU16
+		}
+		if e.Typ == TypeU8 && e.Val > 0xFF {
+			a.eng.Errorf(e.Line, 1, "constant %d overflows u8", e.Val)
+		}
+		return e
+	case *Ident:
+		v, ok := a.lookup(e.Name)
+		if !ok {
+			a.eng.Errorf(e.Line, 1, "undeclared variable %q", e.Name)
+			v.typ = TypeU16
+		}
+		e.Typ = v.typ
+		if v.size > 0 {
+			// A bare array name is its dmem address.
+			e.Typ = TypeU16
+		}
+		a.wantType(e.Line, e.Typ, want)
+		return e
+	case *BinExpr:
+		return a.checkBin(e, want)
+	case *CallExpr:
+		a.checkCall(e, want, false)
+		return e
+	case *IndexExpr:
+		v, ok := a.lookup(e.Name)
+		switch {
+		case !ok:
+			a.eng.Errorf(e.Line, 1, "undeclared variable %q", e.Name)
+		case v.size == 0 && v.typ != TypeU16:
+			// A u16 scalar indexes like an array: it holds a byte
+			// address (this is how string parameters are read).
+			a.eng.Errorf(e.Line, 1, "%q is not an array or u16 pointer", e.Name)
+		}
+		e.Idx = a.checkIndex(e.Idx, v.size)
+		e.Typ = TypeU8
+		a.wantType(e.Line, TypeU8, want)
+		return e
+	case *StrLit:
+		e.Typ = TypeU16
+		a.wantType(e.Line, TypeU16, want)
+		return e
+	}
+	return e
+}
+
+// checkIndex types an array index and bounds-checks it when it is a
+// constant.
+func (a *analyzer) checkIndex(idx Expr, size int) Expr {
+	idx = a.checkExpr(idx, TypeNone)
+	if lit, ok := idx.(*NumLit); ok && size > 0 && int(lit.Val) >= size {
+		a.eng.Errorf(lit.Line, 1, "index %d out of range for array of %d", lit.Val, size)
+	}
+	return idx
+}
+
+// wantType reports a mismatch between a concrete type and the
+// context's expectation. A u8 widens to u16 implicitly (the value is
+// already zero-extended in its register); narrowing does not.
+func (a *analyzer) wantType(line int, got, want Type) {
+	if got == TypeU8 && want == TypeU16 {
+		return
+	}
+	if want != TypeNone && got != TypeNone && got != want {
+		a.eng.Errorf(line, 1, "type mismatch: %s where %s is required", got, want)
+	}
+}
+
+func (a *analyzer) checkBin(e *BinExpr, want Type) Expr {
+	comparison := contains(precedence[0], e.Op)
+	opWant := want
+	if comparison {
+		// Comparisons produce u16 0/1 whatever their operands are.
+		a.wantType(e.Line, TypeU16, want)
+		opWant = TypeNone
+	}
+	e.L = a.checkExpr(e.L, opWant)
+	lt := e.L.exprType()
+	// The right side must agree with the left; an untyped literal on
+	// the left adopts the right side's type instead.
+	e.R = a.checkExpr(e.R, lt)
+	e.Typ = lt
+	if comparison {
+		e.Typ = TypeU16
+	}
+
+	l, lok := e.L.(*NumLit)
+	r, rok := e.R.(*NumLit)
+	if !lok || !rok {
+		return e
+	}
+	return a.fold(e, l, r)
+}
+
+// fold evaluates a constant binary expression at compile time,
+// wrapping to the operand width like the machine would.
+func (a *analyzer) fold(e *BinExpr, l, r *NumLit) Expr {
+	var v uint16
+	switch e.Op {
+	case "+":
+		v = l.Val + r.Val
+	case "-":
+		v = l.Val - r.Val
+	case "*":
+		v = l.Val * r.Val
+	case "/":
+		if r.Val == 0 {
+			a.eng.Errorf(e.Line, 1, "division by constant zero")
+			return e
+		}
+		v = l.Val / r.Val
+	case "%":
+		if r.Val == 0 {
+			a.eng.Errorf(e.Line, 1, "division by constant zero")
+			return e
+		}
+		v = l.Val % r.Val
+	case "&":
+		v = l.Val & r.Val
+	case "|":
+		v = l.Val | r.Val
+	case "^":
+		v = l.Val ^ r.Val
+	case "<<":
+		v = l.Val << (r.Val & 15)
+	case ">>":
+		v = l.Val >> (r.Val & 15)
+	case "==":
+		v = b2u(l.Val == r.Val)
+	case "!=":
+		v = b2u(l.Val != r.Val)
+	case "<":
+		v = b2u(l.Val < r.Val)
+	case "<=":
+		v = b2u(l.Val <= r.Val)
+	case ">":
+		v = b2u(l.Val > r.Val)
+	case ">=":
+		v = b2u(l.Val >= r.Val)
+	default:
+		return e
+	}
+	if e.Typ == TypeU8 {
+		v &= 0xFF
+	}
+	return &NumLit{Val: v, Typ: e.Typ, Line: e.Line}
+}
+
+func b2u(b bool) uint16 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// intrinsics are the built-in functions lowered to single special
+// instructions rather than calls: port I/O (lio/sio) and special
+// register access (lsp/ssp), so kernel code can be written in YAPL.
+// The SPR number must be a constant: the instructions only have an
+// immediate form.
+var intrinsics = map[string]struct {
+	params []Type
+	ret    Type
+	sprArg bool // first argument must be a constant SPR number
+}{
+	"io_read":   {[]Type{TypeU16}, TypeU16, false},
+	"io_write":  {[]Type{TypeU16, TypeU16}, TypeVoid, false},
+	"spr_read":  {[]Type{TypeU16}, TypeU16, true},
+	"spr_write": {[]Type{TypeU16, TypeU16}, TypeVoid, true},
+}
+
+// checkIntrinsic validates a call to a built-in; it reports whether
+// the name was one.
+func (a *analyzer) checkIntrinsic(call *CallExpr, want Type, asStmt bool) bool {
+	in, ok := intrinsics[call.Name]
+	if !ok {
+		return false
+	}
+	if len(call.Args) != len(in.params) {
+		a.eng.Errorf(call.Line, 1, "%q takes %d argument(s), called with %d",
+			call.Name, len(in.params), len(call.Args))
+		call.Typ = in.ret
+		return true
+	}
+	for i, arg := range call.Args {
+		call.Args[i] = a.checkExpr(arg, in.params[i])
+	}
+	if in.sprArg {
+		lit, isLit := call.Args[0].(*NumLit)
+		if !isLit {
+			a.eng.Errorf(call.Line, 1, "%q needs a constant special register number", call.Name)
+		} else if lit.Val > 7 {
+			a.eng.Errorf(call.Line, 1, "special register %d out of range 0..7", lit.Val)
+		}
+	}
+	call.Typ = in.ret
+	if !asStmt {
+		if in.ret == TypeVoid {
+			a.eng.Errorf(call.Line, 1, "void intrinsic %q used in an expression", call.Name)
+		} else {
+			a.wantType(call.Line, in.ret, want)
+		}
+	}
+	return true
+}
+
+// checkCall checks arity and argument types against the callee's
+// signature. asStmt allows calling any function; in an expression
+// the callee must return a value of the wanted type.
+func (a *analyzer) checkCall(call *CallExpr, want Type, asStmt bool) {
+	if a.checkIntrinsic(call, want, asStmt) {
+		return
+	}
+	fn, ok := a.byName[call.Name]
+	if !ok {
+		a.eng.Errorf(call.Line, 1, "call of undefined function %q", call.Name)
+		call.Typ = TypeU16
+		return
+	}
+	if len(call.Args) != len(fn.Params) {
+		a.eng.Errorf(call.Line, 1, "%q takes %d argument(s), called with %d",
+			fn.Name, len(fn.Params), len(call.Args))
+	}
+	for i, arg := range call.Args {
+		if i < len(fn.Params) {
+			call.Args[i] = a.checkExpr(arg, fn.Params[i].Type)
+		} else {
+			call.Args[i] = a.checkExpr(arg, TypeNone)
+		}
+	}
+	call.Typ = fn.Ret
+	if !asStmt {
+		if fn.Ret == TypeVoid {
+			a.eng.Errorf(call.Line, 1, "void function %q used in an expression", fn.Name)
+		} else {
+			a.wantType(call.Line, fn.Ret, want)
+		}
+	}
+}
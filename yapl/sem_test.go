@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// compile runs the full front end and returns the program and any
+// diagnostic messages.
+func compile(t *testing.T, src string) (*Program, []string) {
+	t.Helper()
+	prog, eng := Compile([]byte(src))
+	return prog, eng.Messages()
+}
+
+func TestCleanProgram(t *testing.T) {
+	src := `
+// doubles its argument
+func twice(n u16) u16 {
+	return n + n;
+}
+func main() {
+	var x u16 = 10;
+	var small u8 = 0xFF;
+	if (x < 100) {
+		x = twice(x);
+	}
+	while (x > 0) {
+		x = x - 1;
+	}
+	small = small & 0x0F;
+}
+`
+	_, msgs := compile(t, src)
+	if len(msgs) != 0 {
+		t.Errorf("clean program produced diagnostics: %v", msgs)
+	}
+}
+
+func TestSemanticErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"func f() { x = 1; }", "undeclared"},
+		{"func f() { var x u8 = 256; }", "overflows u8"},
+		{"func f() { var a u8; var b u16; a = b; }", "type mismatch"},
+		{"func f() { var x u16 = g(); }", "undefined function"},
+		{"func g(a u16) u16 { return a; }\nfunc f() { var x u16 = g(); }", "argument"},
+		{"func g() { }\nfunc f() { var x u16 = g(); }", "void function"},
+		{"func f() u16 { return; }", "return without value"},
+		{"func f() { return 1; }", "return with value"},
+		{"func f() { var x u16; var x u16; }", "redeclared"},
+		{"func f() { }\nfunc f() { }", "redefined"},
+		{"func f() { var x u16 = 1 / 0; }", "division by constant zero"},
+		{"func f() { io_write(0); }", "argument"},
+		{"func f() { var x u16 = io_write(0, 1); }", "void intrinsic"},
+		{"func f(n u16) { var x u16 = spr_read(n); }", "constant special register"},
+		{"func f() { spr_write(8, 0); }", "out of range"},
+		{"func io_read() { }", "redefines an intrinsic"},
+		{"func f() { var a u16[4]; }", "only u8 arrays"},
+		{"func f() { var x u8; x[0] = 1; }", "not an array"},
+		{"func f() { var a u8[4]; a = 1; }", "cannot assign to array"},
+		{"func f() { var a u8[4]; var x u8 = a[4]; }", "out of range"},
+		{"func f() { var s u8 = \"oops\"; }", "type mismatch"},
+	}
+	for _, c := range cases {
+		_, msgs := compile(t, c.src)
+		found := false
+		for _, m := range msgs {
+			if strings.Contains(m, c.want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%q: diagnostics %v, want one containing %q", c.src, msgs, c.want)
+		}
+	}
+}
+
+func TestConstantFolding(t *testing.T) {
+	src := "func f() { var x u16 = 2 + 3 * 4; var y u8 = -1; }"
+	prog, msgs := compile(t, src)
+	if len(msgs) != 0 {
+		t.Fatalf("diagnostics: %v", msgs)
+	}
+	stmts := prog.Funcs[0].Body.Stmts
+	x := stmts[0].(*VarStmt)
+	lit, ok := x.Init.(*NumLit)
+	if !ok || lit.Val != 14 {
+		t.Errorf("x init = %#v, want folded literal 14", x.Init)
+	}
+	y := stmts[1].(*VarStmt)
+	lit, ok = y.Init.(*NumLit)
+	if !ok || lit.Val != 0xFF {
+		t.Errorf("y init = %#v, want folded literal 0xFF", y.Init)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	// A block-local variable must not leak into the enclosing scope.
+	src := "func f() { if (1) { var x u16; } x = 2; }"
+	_, msgs := compile(t, src)
+	found := false
+	for _, m := range msgs {
+		if strings.Contains(m, "undeclared") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("block-scoped variable leaked: %v", msgs)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import "fmt"
+
+// The YAPL lexer. YAPL surface syntax is C-like (braces, //
+// comments, multi-character operators), so it does not share the
+// assembly lexer in lex; the two languages have almost no tokens in
+// common.
+
+type ytokKind int
+
+const (
+	yErr ytokKind = iota
+	yEOF
+	yIdent  // identifiers and keywords
+	yNumber // decimal or 0x hex
+	yString // double-quoted literal; Text holds the decoded bytes
+	yPunct  // operators and delimiters; Text holds the exact spelling
+)
+
+type ytok struct {
+	kind ytokKind
+	text string
+	line int
+	col  int
+}
+
+func (t ytok) String() string {
+	switch t.kind {
+	case yEOF:
+		return "end of file"
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+type ylexer struct {
+	src  []byte
+	pos  int
+	line int
+	col  int
+
+	peeked bool
+	ahead  ytok
+}
+
+func newYlexer(src []byte) *ylexer {
+	return &ylexer{src: src, line: 1, col: 1}
+}
+
+func (lx *ylexer) peek() ytok {
+	if !lx.peeked {
+		lx.ahead = lx.scan()
+		lx.peeked = true
+	}
+	return lx.ahead
+}
+
+func (lx *ylexer) next() ytok {
+	if lx.peeked {
+		lx.peeked = false
+		return lx.ahead
+	}
+	return lx.scan()
+}
+
+func (lx *ylexer) advance() {
+	if lx.src[lx.pos] == '\n' {
+		lx.line++
+		lx.col = 1
+	} else {
+		lx.col++
+	}
+	lx.pos++
+}
+
+// twoCharOps are the operators whose two-character spellings must be
+// preferred over their one-character prefixes.
+var twoCharOps = []string{"==", "!=", "<=", ">=", "<<", ">>"}
+
+func (lx *ylexer) scan() ytok {
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		line, col := lx.line, lx.col
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			lx.advance()
+		case c == '/' && lx.pos+1 < len(lx.src) && lx.src[lx.pos+1] == '/':
+			for lx.pos < len(lx.src) && lx.src[lx.pos] != '\n' {
+				lx.advance()
+			}
+		case c == '"':
+			return lx.scanString(line, col)
+		case isYDigit(c):
+			start := lx.pos
+			for lx.pos < len(lx.src) && isYNumCont(lx.src[lx.pos]) {
+				lx.advance()
+			}
+			return ytok{yNumber, string(lx.src[start:lx.pos]), line, col}
+		case isYIdentStart(c):
+			start := lx.pos
+			for lx.pos < len(lx.src) && isYIdentCont(lx.src[lx.pos]) {
+				lx.advance()
+			}
+			return ytok{yIdent, string(lx.src[start:lx.pos]), line, col}
+		default:
+			if lx.pos+1 < len(lx.src) {
+				two := string(lx.src[lx.pos : lx.pos+2])
+				for _, op := range twoCharOps {
+					if two == op {
+						lx.advance()
+						lx.advance()
+						return ytok{yPunct, two, line, col}
+					}
+				}
+			}
+			switch c {
+			case '(', ')', '{', '}', ',', ';', '=', '+', '-', '*', '/', '%',
+				'&', '|', '^', '<', '>', '!', '[', ']':
+				lx.advance()
+				return ytok{yPunct, string(c), line, col}
+			}
+			lx.advance()
+			return ytok{yErr, fmt.Sprintf("unexpected character %q", c), line, col}
+		}
+	}
+	return ytok{yEOF, "", lx.line, lx.col}
+}
+
+// scanString scans a double-quoted literal with the usual escapes.
+// Strings must close on the line they open; a stray quote would
+// otherwise swallow the rest of the file.
+func (lx *ylexer) scanString(line, col int) ytok {
+	lx.advance() // opening quote
+	var b []byte
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch c {
+		case '"':
+			lx.advance()
+			return ytok{yString, string(b), line, col}
+		case '\n':
+			return ytok{yErr, "unterminated string", line, col}
+		case '\\':
+			lx.advance()
+			if lx.pos >= len(lx.src) {
+				return ytok{yErr, "unterminated string", line, col}
+			}
+			switch e := lx.src[lx.pos]; e {
+			case 'n':
+				b = append(b, '\n')
+			case 't':
+				b = append(b, '\t')
+			case 'r':
+				b = append(b, '\r')
+			case '0':
+				b = append(b, 0)
+			case '\\', '"':
+				b = append(b, e)
+			default:
+				return ytok{yErr, fmt.Sprintf("bad escape \\%c in string", e), line, col}
+			}
+			lx.advance()
+		default:
+			b = append(b, c)
+			lx.advance()
+		}
+	}
+	return ytok{yErr, "unterminated string", line, col}
+}
+
+func isYDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isYIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isYIdentCont(c byte) bool { return isYIdentStart(c) || isYDigit(c) }
+
+func isYNumCont(c byte) bool {
+	return isYDigit(c) || c == 'x' || c == 'X' ||
+		c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
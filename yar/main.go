@@ -0,0 +1,113 @@
+// yar is the WUT-4 librarian: it bundles files (the runtime library's
+// objects, typically) into WAR1 archives and takes them apart again.
+// Member names are the base names of the input files.
+//
+// Usage:
+//
+//	yar c archive file...   create archive from files
+//	yar t archive           list members
+//	yar x archive [name...] extract all or named members
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gmofishsauce/y4/obj"
+)
+
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("yar obj=%d\n", obj.Version)
+		return
+	}
+	args := flag.Args()
+	if len(args) < 2 {
+		usage()
+	}
+	op, archive, rest := args[0], args[1], args[2:]
+	switch op {
+	case "c":
+		if len(rest) == 0 {
+			usage()
+		}
+		create(archive, rest)
+	case "t":
+		if len(rest) != 0 {
+			usage()
+		}
+		list(archive)
+	case "x":
+		extract(archive, rest)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: yar c archive file... | yar t archive | yar x archive [name...]")
+	os.Exit(2)
+}
+
+func create(archive string, files []string) {
+	var members []obj.Member
+	seen := make(map[string]bool)
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			fatal("%v", err)
+		}
+		name := filepath.Base(f)
+		if seen[name] {
+			fatal("duplicate member name %q", name)
+		}
+		seen[name] = true
+		members = append(members, obj.Member{Name: name, Body: b})
+	}
+	if err := obj.WriteArchive(archive, members); err != nil {
+		fatal("%v", err)
+	}
+}
+
+func list(archive string) {
+	members, err := obj.ReadArchive(archive)
+	if err != nil {
+		fatal("%v", err)
+	}
+	for _, m := range members {
+		fmt.Printf("%8d %s\n", len(m.Body), m.Name)
+	}
+}
+
+func extract(archive string, names []string) {
+	members, err := obj.ReadArchive(archive)
+	if err != nil {
+		fatal("%v", err)
+	}
+	want := make(map[string]bool)
+	for _, n := range names {
+		want[n] = true
+	}
+	for _, m := range members {
+		if len(names) != 0 && !want[m.Name] {
+			continue
+		}
+		delete(want, m.Name)
+		if err := os.WriteFile(m.Name, m.Body, 0644); err != nil {
+			fatal("%v", err)
+		}
+	}
+	for n := range want {
+		fatal("no member %q in %s", n, archive)
+	}
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "yar: "+format+"\n", args...)
+	os.Exit(1)
+}
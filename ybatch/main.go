@@ -0,0 +1,259 @@
+// ybatch runs a manifest of binaries under func sequentially and
+// prints a result table: exit status, cycle count, and a glimpse of
+// the console output per entry. It is the glue between a directory
+// of built test binaries and a larger pipeline that wants one command
+// and one exit status, without each pipeline growing its own shell
+// loop around func.
+//
+// Usage: ybatch [-bindir dir] [-outdir dir] [-junit file] manifest
+//
+// The manifest is plain text, one entry per line:
+//
+//	name func-arguments...
+//
+// Blank lines and lines starting with # are skipped. The arguments
+// are passed to func verbatim after -q -stats, so an entry is exactly
+// the func invocation it stands for — a kernel image with a user
+// payload is, say, "pair -data user.bin@0x4000 kernel.bin". func runs
+// with the manifest's directory as its working directory, so paths in
+// the manifest are relative to the manifest.
+//
+// An entry fails when func exits nonzero. With -outdir each entry's
+// full console output is saved as name.out; with -junit a JUnit XML
+// report is written for CI systems that ingest that format.
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var binDir = flag.String("bindir", "", "directory holding func (default $PATH)")
+var outDir = flag.String("outdir", "", "save each entry's console output as name.out here")
+var junitFile = flag.String("junit", "", "write a JUnit XML report to this file")
+
+// entry is one manifest line.
+type entry struct {
+	name string
+	args []string
+}
+
+// batchResult is the outcome of running one entry.
+type batchResult struct {
+	entry
+	status  string // "pass" or the failure summary
+	failed  bool
+	cycles  uint64
+	console []byte
+	stderr  string
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ybatch [-bindir dir] [-outdir dir] [-junit file] manifest")
+		os.Exit(2)
+	}
+	manifest := flag.Arg(0)
+	b, err := os.ReadFile(manifest)
+	if err != nil {
+		fatal("%v", err)
+	}
+	entries, err := parseManifest(b)
+	if err != nil {
+		fatal("%s: %v", manifest, err)
+	}
+	if len(entries) == 0 {
+		fatal("%s: no entries", manifest)
+	}
+
+	var results []batchResult
+	failed := 0
+	for _, e := range entries {
+		r := runEntry(e, filepath.Dir(manifest))
+		if r.failed {
+			failed++
+		}
+		if *outDir != "" {
+			out := filepath.Join(*outDir, r.name+".out")
+			if err := os.WriteFile(out, r.console, 0644); err != nil {
+				fatal("%v", err)
+			}
+		}
+		results = append(results, r)
+	}
+
+	printTable(results)
+	fmt.Printf("%d entries, %d failed\n", len(results), failed)
+	if *junitFile != "" {
+		if err := writeJUnit(*junitFile, results); err != nil {
+			fatal("%v", err)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseManifest splits the manifest into entries. Duplicate names are
+// an error: they would silently overwrite each other's -outdir files
+// and make the table ambiguous.
+func parseManifest(b []byte) ([]entry, error) {
+	var entries []entry
+	seen := make(map[string]bool)
+	for i, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: entry %q has no func arguments", i+1, fields[0])
+		}
+		if seen[fields[0]] {
+			return nil, fmt.Errorf("line %d: duplicate entry name %q", i+1, fields[0])
+		}
+		seen[fields[0]] = true
+		entries = append(entries, entry{name: fields[0], args: fields[1:]})
+	}
+	return entries, nil
+}
+
+// runEntry executes one entry under func with empty stdin, capturing
+// the console output (func's stdout) and the cycle count from the
+// -stats line on stderr.
+func runEntry(e entry, dir string) batchResult {
+	r := batchResult{entry: e, status: "pass"}
+	args := append([]string{"-q", "-stats"}, e.args...)
+	cmd := exec.Command(tool("func"), args...)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(nil)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	r.console = stdout.Bytes()
+	r.stderr = stderr.String()
+	r.cycles = parseCycles(r.stderr)
+	if err != nil {
+		r.failed = true
+		r.status = err.Error() // "exit status 1" or the spawn error
+	}
+	return r
+}
+
+// parseCycles extracts the cycle count from a func-stats line, zero
+// if there is none (func failed before the summary).
+func parseCycles(stderr string) uint64 {
+	for _, line := range strings.Split(stderr, "\n") {
+		if !strings.HasPrefix(line, "func-stats:") {
+			continue
+		}
+		for _, f := range strings.Fields(line) {
+			if v, ok := strings.CutPrefix(f, "cycles="); ok {
+				n, err := strconv.ParseUint(v, 10, 64)
+				if err == nil {
+					return n
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// printTable renders the per-entry summary. The console column shows
+// the first line of output, quoted and truncated, enough to tell one
+// run's output from another without -outdir.
+func printTable(results []batchResult) {
+	wName := len("NAME")
+	for _, r := range results {
+		if len(r.name) > wName {
+			wName = len(r.name)
+		}
+	}
+	fmt.Printf("%-*s  %-6s  %8s  %s\n", wName, "NAME", "RESULT", "CYCLES", "CONSOLE")
+	for _, r := range results {
+		status := "pass"
+		if r.failed {
+			status = "FAIL"
+		}
+		fmt.Printf("%-*s  %-6s  %8d  %s\n", wName, r.name, status, r.cycles, glimpse(r.console))
+		if r.failed {
+			fmt.Printf("%-*s  %s\n", wName, "", r.status)
+		}
+	}
+}
+
+// glimpse is the table's one-line view of an entry's console output.
+func glimpse(b []byte) string {
+	s := string(b)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	const max = 40
+	if len(s) > max {
+		s = s[:max] + "..."
+	}
+	return strconv.Quote(s)
+}
+
+// JUnit XML, the least common denominator of CI result ingestion.
+// Only the fields those systems actually read are emitted.
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+func writeJUnit(path string, results []batchResult) error {
+	suite := junitSuite{Name: "ybatch"}
+	for _, r := range results {
+		c := junitCase{
+			Name:      r.name,
+			SystemOut: string(r.console),
+			SystemErr: r.stderr,
+		}
+		if r.failed {
+			suite.Failures++
+			c.Failure = &junitFailure{Message: r.status}
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, c)
+	}
+	b, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(b, '\n')...), 0644)
+}
+
+func tool(name string) string {
+	if *binDir != "" {
+		return filepath.Join(*binDir, name)
+	}
+	return name
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ybatch: "+format+"\n", args...)
+	os.Exit(1)
+}
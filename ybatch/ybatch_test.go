@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	entries, err := parseManifest([]byte(`
+# comment
+hello hello.bin
+pair -data user.bin@0x4000 kernel.bin
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].name != "hello" || len(entries[0].args) != 1 {
+		t.Errorf("entry 0 = %+v", entries[0])
+	}
+	if entries[1].name != "pair" || len(entries[1].args) != 3 {
+		t.Errorf("entry 1 = %+v", entries[1])
+	}
+
+	for _, bad := range []string{"lonely", "dup a.bin\ndup b.bin"} {
+		if _, err := parseManifest([]byte(bad)); err == nil {
+			t.Errorf("manifest %q parsed without error", bad)
+		}
+	}
+}
+
+func TestParseCycles(t *testing.T) {
+	stderr := "func: halted at pc 0x0002 after 42 cycles\n" +
+		"func-stats: cycles=12345 seconds=0.000100 cps=123450000\n"
+	if got := parseCycles(stderr); got != 12345 {
+		t.Errorf("cycles = %d, want 12345", got)
+	}
+	if got := parseCycles("no stats here\n"); got != 0 {
+		t.Errorf("cycles = %d from empty stderr, want 0", got)
+	}
+}
+
+func TestGlimpse(t *testing.T) {
+	if got := glimpse([]byte("Hello, World!\nmore")); got != `"Hello, World!"` {
+		t.Errorf("glimpse = %s", got)
+	}
+	long := strings.Repeat("x", 60)
+	if got := glimpse([]byte(long)); len(got) > 50 {
+		t.Errorf("glimpse did not truncate: %s", got)
+	}
+}
@@ -0,0 +1,148 @@
+// ydiff compares two WUT-4 binaries at the instruction level. Where
+// cmp reports byte offsets into the container, ydiff reports each
+// differing code word as disassembled before/after instructions with
+// its address — and with the nearest symbol when an asm -syms dump is
+// given — which is what validating a toolchain change actually needs.
+//
+// Usage: ydiff [-syms prog.syms] old.bin new.bin
+//
+// Exit status is 0 when the binaries are identical, 1 when they
+// differ, 2 for usage or load errors, cmp-style.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+var symsFile = flag.String("syms", "", "symbol table from asm -syms, for symbolic addresses")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("ydiff isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: ydiff [-syms prog.syms] old.bin new.bin")
+		os.Exit(2)
+	}
+	a, err := obj.Read(flag.Arg(0))
+	if err != nil {
+		fatal("%v", err)
+	}
+	b, err := obj.Read(flag.Arg(1))
+	if err != nil {
+		fatal("%v", err)
+	}
+	var syms symtab
+	if *symsFile != "" {
+		if syms, err = loadSyms(*symsFile); err != nil {
+			fatal("%v", err)
+		}
+	}
+	if diff(os.Stdout, a, b, syms) {
+		os.Exit(1)
+	}
+}
+
+// diff reports every difference between the images and returns
+// whether there were any.
+func diff(w io.Writer, a, b *obj.Image, syms symtab) bool {
+	differ := false
+	note := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, format+"\n", args...)
+		differ = true
+	}
+	if a.Entry != b.Entry {
+		note("entry: 0x%04X -> 0x%04X", a.Entry, b.Entry)
+	}
+	if a.IsaRev != b.IsaRev {
+		note("isa revision: %d -> %d", a.IsaRev, b.IsaRev)
+	}
+	if len(a.Code) != len(b.Code) {
+		note("code: %d words -> %d words", len(a.Code), len(b.Code))
+	}
+	n := len(a.Code)
+	if len(b.Code) < n {
+		n = len(b.Code)
+	}
+	for i := 0; i < n; i++ {
+		if a.Code[i] == b.Code[i] {
+			continue
+		}
+		note("%s: 0x%04X %-24s -> 0x%04X %s", syms.name(i),
+			a.Code[i], isa.Disassemble(a.Code[i], i),
+			b.Code[i], isa.Disassemble(b.Code[i], i))
+	}
+	if len(a.Data) != len(b.Data) {
+		note("data: %d bytes -> %d bytes", len(a.Data), len(b.Data))
+	}
+	n = len(a.Data)
+	if len(b.Data) < n {
+		n = len(b.Data)
+	}
+	// Byte differences are reported as runs, not one line per byte: a
+	// changed string or table would otherwise swamp the code diff.
+	for i := 0; i < n; i++ {
+		if a.Data[i] == b.Data[i] {
+			continue
+		}
+		j := i
+		for j < n && a.Data[j] != b.Data[j] {
+			j++
+		}
+		note("data 0x%04X..0x%04X: % X -> % X", i, j-1, a.Data[i:j], b.Data[i:j])
+		i = j
+	}
+	return differ
+}
+
+// A symtab maps code addresses back to symbol+offset form. It is
+// sorted by value; name does a binary search for the nearest symbol
+// at or below the address.
+type symtab []struct {
+	name  string
+	value int
+}
+
+func (t symtab) name(addr int) string {
+	i := sort.Search(len(t), func(i int) bool { return t[i].value > addr }) - 1
+	if i < 0 {
+		return fmt.Sprintf("0x%04X", addr)
+	}
+	if off := addr - t[i].value; off != 0 {
+		return fmt.Sprintf("%s+%d (0x%04X)", t[i].name, off, addr)
+	}
+	return fmt.Sprintf("%s (0x%04X)", t[i].name, addr)
+}
+
+// loadSyms reads an asm -syms dump into the sorted-by-value form the
+// lookup wants.
+func loadSyms(path string) (symtab, error) {
+	dump, err := obj.ReadSyms(path)
+	if err != nil {
+		return nil, err
+	}
+	var t symtab
+	for _, s := range dump {
+		t = append(t, struct {
+			name  string
+			value int
+		}{s.Name, int(s.Value)})
+	}
+	sort.SliceStable(t, func(i, j int) bool { return t[i].value < t[j].value })
+	return t, nil
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ydiff: "+format+"\n", args...)
+	os.Exit(2)
+}
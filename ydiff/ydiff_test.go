@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+func TestDiffOutput(t *testing.T) {
+	a := &obj.Image{
+		Code: []uint16{
+			isa.EncodeMem(isa.OpAdi, 2, 0, 5),
+			isa.EncodeXop(isa.XopAdd, 2, 3),
+			isa.EncodeVop(isa.VopHlt),
+		},
+		Data: []byte{1, 2, 3, 4},
+	}
+	b := &obj.Image{
+		Code: []uint16{
+			isa.EncodeMem(isa.OpAdi, 2, 0, 9),
+			isa.EncodeXop(isa.XopAdd, 2, 3),
+			isa.EncodeVop(isa.VopHlt),
+		},
+		Data:  []byte{1, 2, 9, 9},
+		Entry: 1,
+	}
+	syms := symtab{{"main", 0}}
+
+	var out strings.Builder
+	if !diff(&out, a, b, syms) {
+		t.Fatal("differing images reported identical")
+	}
+	for _, want := range []string{
+		"entry: 0x0000 -> 0x0001",
+		"main (0x0000):",
+		"adi r2, r0, 5",
+		"adi r2, r0, 9",
+		"data 0x0002..0x0003: 03 04 -> 09 09",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "hlt") {
+		t.Errorf("identical words reported:\n%s", out.String())
+	}
+
+	out.Reset()
+	if diff(&out, a, a, syms) || out.Len() != 0 {
+		t.Errorf("identical images reported different: %q", out.String())
+	}
+}
+
+func TestSymtabName(t *testing.T) {
+	syms := symtab{{"main", 0}, {"loop", 4}}
+	cases := map[int]string{
+		0: "main (0x0000)",
+		2: "main+2 (0x0002)",
+		4: "loop (0x0004)",
+		9: "loop+5 (0x0009)",
+	}
+	for addr, want := range cases {
+		if got := syms.name(addr); got != want {
+			t.Errorf("name(%d) = %q, want %q", addr, got, want)
+		}
+	}
+	if got := symtab(nil).name(3); got != "0x0003" {
+		t.Errorf("no symbols: name(3) = %q", got)
+	}
+}
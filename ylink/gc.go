@@ -0,0 +1,82 @@
+// Section garbage collection (-gc-sections): drop .func blocks and
+// labeled data runs that nothing reachable references, before the
+// combined stream goes to the assembler. Common sections — top-level
+// code, .set lines, anything outside .func and unlabeled data — are
+// always kept, so code that runs by position (boot vectors, fall-
+// through routines like lib/math.s) is never touched.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// gc marks sections reachable from the common text and the entry
+// symbols, filters each unit's source down to the kept sections, and
+// returns "name unit" strings for the discarded ones.
+func gc(units []*unit) []string {
+	def := make(map[string]*section)
+	for _, u := range units {
+		for _, sec := range u.sects {
+			for _, s := range sec.defs() {
+				if def[s] == nil {
+					def[s] = sec
+				}
+			}
+		}
+	}
+
+	keep := make(map[*section]bool)
+	var work []*section
+	mark := func(sec *section) {
+		if sec != nil && !keep[sec] {
+			keep[sec] = true
+			work = append(work, sec)
+		}
+	}
+	for _, u := range units {
+		for _, sec := range u.sects {
+			if sec.kind == sectCommon {
+				mark(sec)
+			}
+		}
+	}
+	// _start is the object format's entry symbol; main is the YAPL
+	// and library convention. Either roots the program if defined.
+	mark(def["_start"])
+	mark(def["main"])
+	for len(work) > 0 {
+		sec := work[0]
+		work = work[1:]
+		for _, s := range sec.refs {
+			mark(def[s])
+		}
+	}
+
+	var discarded []string
+	for _, u := range units {
+		for _, sec := range u.sects {
+			if !keep[sec] && !sec.empty() {
+				discarded = append(discarded, fmt.Sprintf("%s  %s", sec.name, u.name))
+			}
+		}
+		u.filter(keep)
+	}
+	return discarded
+}
+
+// filter rewrites the unit's source with only the kept sections'
+// lines, in their original order.
+func (u *unit) filter(keep map[*section]bool) {
+	lines := strings.SplitAfter(string(u.src), "\n")
+	var b strings.Builder
+	for _, sec := range u.sects {
+		if !keep[sec] {
+			continue
+		}
+		for ln := sec.start; ln <= sec.end && ln <= len(lines); ln++ {
+			b.WriteString(lines[ln-1])
+		}
+	}
+	u.src = []byte(b.String())
+}
@@ -0,0 +1,312 @@
+// ylink is the WUT-4 linker. The assembler resolves every address in
+// a single pass over one source stream, so ylink links at the source
+// level: it concatenates its inputs in order, pulling WAR1 archive
+// members only when they define a symbol some earlier input left
+// undefined, then runs asm (found on $PATH, like itf's tools) on the
+// combined stream.
+//
+// With -map ylink writes a link map: section sizes against the 64 KiW
+// code space, each input's code word contribution, and every symbol
+// with its final address and defining input.
+//
+// With -gc-sections ylink drops .func blocks and labeled data runs
+// that nothing reachable references (see gc.go); the map lists what
+// was discarded.
+//
+// Diagnostics from the assembler refer to the combined source, which
+// is kept next to the output (out.link.s) when assembly fails and
+// removed when it succeeds.
+//
+// Usage: ylink [-o out.bin] [-map out.map] input.s... [lib.war...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gmofishsauce/y4/obj"
+)
+
+var outFile = flag.String("o", "", "output file (default first input with .bin suffix)")
+var mapFile = flag.String("map", "", "write a link map to this file")
+var gcSections = flag.Bool("gc-sections", false, "drop unreferenced .func blocks and labeled data runs")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+
+// unit is one source file headed for the combined stream: a program
+// input named on the command line or a selected archive member.
+type unit struct {
+	name  string // display name; members carry their archive
+	src   []byte
+	sects []*section
+	first int // first line of the body in the combined stream
+	lines int // body line count
+	words int // code words contributed, from the line map
+}
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("ylink obj=%d\n", obj.Version)
+		return
+	}
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ylink [-o out.bin] [-map out.map] input.s... [lib.war...]")
+		os.Exit(2)
+	}
+
+	var prog []*unit
+	var libs []*unit
+	for _, arg := range flag.Args() {
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if obj.IsArchive(b) {
+			members, err := obj.ReadArchive(arg)
+			if err != nil {
+				fatal("%v", err)
+			}
+			for _, m := range members {
+				u := newUnit(fmt.Sprintf("%s (%s)", m.Name, filepath.Base(arg)), m.Body)
+				libs = append(libs, u)
+			}
+		} else {
+			prog = append(prog, newUnit(arg, b))
+		}
+	}
+	if len(prog) == 0 {
+		fatal("no program input, only archives")
+	}
+	units := append(prog, selectMembers(prog, libs)...)
+	var discarded []string
+	if *gcSections {
+		discarded = gc(units)
+	}
+
+	out := *outFile
+	if out == "" {
+		out = strings.TrimSuffix(flag.Arg(0), ".s") + ".bin"
+	}
+	combined := strings.TrimSuffix(out, ".bin") + ".link.s"
+	if err := os.WriteFile(combined, combine(units), 0644); err != nil {
+		fatal("%v", err)
+	}
+
+	tmp, err := os.MkdirTemp("", "ylink")
+	if err != nil {
+		fatal("%v", err)
+	}
+	defer os.RemoveAll(tmp)
+	lines := filepath.Join(tmp, "lines")
+	syms := filepath.Join(tmp, "syms")
+	cmd := exec.Command("asm", "-o", out, "-lines", lines, "-syms", syms, combined)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fatal("asm failed on %s: %v", combined, err)
+	}
+	os.Remove(combined)
+
+	if *mapFile != "" {
+		if err := writeMap(*mapFile, out, units, discarded, lines, syms); err != nil {
+			fatal("%v", err)
+		}
+	}
+}
+
+// selectMembers returns the archive members needed to close the
+// program over its symbol references, in archive order. A member is
+// pulled when it defines a symbol no already-included unit defines;
+// pulling one may expose new undefined references, so the scan
+// repeats until it settles.
+func selectMembers(prog, libs []*unit) []*unit {
+	defined := make(map[string]bool)
+	referenced := make(map[string]bool)
+	include := func(u *unit) {
+		for _, sec := range u.sects {
+			for _, s := range sec.defs() {
+				defined[s] = true
+			}
+			for _, s := range sec.refs {
+				referenced[s] = true
+			}
+		}
+	}
+	for _, u := range prog {
+		include(u)
+	}
+	var picked []*unit
+	taken := make(map[*unit]bool)
+	for {
+		progress := false
+		for _, u := range libs {
+			if taken[u] {
+				continue
+			}
+			if defines(u, func(s string) bool { return referenced[s] && !defined[s] }) {
+				taken[u] = true
+				picked = append(picked, u)
+				include(u)
+				progress = true
+			}
+		}
+		if !progress {
+			return picked
+		}
+	}
+}
+
+// defines reports whether any of the unit's definitions satisfies
+// the predicate.
+func defines(u *unit, pred func(string) bool) bool {
+	for _, sec := range u.sects {
+		for _, s := range sec.defs() {
+			if pred(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// combine concatenates the unit bodies, each behind a one-line marker
+// comment, and records where each body lands so the line map can be
+// attributed back to inputs.
+func combine(units []*unit) []byte {
+	var b strings.Builder
+	line := 1
+	for _, u := range units {
+		fmt.Fprintf(&b, "; ylink: %s\n", u.name)
+		line++
+		u.first = line
+		b.Write(u.src)
+		if len(u.src) > 0 && u.src[len(u.src)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+		u.lines = countLines(u.src)
+		line += u.lines
+	}
+	return []byte(b.String())
+}
+
+func countLines(src []byte) int {
+	n := strings.Count(string(src), "\n")
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// writeMap emits the link map: section totals, per-input code words
+// from the assembler's line map, and symbols by final address with
+// the input that defined them.
+func writeMap(path, out string, units []*unit, discarded []string, linesFile, symsFile string) error {
+	im, err := obj.Read(out)
+	if err != nil {
+		return err
+	}
+	if err := countWords(units, linesFile); err != nil {
+		return err
+	}
+	values, err := obj.ReadSymsMap(symsFile)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "; link map for %s\n", out)
+	fmt.Fprintf(&b, "; code %d/65536 words, data %d bytes, entry 0x%04X\n\n",
+		len(im.Code), len(im.Data), im.Entry)
+
+	fmt.Fprintf(&b, "; code words by input\n")
+	for _, u := range units {
+		fmt.Fprintf(&b, "%8d %s\n", u.words, u.name)
+	}
+
+	type symAddr struct {
+		name, unit string
+		value      int
+	}
+	gather := func(pick func(*section) []string) []symAddr {
+		var out []symAddr
+		for _, u := range units {
+			for _, sec := range u.sects {
+				for _, s := range pick(sec) {
+					if v, ok := values[s]; ok {
+						out = append(out, symAddr{s, u.name, int(v)})
+					}
+				}
+			}
+		}
+		sort.SliceStable(out, func(i, j int) bool { return out[i].value < out[j].value })
+		return out
+	}
+	addrs := gather(func(s *section) []string { return s.labels })
+	dataSyms := gather(func(s *section) []string { return s.data })
+	consts := gather(func(s *section) []string { return s.consts })
+
+	fmt.Fprintf(&b, "\n; code symbols by address\n")
+	for _, s := range addrs {
+		fmt.Fprintf(&b, "0x%04X %s  %s\n", s.value, s.name, s.unit)
+	}
+	if len(dataSyms) > 0 {
+		fmt.Fprintf(&b, "\n; data symbols by address\n")
+		for _, s := range dataSyms {
+			fmt.Fprintf(&b, "0x%04X %s  %s\n", s.value, s.name, s.unit)
+		}
+	}
+	if len(consts) > 0 {
+		fmt.Fprintf(&b, "\n; constants\n")
+		for _, s := range consts {
+			fmt.Fprintf(&b, "%6d %s  %s\n", s.value, s.name, s.unit)
+		}
+	}
+	if len(discarded) > 0 {
+		fmt.Fprintf(&b, "\n; discarded sections\n")
+		for _, d := range discarded {
+			fmt.Fprintf(&b, "%s\n", d)
+		}
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// countWords attributes each code word to the input whose lines
+// produced it, using the assembler's address-to-line map of the
+// combined stream.
+func countWords(units []*unit, linesFile string) error {
+	b, err := os.ReadFile(linesFile)
+	if err != nil {
+		return err
+	}
+	for _, entry := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		if entry == "" {
+			continue
+		}
+		fields := strings.Fields(entry)
+		if len(fields) != 2 {
+			return fmt.Errorf("bad line map entry %q", entry)
+		}
+		line, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return err
+		}
+		for _, u := range units {
+			if line >= u.first && line < u.first+u.lines {
+				u.words++
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ylink: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,154 @@
+// Source scanning for member selection and section gc. ylink does
+// not assemble: it only needs to know which symbols each input
+// defines and which it references, so it reads the token stream with
+// the same line shape the assembler parses (optional label, mnemonic
+// or directive, operands) and leaves every semantic judgment to asm.
+//
+// Along the way each input is divided into sections: .func/.endfunc
+// blocks and labeled data runs are separable (gc can drop them),
+// while everything else — top-level code, .set lines, directives —
+// is common and always kept.
+package main
+
+import (
+	"strings"
+
+	"github.com/gmofishsauce/y4/lex"
+)
+
+type sectKind int
+
+const (
+	sectCommon sectKind = iota
+	sectFunc
+	sectData
+)
+
+// section is one separable span of an input: a .func block, a
+// labeled data run, or the common text between them. Line numbers
+// are 1-based and inclusive.
+type section struct {
+	name       string // .func name or first data label; "" for common
+	kind       sectKind
+	start, end int
+	labels     []string // symbols defined at code addresses
+	data       []string // symbols defined at data addresses
+	consts     []string // .set names and .func frame symbols
+	refs       []string
+}
+
+func newUnit(name string, src []byte) *unit {
+	u := &unit{name: name, src: src}
+	u.scan()
+	return u
+}
+
+// scan fills in the unit's sections. Labels and .func names define
+// addresses; .set names and .func frame symbols define constants.
+// Every other non-register operand symbol is a reference.
+func (u *unit) scan() {
+	lx := lex.NewLexer(u.src)
+	inData := false
+	cur := &section{kind: sectCommon, start: 1}
+	u.sects = append(u.sects, cur)
+	open := func(s *section) {
+		cur.end = s.start - 1
+		cur = s
+		u.sects = append(u.sects, s)
+	}
+	for {
+		t := lx.Next()
+		if t.Kind == lex.TkEOF {
+			cur.end = countLines(u.src)
+			return
+		}
+		if t.Kind == lex.TkNewline {
+			continue
+		}
+		line := t.Line
+		if t.Kind == lex.TkSymbol && lx.Peek(0).Kind == lex.TkColon {
+			if inData {
+				if cur.kind == sectData || cur.kind == sectCommon {
+					open(&section{name: t.Text, kind: sectData, start: line})
+				}
+				cur.data = append(cur.data, t.Text)
+			} else {
+				cur.labels = append(cur.labels, t.Text)
+			}
+			lx.Next()
+			t = lx.Next()
+			if t.Kind == lex.TkNewline || t.Kind == lex.TkEOF {
+				continue
+			}
+		}
+		var op string
+		if t.Kind == lex.TkSymbol {
+			op = t.Text
+			t = lx.Next()
+			switch op {
+			case ".data":
+				inData = true
+			case ".code":
+				if cur.kind == sectData {
+					open(&section{kind: sectCommon, start: line})
+				}
+				inData = false
+			case ".func":
+				open(&section{kind: sectFunc, start: line})
+			}
+		}
+		nth := 0
+		for t.Kind != lex.TkNewline && t.Kind != lex.TkEOF {
+			if t.Kind == lex.TkSymbol {
+				cur.operand(op, nth, t.Text)
+				nth++
+			} else if t.Kind == lex.TkNumber || t.Kind == lex.TkString {
+				nth++
+			}
+			t = lx.Next()
+		}
+		if op == ".endfunc" && cur.kind == sectFunc {
+			open(&section{kind: sectCommon, start: line + 1})
+		}
+	}
+}
+
+func (s *section) operand(op string, nth int, text string) {
+	if nth == 0 && op == ".set" {
+		s.consts = append(s.consts, text)
+		return
+	}
+	if nth == 0 && op == ".func" {
+		s.name = text
+		s.labels = append(s.labels, text)
+		s.consts = append(s.consts, text+".frame")
+		return
+	}
+	if isRegister(text) || strings.HasPrefix(text, ".") {
+		return
+	}
+	s.refs = append(s.refs, text)
+}
+
+// defs returns every symbol the section defines.
+func (s *section) defs() []string {
+	out := make([]string, 0, len(s.labels)+len(s.data)+len(s.consts))
+	out = append(out, s.labels...)
+	out = append(out, s.data...)
+	return append(out, s.consts...)
+}
+
+// empty reports whether the section covers no lines; scan leaves
+// zero-width common sections behind back-to-back .func blocks.
+func (s *section) empty() bool {
+	return s.start > s.end
+}
+
+// isRegister mirrors the assembler's register names: r0-r7 and the
+// ABI role names.
+func isRegister(s string) bool {
+	if len(s) == 2 && s[0] == 'r' && s[1] >= '0' && s[1] <= '7' {
+		return true
+	}
+	return s == "lnk" || s == "sp"
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	u := newUnit("t.s", []byte(`
+.set limit, 60
+.func main, 4
+	adi sp, sp, -4
+	lui r2, limit
+	jlr lnk, r3, mul16
+loop:	beq r0, r0, loop
+.endfunc
+.data
+buf:	.space 8
+`))
+	var funcs, data, consts, refs []string
+	for _, sec := range u.sects {
+		if sec.kind == sectFunc {
+			funcs = append(funcs, sec.name)
+		}
+		data = append(data, sec.data...)
+		consts = append(consts, sec.consts...)
+		refs = append(refs, sec.refs...)
+	}
+	if want := []string{"main"}; !reflect.DeepEqual(funcs, want) {
+		t.Errorf("funcs = %v, want %v", funcs, want)
+	}
+	if want := []string{"buf"}; !reflect.DeepEqual(data, want) {
+		t.Errorf("data = %v, want %v", data, want)
+	}
+	if want := []string{"limit", "main.frame"}; !reflect.DeepEqual(consts, want) {
+		t.Errorf("consts = %v, want %v", consts, want)
+	}
+	if want := []string{"limit", "mul16", "loop"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("refs = %v, want %v", refs, want)
+	}
+}
+
+func TestSelectMembers(t *testing.T) {
+	prog := newUnit("prog.s", []byte("jlr lnk, r3, mul16\n"))
+	mul := newUnit("math.s", []byte("mul16:\n\tjlr lnk, r3, spin\n"))
+	spin := newUnit("spin.s", []byte("spin:\tbeq r0, r0, spin\n"))
+	lock := newUnit("lock.s", []byte("lock:\thlt\n"))
+
+	// mul16 pulls math.s, whose own reference pulls spin.s on the
+	// second iteration; lock.s stays out.
+	picked := selectMembers([]*unit{prog}, []*unit{lock, spin, mul})
+	if len(picked) != 2 || picked[0] != mul || picked[1] != spin {
+		names := make([]string, len(picked))
+		for i, u := range picked {
+			names[i] = u.name
+		}
+		t.Errorf("picked %v, want [math.s spin.s]", names)
+	}
+}
+
+func TestGC(t *testing.T) {
+	prog := newUnit("prog.s", []byte(`.func main, 0
+	jlr lnk, r3, used
+	hlt
+.endfunc
+.func used, 0
+	jlr lnk, r0, 0
+.endfunc
+.func unused, 0
+	jlr lnk, r0, 0
+.endfunc
+.data
+buf: .space 4
+`))
+	lib := newUnit("lib.s", []byte(`.func helper, 0
+	jlr lnk, r0, 0
+.endfunc
+.data
+deadbuf: .space 2
+`))
+	discarded := gc([]*unit{prog, lib})
+	want := []string{"unused  prog.s", "buf  prog.s", "helper  lib.s", "deadbuf  lib.s"}
+	if !reflect.DeepEqual(discarded, want) {
+		t.Errorf("discarded = %v, want %v", discarded, want)
+	}
+	src := string(prog.src)
+	if !strings.Contains(src, ".func used") || strings.Contains(src, ".func unused") {
+		t.Errorf("filtered prog source wrong:\n%s", src)
+	}
+	// Unreferenced labeled data runs are dropped like functions.
+	if strings.Contains(src, "buf") {
+		t.Errorf("unreferenced data run kept:\n%s", src)
+	}
+}
@@ -0,0 +1,146 @@
+// ymin minimizes a failing WUT-4 assembly program. Given a source
+// file and a command that reproduces the failure — an itf case that
+// diverges, a func run that crashes, an assertion in a harness — it
+// repeatedly removes and simplifies lines while the failure still
+// reproduces, and writes a minimal reproducer. Small reproducers are
+// what turn an emulator or ISA bug report into a fix.
+//
+// Usage: ymin -run 'cmd {}' [-match text] [-o out.s] prog.s
+//
+// The command runs with {} replaced by a candidate source path. A
+// candidate still fails when the command exits nonzero and, when
+// -match is given, its combined output contains the text; -match
+// keeps the minimizer from wandering onto a different failure (an
+// assembly error, say) than the one being chased.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var runCmd = flag.String("run", "", "command reproducing the failure; {} is the candidate file")
+var matchText = flag.String("match", "", "require this text in the command output")
+var outFile = flag.String("o", "", "output file (default source with .min.s suffix)")
+var timeout = flag.Duration("timeout", 10*time.Second,
+	"kill a reproduction run after this long; candidates that hang count as not failing")
+
+var runs int
+
+func main() {
+	flag.Parse()
+	if *runCmd == "" || flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ymin -run 'cmd {}' [-match text] [-o out.s] prog.s")
+		os.Exit(2)
+	}
+	src := flag.Arg(0)
+	b, err := os.ReadFile(src)
+	if err != nil {
+		fatal("%v", err)
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+
+	if !fails(lines) {
+		fatal("%s does not reproduce the failure (command exited 0%s)",
+			src, matchNote())
+	}
+	min := minimize(lines, fails)
+
+	out := *outFile
+	if out == "" {
+		out = strings.TrimSuffix(src, ".s") + ".min.s"
+	}
+	if err := os.WriteFile(out, []byte(strings.Join(min, "")), 0644); err != nil {
+		fatal("%v", err)
+	}
+	fmt.Fprintf(os.Stderr, "ymin: %d lines -> %d in %d runs, wrote %s\n",
+		len(lines), len(min), runs, out)
+}
+
+func matchNote() string {
+	if *matchText == "" {
+		return ""
+	}
+	return fmt.Sprintf(" or output lacked %q", *matchText)
+}
+
+// minimize shrinks lines while pred keeps reporting failure: first
+// removal of ever-smaller chunks until a fixed point, then a pass
+// replacing each surviving instruction with adi r0, r0, 0, which
+// keeps addresses and branch distances intact while discarding the
+// instruction's behavior.
+func minimize(lines []string, pred func([]string) bool) []string {
+	for changed := true; changed; {
+		changed = false
+		for chunk := len(lines) / 2; chunk >= 1; chunk /= 2 {
+			for i := 0; i+chunk <= len(lines); {
+				cand := append(append([]string{}, lines[:i]...), lines[i+chunk:]...)
+				if pred(cand) {
+					lines = cand
+					changed = true
+				} else {
+					i += chunk
+				}
+			}
+		}
+	}
+	for i, ln := range lines {
+		t := strings.TrimSpace(ln)
+		if t == "" || strings.HasPrefix(t, ";") || strings.HasPrefix(t, ".") ||
+			strings.HasSuffix(t, ":") || t == "adi r0, r0, 0" {
+			continue
+		}
+		cand := append([]string{}, lines...)
+		cand[i] = "\tadi r0, r0, 0\n"
+		if pred(cand) {
+			lines = cand
+		}
+	}
+	return lines
+}
+
+// fails runs the reproduction command on a candidate and reports
+// whether the failure is still present.
+func fails(lines []string) bool {
+	runs++
+	f, err := os.CreateTemp("", "ymin-*.s")
+	if err != nil {
+		fatal("%v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(strings.Join(lines, "")); err != nil {
+		fatal("%v", err)
+	}
+	f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", strings.ReplaceAll(*runCmd, "{}", f.Name()))
+	// The command is a shell with children (asm, func); kill the
+	// whole process group on timeout or a hung grandchild keeps the
+	// output pipe open and CombinedOutput never returns.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if err == nil || ctx.Err() != nil {
+		// A candidate that hangs (a removed hlt, usually) is a
+		// different bug than the one being chased.
+		return false
+	}
+	return *matchText == "" || bytes.Contains(out, []byte(*matchText))
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ymin: "+format+"\n", args...)
+	os.Exit(1)
+}
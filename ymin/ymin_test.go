@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// The oracle fails while both "poison" lines survive, standing in
+// for a two-instruction interaction that reproduces an emulator bug.
+func TestMinimize(t *testing.T) {
+	src := []string{
+		"; a comment\n",
+		".func main, 0\n",
+		"\tadi r2, r0, 1\n",
+		"\tpoison one\n",
+		"\tadi r3, r0, 2\n",
+		"\tadi r4, r0, 3\n",
+		"\tpoison two\n",
+		"\thlt\n",
+		".endfunc\n",
+	}
+	pred := func(lines []string) bool {
+		all := strings.Join(lines, "")
+		return strings.Contains(all, "poison one") && strings.Contains(all, "poison two")
+	}
+	min := minimize(src, pred)
+	want := []string{"\tpoison one\n", "\tpoison two\n"}
+	if len(min) != 2 || min[0] != want[0] || min[1] != want[1] {
+		t.Errorf("minimize = %q, want %q", min, want)
+	}
+}
+
+// Lines the oracle needs are kept even when removal is tried at
+// every chunk size; everything else goes, including the nop pass
+// rewriting instructions that must stay for spacing.
+func TestMinimizeNopPass(t *testing.T) {
+	src := []string{
+		"\tadi r2, r0, 1\n",
+		"\tadi r3, r0, 2\n",
+		"\thlt\n",
+	}
+	// Oracle: exactly three lines, last is hlt — models a pc-relative
+	// failure that needs the spacing but not the arithmetic.
+	pred := func(lines []string) bool {
+		return len(lines) == 3 && strings.TrimSpace(lines[2]) == "hlt"
+	}
+	min := minimize(src, pred)
+	if len(min) != 3 {
+		t.Fatalf("minimize = %q, want 3 lines", min)
+	}
+	for _, ln := range min[:2] {
+		if strings.TrimSpace(ln) != "adi r0, r0, 0" {
+			t.Errorf("line %q not simplified to the no-op", ln)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// ynm lists the symbols of a WUT-4 binary, nm-style: one symbol per
+// line with its address, a class letter, and its name, for scripted
+// build checks (is this symbol present, did that one move, does
+// anything match this pattern).
+//
+// Usage: ynm [-n] [-filter regex] binary.elf | prog.syms
+//
+// Symbols come from an ELF container's .symtab or from an asm -syms
+// dump; the native WUT4 format carries no symbols, so for it pass the
+// dump instead. Class letters: T for a function entry (a symbol with
+// a name.frame partner), U for an undefined ELF symbol, A for
+// everything else — the formats record no section for plain labels.
+// The default order is alphabetical; -n sorts by address, like nm.
+package main
+
+import (
+	"debug/elf"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+var numeric = flag.Bool("n", false, "sort by address instead of name")
+var filter = flag.String("filter", "", "list only symbols matching this regexp")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+
+type sym struct {
+	name    string
+	value   uint16
+	defined bool
+}
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("ynm isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ynm [-n] [-filter regex] binary.elf | prog.syms")
+		os.Exit(2)
+	}
+	syms, err := loadSymbols(flag.Arg(0))
+	if err != nil {
+		fatal("%v", err)
+	}
+	if *filter != "" {
+		re, err := regexp.Compile(*filter)
+		if err != nil {
+			fatal("-filter: %v", err)
+		}
+		var kept []sym
+		for _, s := range syms {
+			if re.MatchString(s.name) {
+				kept = append(kept, s)
+			}
+		}
+		syms = kept
+	}
+	for _, line := range listing(syms, *numeric) {
+		fmt.Println(line)
+	}
+}
+
+// listing formats the symbols in the requested order. It is separate
+// from main so the output shape is testable.
+func listing(syms []sym, numeric bool) []string {
+	frames := make(map[string]bool)
+	for _, s := range syms {
+		if name, ok := strings.CutSuffix(s.name, ".frame"); ok {
+			frames[name] = true
+		}
+	}
+	sort.SliceStable(syms, func(i, j int) bool {
+		if numeric && syms[i].value != syms[j].value {
+			return syms[i].value < syms[j].value
+		}
+		return syms[i].name < syms[j].name
+	})
+	var out []string
+	for _, s := range syms {
+		switch {
+		case !s.defined:
+			out = append(out, fmt.Sprintf("       U %s", s.name))
+		case frames[s.name]:
+			out = append(out, fmt.Sprintf("0x%04X T %s", s.value, s.name))
+		default:
+			out = append(out, fmt.Sprintf("0x%04X A %s", s.value, s.name))
+		}
+	}
+	return out
+}
+
+// loadSymbols reads symbols from an ELF .symtab or an asm -syms dump.
+func loadSymbols(path string) ([]sym, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if obj.IsObject(b) {
+		return nil, fmt.Errorf("%s: WUT4 format carries no symbols; pass the asm -syms dump", path)
+	}
+	if obj.IsELF(b) {
+		return elfSymbols(path)
+	}
+	dump, err := obj.ReadSyms(path)
+	if err != nil {
+		return nil, err
+	}
+	var syms []sym
+	for _, s := range dump {
+		syms = append(syms, sym{name: s.Name, value: s.Value, defined: true})
+	}
+	return syms, nil
+}
+
+func elfSymbols(path string) ([]sym, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	defer f.Close()
+	if f.Machine != elf.Machine(obj.ElfMachine) {
+		return nil, fmt.Errorf("%s: ELF machine 0x%X is not WUT-4 (0x%X)",
+			path, uint16(f.Machine), obj.ElfMachine)
+	}
+	es, err := f.Symbols()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var syms []sym
+	for _, e := range es {
+		syms = append(syms, sym{
+			name:    e.Name,
+			value:   uint16(e.Value),
+			defined: e.Section != elf.SHN_UNDEF,
+		})
+	}
+	return syms, nil
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ynm: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testSyms() []sym {
+	return []sym{
+		{"main", 0, true},
+		{"main.frame", 4, true},
+		{"limit", 60, true},
+		{"putc", 0, false},
+	}
+}
+
+// Functions get T from their .frame partner, undefined symbols U with
+// no address, everything else A; default order is alphabetical and -n
+// is by address.
+func TestListing(t *testing.T) {
+	got := listing(testSyms(), false)
+	want := []string{
+		"0x003C A limit",
+		"0x0000 T main",
+		"0x0004 A main.frame",
+		"       U putc",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("alphabetical listing = %q, want %q", got, want)
+	}
+	got = listing(testSyms(), true)
+	if got[0] != "0x0000 T main" || got[len(got)-1] != "0x003C A limit" {
+		t.Errorf("numeric listing = %q", got)
+	}
+}
@@ -0,0 +1,455 @@
+// ypatch applies small symbolic patches to an existing WUT4 binary,
+// so quick experiments on generated code — swap one instruction, stub
+// a region out with nops, try a different immediate — don't require a
+// full reassembly. Patches address the code segment by symbol (from
+// asm -syms) plus word offset, and instructions are encoded with the
+// shared ISA encoders, so a patched word is exactly what the
+// assembler would have emitted.
+//
+// Usage: ypatch [-syms prog.syms] [-o out.bin] binary.bin patch.yp
+//
+// The patch file has one directive per line; # starts a comment.
+//
+//	set LOC INSTRUCTION     replace the word at LOC
+//	nop LOC [COUNT]         overwrite COUNT words (default 1) with nop
+//	imm LOC VALUE           re-encode only the immediate field at LOC
+//	word LOC VALUE          store a raw word (the escape hatch)
+//
+// LOC is a symbol, symbol+N, symbol-N, or a bare word address. nop
+// overwrites rather than inserts: shifting code would silently break
+// every branch across the patch point. For a branch, imm takes the
+// absolute target address, as in assembly source.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+var outFile = flag.String("o", "", "output file (default: patch in place)")
+var symsFile = flag.String("syms", "", "symbol table from asm -syms, for symbolic addresses")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("ypatch isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: ypatch [-syms prog.syms] [-o out.bin] binary.bin patch.yp")
+		os.Exit(2)
+	}
+	im, err := obj.Read(flag.Arg(0))
+	if err != nil {
+		fatal("%v", err)
+	}
+	syms := map[string]uint16{}
+	if *symsFile != "" {
+		if syms, err = obj.ReadSymsMap(*symsFile); err != nil {
+			fatal("%v", err)
+		}
+	}
+	b, err := os.ReadFile(flag.Arg(1))
+	if err != nil {
+		fatal("%v", err)
+	}
+	p := &patcher{im: im, syms: syms}
+	for i, line := range strings.Split(string(b), "\n") {
+		if err := p.apply(line); err != nil {
+			fatal("%s:%d: %v", flag.Arg(1), i+1, err)
+		}
+	}
+	out := *outFile
+	if out == "" {
+		out = flag.Arg(0)
+	}
+	if err := im.Write(out); err != nil {
+		fatal("%v", err)
+	}
+}
+
+type patcher struct {
+	im   *obj.Image
+	syms map[string]uint16
+}
+
+// apply executes one patch line; blank lines and comments are no-ops.
+func (p *patcher) apply(line string) error {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	f := strings.Fields(line)
+	if len(f) == 0 {
+		return nil
+	}
+	switch f[0] {
+	case "word":
+		if len(f) != 3 {
+			return fmt.Errorf("word needs a location and a value")
+		}
+		addr, err := p.loc(f[1])
+		if err != nil {
+			return err
+		}
+		v, err := p.eval(f[2])
+		if err != nil {
+			return err
+		}
+		if v < -0x8000 || v > 0xFFFF {
+			return fmt.Errorf("word value %d out of range", v)
+		}
+		p.im.Code[addr] = uint16(v)
+	case "nop":
+		if len(f) != 2 && len(f) != 3 {
+			return fmt.Errorf("nop needs a location and an optional count")
+		}
+		addr, err := p.loc(f[1])
+		if err != nil {
+			return err
+		}
+		count := 1
+		if len(f) == 3 {
+			if count, err = p.eval(f[2]); err != nil {
+				return err
+			}
+		}
+		if count < 1 || int(addr)+count > len(p.im.Code) {
+			return fmt.Errorf("nop count %d runs off the code segment", count)
+		}
+		for i := 0; i < count; i++ {
+			p.im.Code[int(addr)+i] = isa.EncodeMem(isa.OpAdi, 0, 0, 0)
+		}
+	case "imm":
+		if len(f) != 3 {
+			return fmt.Errorf("imm needs a location and a value")
+		}
+		addr, err := p.loc(f[1])
+		if err != nil {
+			return err
+		}
+		v, err := p.eval(f[2])
+		if err != nil {
+			return err
+		}
+		w, err := reimm(p.im.Code[addr], addr, v)
+		if err != nil {
+			return err
+		}
+		p.im.Code[addr] = w
+	case "set":
+		if len(f) < 2 {
+			return fmt.Errorf("set needs a location and an instruction")
+		}
+		addr, err := p.loc(f[1])
+		if err != nil {
+			return err
+		}
+		w, err := p.encode(strings.Join(f[2:], " "), addr)
+		if err != nil {
+			return err
+		}
+		p.im.Code[addr] = w
+	default:
+		return fmt.Errorf("unknown directive %q", f[0])
+	}
+	return nil
+}
+
+// loc resolves a code location: a symbol, symbol+N, symbol-N, or a
+// bare word address, checked against the code segment.
+func (p *patcher) loc(s string) (uint16, error) {
+	v, err := p.eval(s)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || v >= len(p.im.Code) {
+		return 0, fmt.Errorf("%s: address %d outside the code segment (%d words)",
+			s, v, len(p.im.Code))
+	}
+	return uint16(v), nil
+}
+
+// eval resolves a number or a symbol with an optional +N or -N.
+func (p *patcher) eval(s string) (int, error) {
+	if v, err := strconv.ParseInt(s, 0, 32); err == nil {
+		return int(v), nil
+	}
+	sym, off := s, 0
+	if i := strings.IndexAny(s, "+-"); i > 0 {
+		o, err := strconv.ParseInt(s[i:], 0, 32)
+		if err != nil {
+			return 0, fmt.Errorf("%s: bad offset", s)
+		}
+		sym, off = s[:i], int(o)
+	}
+	v, ok := p.syms[sym]
+	if !ok {
+		return 0, fmt.Errorf("%s: undefined symbol %q (need -syms?)", s, sym)
+	}
+	return int(v) + off, nil
+}
+
+// encode assembles one instruction with the shared ISA encoders.
+// Operands are registers (rN, lnk, sp) and numbers or symbols; a
+// branch takes its absolute target, as in source.
+func (p *patcher) encode(text string, addr uint16) (uint16, error) {
+	mnem, rest, _ := strings.Cut(strings.TrimSpace(text), " ")
+	var opnds []string
+	if rest = strings.TrimSpace(rest); rest != "" {
+		opnds = strings.Split(rest, ",")
+		for i := range opnds {
+			opnds[i] = strings.TrimSpace(opnds[i])
+		}
+	}
+	d := isa.ByMnemonic(mnem)
+	if d == nil {
+		return 0, fmt.Errorf("unknown instruction %q (pseudos are not supported)", mnem)
+	}
+	reg := func(i int) (int, error) {
+		if i >= len(opnds) {
+			return 0, fmt.Errorf("%s: too few operands", mnem)
+		}
+		if r, ok := regNumber(opnds[i]); ok {
+			return r, nil
+		}
+		return 0, fmt.Errorf("%s: %q is not a register", mnem, opnds[i])
+	}
+	num := func(i, lo, hi int) (int, error) {
+		if i >= len(opnds) {
+			return 0, fmt.Errorf("%s: too few operands", mnem)
+		}
+		v, err := p.eval(opnds[i])
+		if err != nil {
+			return 0, err
+		}
+		if v < lo || v > hi {
+			return 0, fmt.Errorf("%s: %d out of range %d..%d", mnem, v, lo, hi)
+		}
+		return v, nil
+	}
+	want := func(n int) error {
+		if len(opnds) != n {
+			return fmt.Errorf("%s: takes %d operands, got %d", mnem, n, len(opnds))
+		}
+		return nil
+	}
+
+	switch d.Fmt {
+	case isa.FmtMem, isa.FmtAdi:
+		if err := want(3); err != nil {
+			return 0, err
+		}
+		ra, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		rb, err := reg(1)
+		if err != nil {
+			return 0, err
+		}
+		imm, err := num(2, -64, 63)
+		if err != nil {
+			return 0, err
+		}
+		return isa.EncodeMem(d.Op, ra, rb, imm), nil
+	case isa.FmtBranch:
+		if err := want(3); err != nil {
+			return 0, err
+		}
+		ra, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		rb, err := reg(1)
+		if err != nil {
+			return 0, err
+		}
+		target, err := num(2, 0, 0xFFFF)
+		if err != nil {
+			return 0, err
+		}
+		disp := target - int(addr) - 1
+		if disp < -64 || disp > 63 {
+			return 0, fmt.Errorf("%s: target 0x%04X is %d words away, out of branch range", mnem, target, disp)
+		}
+		return isa.EncodeMem(d.Op, ra, rb, disp), nil
+	case isa.FmtLui:
+		if err := want(2); err != nil {
+			return 0, err
+		}
+		ra, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		imm, err := num(1, 0, 0xFFFF)
+		if err != nil {
+			return 0, err
+		}
+		if imm > 0x3FF {
+			// The assembler's aligned-constant special case.
+			if imm&0x3F != 0 {
+				return 0, fmt.Errorf("lui: %d is neither a field value 0..1023 nor a multiple of 64", imm)
+			}
+			imm >>= 6
+		}
+		return isa.EncodeLui(ra, imm), nil
+	case isa.FmtXop, isa.FmtIo:
+		if err := want(2); err != nil {
+			return 0, err
+		}
+		ra, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		rb, err := reg(1)
+		if err != nil {
+			return 0, err
+		}
+		if d.Fmt == isa.FmtIo {
+			return isa.EncodeIo(d.Yop, ra, rb), nil
+		}
+		return isa.EncodeXop(d.Xop, ra, rb), nil
+	case isa.FmtJlr:
+		if err := want(3); err != nil {
+			return 0, err
+		}
+		ra, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		rb, err := reg(1)
+		if err != nil {
+			return 0, err
+		}
+		imm, err := num(2, 0, 15)
+		if err != nil {
+			return 0, err
+		}
+		return isa.EncodeJlr(ra, rb, imm), nil
+	case isa.FmtSpr:
+		if err := want(2); err != nil {
+			return 0, err
+		}
+		ra, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		spr, err := num(1, 0, isa.NumSpr-1)
+		if err != nil {
+			return 0, err
+		}
+		return isa.EncodeSpr(d.Yop, ra, spr), nil
+	case isa.FmtSys:
+		if err := want(1); err != nil {
+			return 0, err
+		}
+		imm, err := num(0, 0, 63)
+		if err != nil {
+			return 0, err
+		}
+		return isa.EncodeSys(imm), nil
+	case isa.FmtShi:
+		if err := want(2); err != nil {
+			return 0, err
+		}
+		ra, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		n, err := num(1, 0, 7)
+		if err != nil {
+			return 0, err
+		}
+		return isa.EncodeShi(d.Yop, ra, n), nil
+	case isa.FmtYop:
+		if err := want(1); err != nil {
+			return 0, err
+		}
+		ra, err := reg(0)
+		if err != nil {
+			return 0, err
+		}
+		return isa.EncodeYop(d.Yop, ra), nil
+	default: // FmtVop
+		if err := want(0); err != nil {
+			return 0, err
+		}
+		return isa.EncodeVop(d.Vop), nil
+	}
+}
+
+// reimm re-encodes just the immediate field of an existing word,
+// keeping the opcode and registers. For a branch, v is the absolute
+// target address.
+func reimm(w, addr uint16, v int) (uint16, error) {
+	d := isa.Decode(w)
+	if d == nil {
+		return 0, fmt.Errorf("word 0x%04X at 0x%04X is not an instruction", w, addr)
+	}
+	ra, rb := isa.GetRa(w), isa.GetRb(w)
+	check := func(lo, hi int) error {
+		if v < lo || v > hi {
+			return fmt.Errorf("%s: immediate %d out of range %d..%d", d.Mnemonic, v, lo, hi)
+		}
+		return nil
+	}
+	switch d.Fmt {
+	case isa.FmtMem, isa.FmtAdi:
+		if err := check(-64, 63); err != nil {
+			return 0, err
+		}
+		return isa.EncodeMem(d.Op, ra, rb, v), nil
+	case isa.FmtBranch:
+		disp := v - int(addr) - 1
+		if disp < -64 || disp > 63 {
+			return 0, fmt.Errorf("%s: target 0x%04X is %d words away, out of branch range", d.Mnemonic, v, disp)
+		}
+		return isa.EncodeMem(d.Op, ra, rb, disp), nil
+	case isa.FmtLui:
+		if err := check(0, 0x3FF); err != nil {
+			return 0, err
+		}
+		return isa.EncodeLui(ra, v), nil
+	case isa.FmtJlr:
+		if err := check(0, 15); err != nil {
+			return 0, err
+		}
+		return isa.EncodeJlr(ra, rb, v), nil
+	case isa.FmtSys:
+		if err := check(0, 63); err != nil {
+			return 0, err
+		}
+		return isa.EncodeSys(v), nil
+	case isa.FmtShi:
+		if err := check(0, 7); err != nil {
+			return 0, err
+		}
+		return isa.EncodeShi(d.Yop, ra, v), nil
+	}
+	return 0, fmt.Errorf("%s has no immediate field", d.Mnemonic)
+}
+
+func regNumber(s string) (int, bool) {
+	if len(s) == 2 && s[0] == 'r' && s[1] >= '0' && s[1] <= '7' {
+		return int(s[1] - '0'), true
+	}
+	switch s {
+	case "lnk":
+		return 1, true
+	case "sp":
+		return 6, true
+	}
+	return 0, false
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ypatch: "+format+"\n", args...)
+	os.Exit(1)
+}
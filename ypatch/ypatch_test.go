@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+func testPatcher() *patcher {
+	return &patcher{
+		im: &obj.Image{Code: []uint16{
+			isa.EncodeMem(isa.OpAdi, 2, 0, 5),
+			isa.EncodeMem(isa.OpBeq, 0, 0, 2),
+			isa.EncodeXop(isa.XopAdd, 2, 3),
+			isa.EncodeVop(isa.VopHlt),
+		}},
+		syms: map[string]uint16{"main": 0, "done": 3},
+	}
+}
+
+// Each directive must produce exactly the word the assembler would
+// have emitted at that address.
+func TestPatchDirectives(t *testing.T) {
+	p := testPatcher()
+	lines := []string{
+		"# stub out the add, retarget the branch, tweak the immediate",
+		"nop main+2",
+		"imm main+1 done",
+		"imm main 9",
+		"set done sub r2, r3",
+		"word main+3 0x1234 # overwrites the set",
+	}
+	for _, line := range lines {
+		if err := p.apply(line); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+	}
+	want := []uint16{
+		isa.EncodeMem(isa.OpAdi, 2, 0, 9),
+		isa.EncodeMem(isa.OpBeq, 0, 0, 1),
+		isa.EncodeMem(isa.OpAdi, 0, 0, 0),
+		0x1234,
+	}
+	for i, w := range want {
+		if p.im.Code[i] != w {
+			t.Errorf("word %d = 0x%04X, want 0x%04X", i, p.im.Code[i], w)
+		}
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+	cases := []struct{ line, want string }{
+		{"set main blt r1, r2", "unknown instruction"},
+		{"set main adi r2, r0, 99", "out of range"},
+		{"imm main+3 4", "no immediate field"},
+		{"nop nowhere", "undefined symbol"},
+		{"nop main 99", "runs off the code segment"},
+		{"frob main", "unknown directive"},
+	}
+	for _, c := range cases {
+		err := testPatcher().apply(c.line)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%q: error %v, want %q", c.line, err, c.want)
+		}
+	}
+}
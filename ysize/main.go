@@ -0,0 +1,103 @@
+// ysize reports where a WUT-4 binary's memory budget is going: the
+// code and data section sizes against the 64 KiW imem and 64 KiB dmem
+// limits, and with an asm -syms dump, a per-function size listing
+// sorted largest first.
+//
+// Usage: ysize [-syms prog.syms] [-n count] binary.bin
+//
+// Function extents come from the synthetic name.frame symbols the
+// assembler defines for every .func: a symbol with a .frame partner
+// starts a function, which runs to the next function or the end of
+// the code segment. The flat -syms dump does not record which segment
+// a plain label is in, so only functions get sizes.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+var symsFile = flag.String("syms", "", "symbol table from asm -syms, for the function listing")
+var topN = flag.Int("n", 0, "list only the largest n functions; 0 for all")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("ysize isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ysize [-syms prog.syms] [-n count] binary.bin")
+		os.Exit(2)
+	}
+	im, err := obj.Read(flag.Arg(0))
+	if err != nil {
+		fatal("%v", err)
+	}
+	fmt.Printf("%s: code %d words (%.1f%% of imem), data %d bytes (%.1f%% of dmem)\n",
+		flag.Arg(0), len(im.Code), 100*float64(len(im.Code))/(1<<16),
+		len(im.Data), 100*float64(len(im.Data))/(1<<16))
+	if *symsFile == "" {
+		return
+	}
+	syms, err := obj.ReadSymsMap(*symsFile)
+	if err != nil {
+		fatal("%v", err)
+	}
+	funcs := functionSizes(syms, len(im.Code))
+	if len(funcs) == 0 {
+		fmt.Println("no functions (no name.frame symbols in the table)")
+		return
+	}
+	if *topN > 0 && len(funcs) > *topN {
+		funcs = funcs[:*topN]
+	}
+	fmt.Println("largest functions:")
+	for _, f := range funcs {
+		fmt.Printf("  %-20s 0x%04X  %5d words  %5.1f%%\n",
+			f.name, f.start, f.size, 100*float64(f.size)/float64(len(im.Code)))
+	}
+}
+
+type function struct {
+	name        string
+	start, size int
+}
+
+// functionSizes finds the .func symbols and sizes each one as the
+// span to the next function or the end of code, sorted largest first
+// (ties by address, for stable output).
+func functionSizes(syms map[string]uint16, codeWords int) []function {
+	var funcs []function
+	for name := range syms {
+		if _, ok := syms[name+".frame"]; ok {
+			funcs = append(funcs, function{name: name, start: int(syms[name])})
+		}
+	}
+	sort.Slice(funcs, func(i, j int) bool { return funcs[i].start < funcs[j].start })
+	for i := range funcs {
+		end := codeWords
+		if i+1 < len(funcs) {
+			end = funcs[i+1].start
+		}
+		funcs[i].size = end - funcs[i].start
+	}
+	sort.SliceStable(funcs, func(i, j int) bool {
+		if funcs[i].size != funcs[j].size {
+			return funcs[i].size > funcs[j].size
+		}
+		return funcs[i].start < funcs[j].start
+	})
+	return funcs
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ysize: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+// Functions are the symbols with .frame partners; each spans to the
+// next function, and the listing comes back largest first.
+func TestFunctionSizes(t *testing.T) {
+	syms := map[string]uint16{
+		"main":        0,
+		"main.frame":  4,
+		"loop":        3, // plain label inside main, not a function
+		"print":       10,
+		"print.frame": 0,
+		"limit":       60, // .set constant, no .frame
+	}
+	funcs := functionSizes(syms, 40)
+	want := []function{
+		{"print", 10, 30},
+		{"main", 0, 10},
+	}
+	if len(funcs) != len(want) {
+		t.Fatalf("got %d functions, want %d: %+v", len(funcs), len(want), funcs)
+	}
+	for i, w := range want {
+		if funcs[i] != w {
+			t.Errorf("function %d = %+v, want %+v", i, funcs[i], w)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// ystrings scans a WUT-4 binary for printable ASCII runs, strings
+// style but segment-aware: the data segment is scanned by default,
+// with addresses reported as dmem byte addresses, so the output lines
+// up with what .string directives emitted and with what a running
+// program sees.
+//
+// Usage: ystrings [-min n] [-code] binary.bin
+//
+// With -code the code segment is scanned too (as its little-endian
+// bytes, addresses in imem words), which turns up immediates and
+// constants that happen to spell text — useful when reverse
+// engineering, noise when verifying the assembler.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+	"github.com/gmofishsauce/y4/wire"
+)
+
+var minLen = flag.Int("min", 4, "minimum run length to report")
+var scanCode = flag.Bool("code", false, "scan the code segment too")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("ystrings isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() != 1 || *minLen < 1 {
+		fmt.Fprintln(os.Stderr, "usage: ystrings [-min n] [-code] binary.bin")
+		os.Exit(2)
+	}
+	im, err := obj.Read(flag.Arg(0))
+	if err != nil {
+		fatal("%v", err)
+	}
+	for _, r := range runs(im.Data, *minLen) {
+		fmt.Printf("data 0x%04X: %s\n", r.addr, r.text)
+	}
+	if *scanCode {
+		code := make([]byte, 2*len(im.Code))
+		for i, w := range im.Code {
+			wire.PutWord(code[2*i:], w)
+		}
+		for _, r := range runs(code, *minLen) {
+			// Code addresses are words; the run may start mid-word.
+			fmt.Printf("code 0x%04X.%d: %s\n", r.addr/2, r.addr%2, r.text)
+		}
+	}
+}
+
+type run struct {
+	addr int
+	text string
+}
+
+// runs finds maximal runs of at least min printable characters.
+// Printable is plain ASCII plus tab; a NUL or newline ends a run, so
+// .string emissions come back exactly as written.
+func runs(b []byte, min int) []run {
+	var out []run
+	start := -1
+	for i := 0; i <= len(b); i++ {
+		if i < len(b) && printable(b[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= min {
+			out = append(out, run{start, string(b[start:i])})
+		}
+		start = -1
+	}
+	return out
+}
+
+func printable(c byte) bool { return c >= 0x20 && c < 0x7F || c == '\t' }
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ystrings: "+format+"\n", args...)
+	os.Exit(1)
+}
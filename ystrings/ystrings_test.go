@@ -0,0 +1,26 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Runs are maximal, at least min long, and end at NUL; short runs and
+// lone printables disappear.
+func TestRuns(t *testing.T) {
+	b := []byte("hello\x00hi\x00\x01world!\ttab")
+	got := runs(b, 4)
+	want := []run{
+		{0, "hello"},
+		{10, "world!\ttab"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("runs = %+v, want %+v", got, want)
+	}
+	if got := runs([]byte("abc"), 4); got != nil {
+		t.Errorf("short input gave %+v", got)
+	}
+	if got := runs([]byte("tail"), 4); len(got) != 1 || got[0].text != "tail" {
+		t.Errorf("run at end of input gave %+v", got)
+	}
+}
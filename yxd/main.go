@@ -0,0 +1,94 @@
+// yxd is a hex dump tool that knows the shape of a WUT-4 binary: the
+// code segment is 16-bit little-endian words and the data segment is
+// bytes. It dumps each segment in its natural unit with virtual
+// addresses — imem word addresses for code, dmem byte addresses for
+// data — rather than file offsets, so a line of output can be
+// compared directly with a debugger examine or a dis listing.
+//
+// Usage: yxd [-code] [-data] binary.bin
+//
+// By default both segments are dumped; -code or -data selects one.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gmofishsauce/y4/isa"
+	"github.com/gmofishsauce/y4/obj"
+)
+
+var codeOnly = flag.Bool("code", false, "dump only the code segment")
+var dataOnly = flag.Bool("data", false, "dump only the data segment")
+var version = flag.Bool("version", false, "report ISA and object format versions and exit")
+
+func main() {
+	flag.Parse()
+	if *version {
+		fmt.Printf("yxd isa=%d obj=%d\n", isa.Version, obj.Version)
+		return
+	}
+	if flag.NArg() != 1 || (*codeOnly && *dataOnly) {
+		fmt.Fprintln(os.Stderr, "usage: yxd [-code] [-data] binary.bin")
+		os.Exit(2)
+	}
+	im, err := obj.Read(flag.Arg(0))
+	if err != nil {
+		fatal("%v", err)
+	}
+	if !*dataOnly {
+		dumpWords(os.Stdout, im.Code)
+	}
+	if !*codeOnly {
+		dumpBytes(os.Stdout, im.Data)
+	}
+}
+
+// dumpWords prints code eight words per line with imem addresses.
+func dumpWords(w io.Writer, code []uint16) {
+	for i := 0; i < len(code); i += 8 {
+		fmt.Fprintf(w, "code 0x%04X:", i)
+		for j := i; j < i+8 && j < len(code); j++ {
+			fmt.Fprintf(w, " %04X", code[j])
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// dumpBytes prints data sixteen bytes per line with dmem addresses
+// and an ASCII column, xxd style.
+func dumpBytes(w io.Writer, data []byte) {
+	for i := 0; i < len(data); i += 16 {
+		fmt.Fprintf(w, "data 0x%04X:", i)
+		n := len(data) - i
+		if n > 16 {
+			n = 16
+		}
+		for j := 0; j < 16; j++ {
+			if j == 8 {
+				fmt.Fprint(w, " ")
+			}
+			if j < n {
+				fmt.Fprintf(w, " %02X", data[i+j])
+			} else {
+				fmt.Fprint(w, "   ")
+			}
+		}
+		ascii := make([]byte, n)
+		for j := 0; j < n; j++ {
+			if c := data[i+j]; c >= 0x20 && c < 0x7F {
+				ascii[j] = c
+			} else {
+				ascii[j] = '.'
+			}
+		}
+		fmt.Fprintf(w, "  |%s|\n", ascii)
+	}
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "yxd: "+format+"\n", args...)
+	os.Exit(1)
+}
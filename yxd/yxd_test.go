@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Code lines carry word addresses and whole words; data lines carry
+// byte addresses, padding for short tails, and the ASCII column.
+func TestDumpFormats(t *testing.T) {
+	var out strings.Builder
+	dumpWords(&out, []uint16{0x1234, 0xBEEF, 0x0000, 0xFFFF, 1, 2, 3, 4, 5})
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("9 words gave %d lines: %q", len(lines), lines)
+	}
+	if lines[0] != "code 0x0000: 1234 BEEF 0000 FFFF 0001 0002 0003 0004" {
+		t.Errorf("code line = %q", lines[0])
+	}
+	if lines[1] != "code 0x0008: 0005" {
+		t.Errorf("short code line = %q", lines[1])
+	}
+
+	out.Reset()
+	dumpBytes(&out, []byte("Hi\x00\x01"))
+	want := "data 0x0000: 48 69 00 01                      " +
+		"                 |Hi..|\n"
+	if out.String() != want {
+		t.Errorf("data dump = %q, want %q", out.String(), want)
+	}
+}